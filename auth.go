@@ -0,0 +1,353 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Logging in to a service.
+
+// A Token is a credential obtained by logging in.
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`  // zero if the token doesn't expire
+	Account      string    `json:"account,omitempty"` // who the token is for, for display
+}
+
+// Expired reports whether t has an expiry time that has passed.
+func (t *Token) Expired() bool {
+	return !t.Expiry.IsZero() && time.Now().After(t.Expiry)
+}
+
+// A TokenSource obtains a token by logging in, for example with DeviceFlow.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenSourceFunc adapts a function to a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (*Token, error)
+
+// Token calls f.
+func (f TokenSourceFunc) Token(ctx context.Context) (*Token, error) { return f(ctx) }
+
+// A TokenStore keeps a token between runs of a program.
+type TokenStore interface {
+	// Load returns the stored token, or ErrNotLoggedIn if there is none.
+	Load() (*Token, error)
+	Save(*Token) error
+	// Delete removes the stored token. It is not an error if there is none.
+	Delete() error
+}
+
+// ErrNotLoggedIn is returned by TokenStore.Load when there is no token.
+var ErrNotLoggedIn = errors.New("not logged in")
+
+// DefaultTokenStore returns a TokenStore for the token of the program named
+// prog. It keeps the token in the system's keychain, using KeychainStore and
+// CredentialTokenStore, if there is one. Otherwise it uses FileTokenStore with
+// the file at DefaultTokenPath.
+func DefaultTokenStore(prog string) (TokenStore, error) {
+	cs, err := keychainStore()
+	if err == nil {
+		return CredentialTokenStore(cs, prog, "token"), nil
+	}
+	if !errors.Is(err, ErrNoKeychain) {
+		return nil, err
+	}
+	path, err := DefaultTokenPath(prog)
+	if err != nil {
+		return nil, err
+	}
+	return FileTokenStore(path), nil
+}
+
+// keychainStore is KeychainStore. It is a variable for testing.
+var keychainStore = KeychainStore
+
+// FileTokenStore returns a TokenStore that keeps the token as JSON in the file
+// at path, readable only by the user. The token is not encrypted; the file's
+// permissions are its only protection, so DefaultTokenStore uses it only when
+// there is no keychain.
+func FileTokenStore(path string) TokenStore {
+	return fileTokenStore(path)
+}
+
+type fileTokenStore string
+
+func (p fileTokenStore) Load() (*Token, error) {
+	data, err := os.ReadFile(string(p))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotLoggedIn
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t Token
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return &t, nil
+}
+
+func (p fileTokenStore) Save(t *Token) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(string(p)), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(string(p), data, 0o600)
+}
+
+func (p fileTokenStore) Delete() error {
+	err := os.Remove(string(p))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// DefaultTokenPath returns the path of a file for the token of the program
+// named prog: "token.json" in the directory named prog in the user's state
+// directory. On Unix systems other than macOS, that is $XDG_STATE_HOME, or
+// ~/.local/state if it is not set; elsewhere it is the configuration
+// directory of os.UserConfigDir.
+func DefaultTokenPath(prog string) (string, error) {
+	dir, err := userStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, prog, "token.json"), nil
+}
+
+// userStateDir returns the directory for data that a program keeps between
+// runs, as described at DefaultTokenPath.
+func userStateDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows", "darwin", "ios", "plan9":
+		return os.UserConfigDir()
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		if !filepath.IsAbs(dir) {
+			return "", errors.New("path in $XDG_STATE_HOME is relative")
+		}
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state"), nil
+}
+
+// AddAuthCommands adds a group of sub-commands named "auth" to c:
+//
+//   - "auth login" gets a token from src and saves it in store.
+//   - "auth status" reports whether there is a saved token, and whether it
+//     has expired. It fails if there is no usable token.
+//   - "auth logout" deletes the saved token.
+//
+// Other commands can get the token with store.Load.
+func (c *Command) AddAuthCommands(src TokenSource, store TokenStore) *Command {
+	g := c.Command("auth", nil, "manage credentials")
+	g.Command("login", &authLogin{src: src, store: store}, "log in and save a token")
+	g.Command("status", &authStatus{store: store}, "show whether you are logged in")
+	g.Command("logout", &authLogout{store: store}, "delete the saved token")
+	return g
+}
+
+type authLogin struct {
+	src   TokenSource
+	store TokenStore
+}
+
+func (a *authLogin) Run(ctx context.Context) error {
+	t, err := a.src.Token(ctx)
+	if err != nil {
+		return err
+	}
+	if err := a.store.Save(t); err != nil {
+		return fmt.Errorf("saving token: %w", err)
+	}
+	fmt.Fprintf(Stderr(ctx), "Logged in%s.\n", accountSuffix(t))
+	return nil
+}
+
+type authStatus struct {
+	store TokenStore
+}
+
+func (a *authStatus) Run(ctx context.Context) error {
+	t, err := a.store.Load()
+	if err != nil {
+		return err
+	}
+	if t.Expired() {
+		return fmt.Errorf("logged in%s, but the token expired at %s", accountSuffix(t), t.Expiry.Format(time.RFC3339))
+	}
+	fmt.Fprintf(Stdout(ctx), "Logged in%s.", accountSuffix(t))
+	if !t.Expiry.IsZero() {
+		fmt.Fprintf(Stdout(ctx), " The token expires at %s.", t.Expiry.Format(time.RFC3339))
+	}
+	fmt.Fprintln(Stdout(ctx))
+	return nil
+}
+
+type authLogout struct {
+	store TokenStore
+}
+
+func (a *authLogout) Run(ctx context.Context) error {
+	if err := a.store.Delete(); err != nil {
+		return err
+	}
+	fmt.Fprintln(Stderr(ctx), "Logged out.")
+	return nil
+}
+
+func accountSuffix(t *Token) string {
+	if t.Account == "" {
+		return ""
+	}
+	return " as " + t.Account
+}
+
+// DeviceFlow is a TokenSource that logs in with the OAuth 2.0 device
+// authorization grant (RFC 8628), which suits programs without a web server
+// of their own. It shows the user a code and a URL, opening it with OpenURL,
+// and waits while the user approves the login in a browser.
+type DeviceFlow struct {
+	ClientID      string
+	Scopes        []string
+	DeviceAuthURL string       // the device authorization endpoint
+	TokenURL      string       // the token endpoint
+	HTTPClient    *http.Client // if nil, the client from HTTPClient
+}
+
+// Token implements TokenSource.
+func (d *DeviceFlow) Token(ctx context.Context) (*Token, error) {
+	var auth struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                *int   `json:"interval"`
+	}
+	vals := url.Values{"client_id": {d.ClientID}}
+	if len(d.Scopes) > 0 {
+		vals.Set("scope", strings.Join(d.Scopes, " "))
+	}
+	if err := d.post(ctx, d.DeviceAuthURL, vals, &auth); err != nil {
+		return nil, fmt.Errorf("device authorization: %w", err)
+	}
+	fmt.Fprintf(Stderr(ctx), "Your one-time code is %s\n", auth.UserCode)
+	u := auth.VerificationURIComplete
+	if u == "" {
+		u = auth.VerificationURI
+	}
+	if err := OpenURL(ctx, u); err != nil {
+		return nil, err
+	}
+
+	interval := pollInterval(auth.Interval)
+	var deadline <-chan time.Time
+	if auth.ExpiresIn > 0 {
+		deadline = time.After(time.Duration(auth.ExpiresIn) * time.Second)
+	}
+	vals = url.Values{
+		"client_id":   {d.ClientID},
+		"device_code": {auth.DeviceCode},
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, CheckCancel(ctx)
+		case <-deadline:
+			return nil, errors.New("the login code expired")
+		case <-time.After(interval):
+		}
+		var resp struct {
+			AccessToken  string `json:"access_token"`
+			RefreshToken string `json:"refresh_token"`
+			ExpiresIn    int    `json:"expires_in"`
+			Error        string `json:"error"`
+		}
+		err := d.post(ctx, d.TokenURL, vals, &resp)
+		switch {
+		case resp.Error == "authorization_pending":
+			continue
+		case resp.Error == "slow_down":
+			interval += 5 * time.Second
+			continue
+		case resp.Error != "":
+			return nil, fmt.Errorf("login failed: %s", resp.Error)
+		case err != nil:
+			return nil, err
+		}
+		t := &Token{AccessToken: resp.AccessToken, RefreshToken: resp.RefreshToken}
+		if resp.ExpiresIn > 0 {
+			t.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+		}
+		return t, nil
+	}
+}
+
+// minPollInterval is the shortest time to wait between requests for the
+// token, and the time to wait if the server doesn't say (RFC 8628, section
+// 3.2). It is a variable for testing.
+var minPollInterval = 5 * time.Second
+
+// pollInterval returns the time to wait between requests for the token, given
+// the interval in seconds from the server, if any.
+func pollInterval(secs *int) time.Duration {
+	if secs == nil {
+		return minPollInterval
+	}
+	return max(time.Duration(*secs)*time.Second, minPollInterval)
+}
+
+// post sends a form to u and decodes the JSON response into v, even if the
+// response has an error status, since OAuth errors are reported in the body.
+func (d *DeviceFlow) post(ctx context.Context, u string, form url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	client := d.HTTPClient
+	if client == nil {
+		client, err = HTTPClient(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: %s", u, res.Status)
+	}
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", u, res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prog", "token.json")
+	store := FileTokenStore(path)
+	src := TokenSourceFunc(func(context.Context) (*Token, error) {
+		return &Token{AccessToken: "secret", Account: "pat"}, nil
+	})
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	top.AddAuthCommands(src, store)
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		ctx := withInvocation(context.Background(), &invocation{stdout: &out, stderr: &out})
+		err := top.Run(ctx, args)
+		return out.String(), err
+	}
+
+	if _, err := run("auth", "status"); !errors.Is(err, ErrNotLoggedIn) {
+		t.Fatalf("status before login: got %v, want ErrNotLoggedIn", err)
+	}
+	if _, err := run("auth", "login"); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != 0o600 {
+		t.Errorf("token file mode: got %o, want 600", got)
+	}
+	out, err := run("auth", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Logged in as pat."; !strings.HasPrefix(out, want) {
+		t.Errorf("status: got %q, want prefix %q", out, want)
+	}
+	if strings.Contains(out, "secret") {
+		t.Errorf("status shows the token: %q", out)
+	}
+
+	if err := store.Save(&Token{AccessToken: "x", Expiry: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run("auth", "status"); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("status with expired token: got %v", err)
+	}
+
+	if _, err := run("auth", "logout"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("after logout: got %v, want ErrNotLoggedIn", err)
+	}
+	// Logging out twice is fine.
+	if _, err := run("auth", "logout"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceFlow(t *testing.T) {
+	defer func(d time.Duration) { minPollInterval = d }(minPollInterval)
+	minPollInterval = time.Millisecond
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.FormValue("scope"); got != "read write" {
+			t.Errorf("scope: got %q", got)
+		}
+		fmt.Fprint(w, `{"device_code": "dc", "user_code": "ABCD-1234",
+			"verification_uri": "https://example.com/device", "interval": 0}`)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.FormValue("device_code"); got != "dc" {
+			t.Errorf("device_code: got %q", got)
+		}
+		polls++
+		if polls < 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": "authorization_pending"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "tok", "token_type": "bearer", "expires_in": 3600}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	d := &DeviceFlow{
+		ClientID:      "client",
+		Scopes:        []string{"read", "write"},
+		DeviceAuthURL: srv.URL + "/device",
+		TokenURL:      srv.URL + "/token",
+	}
+	var stderr bytes.Buffer
+	ctx := withInvocation(context.Background(), &invocation{stdin: strings.NewReader(""), stderr: &stderr})
+	tok, err := d.Token(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "tok" || tok.Expired() || tok.Expiry.IsZero() {
+		t.Errorf("got %+v", tok)
+	}
+	if polls != 3 {
+		t.Errorf("got %d polls, want 3", polls)
+	}
+	for _, want := range []string{"ABCD-1234", "https://example.com/device"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("output %q does not contain %q", stderr.String(), want)
+		}
+	}
+}
+
+func TestPollInterval(t *testing.T) {
+	zero, ten := 0, 10
+	for _, test := range []struct {
+		secs *int
+		want time.Duration
+	}{
+		{nil, 5 * time.Second},
+		{&zero, 5 * time.Second},
+		{&ten, 10 * time.Second},
+	} {
+		if got := pollInterval(test.secs); got != test.want {
+			t.Errorf("%v: got %s, want %s", test.secs, got, test.want)
+		}
+	}
+}
+
+func TestDefaultTokenStore(t *testing.T) {
+	defer func(f func() (CredentialStore, error)) { keychainStore = f }(keychainStore)
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+	t.Setenv("HOME", state)
+	t.Setenv("AppData", state)
+
+	keychainStore = func() (CredentialStore, error) { return nil, ErrNoKeychain }
+	store, err := DefaultTokenStore("prog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := DefaultTokenPath("prog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store != FileTokenStore(path) {
+		t.Errorf("without a keychain: got %#v, want a file store at %s", store, path)
+	}
+	if !strings.HasPrefix(path, state) {
+		t.Errorf("path %s is not under %s", path, state)
+	}
+
+	cs := EncryptedFileStore(filepath.Join(t.TempDir(), "creds"), func() ([]byte, error) { return []byte("pw"), nil })
+	keychainStore = func() (CredentialStore, error) { return cs, nil }
+	store, err = DefaultTokenStore("prog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(*credentialTokenStore); !ok {
+		t.Errorf("with a keychain: got %#v, want a credential store", store)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Running several commands at once.
+
+// AddBatchCommand registers a sub-command of c named "batch" that runs
+// several command lines of c's tree in this process, at the same time:
+//
+//	prog batch 'get a' 'get b' 'list -all'
+//	prog batch -f commands.txt -parallel 4
+//
+// Each argument is a command line, split into words as a shell would,
+// without the program name. A file given with -f has one command line per
+// line; blank lines and lines beginning with "#" are skipped. With "-f -",
+// the lines come from standard input.
+//
+// Each command runs with its own standard output and error, and empty
+// standard input. At most -parallel commands run at once, by default
+// runtime.NumCPU(). When all have finished, batch writes their outputs in
+// order, each after a line with the command. If any failed, batch fails like
+// ForEach, listing them, so Command.Main returns 3 if only some of the
+// commands failed.
+//
+// The flags of batch come before the command lines, which may themselves
+// begin with flags, as in "prog batch -parallel 2 '-v get a'".
+//
+// Each command runs on new copies of the commands' structs and bundles, as if
+// Reentrant were set on c. The commands run at the same time unless the
+// copies would share some flag or argument, as they do those added to a flag
+// set directly or with a ParamBuilder; then batch runs them one at a time.
+func (c *Command) AddBatchCommand() *Command {
+	cmd := c.Command("batch", &batchCmd{top: c}, "run several commands at once")
+	cmd.StrictOrder = true
+	return cmd
+}
+
+type batchCmd struct {
+	Parallel int      `cli:"flag=parallel, minval=1, 'the number of commands to run at once; the default is the number of CPUs'"`
+	File     string   `cli:"flag=f, 'file of command lines, one per line; - for standard input'"`
+	Lines    []string `cli:"name=COMMAND, opt=, command lines to run"`
+
+	top *Command
+}
+
+// A batchResult is the outcome of one command run by batch.
+type batchResult struct {
+	line           string
+	stdout, stderr bytes.Buffer
+	code           int
+}
+
+func (b *batchCmd) Run(ctx context.Context) error {
+	lines := append([]string{}, b.Lines...)
+	if b.File != "" {
+		fl, err := b.readFile(ctx)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, fl...)
+	}
+	if len(lines) == 0 {
+		return NewUsageError(errors.New("no commands to run"))
+	}
+	var results []*batchResult
+	var argss [][]string
+	for _, line := range lines {
+		args, err := splitCommandLine(line)
+		if err != nil {
+			return NewUsageError(fmt.Errorf("%q: %w", line, err))
+		}
+		if len(args) == 0 {
+			return NewUsageError(fmt.Errorf("%q: empty command", line))
+		}
+		argss = append(argss, args)
+		results = append(results, &batchResult{line: line})
+	}
+	indexes := make([]int, len(results))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	parallel := b.Parallel
+	if !b.top.isolated() {
+		parallel = 1
+	}
+	err := ForEach(ctx, indexes, parallel, func(ctx context.Context, i int) error {
+		r := results[i]
+		inv := &invocation{
+			stdin:  strings.NewReader(""),
+			stdout: &r.stdout,
+			stderr: &r.stderr,
+			fresh:  true,
+		}
+		err := b.top.Run(withInvocation(ctx, inv), argss[i])
+		if err == nil {
+			err = inv.strictErr()
+		}
+		if r.code = exitCode(err, &r.stderr, false); r.code != 0 {
+			return fmt.Errorf("%s: exit code %d", r.line, r.code)
+		}
+		return nil
+	})
+	stdout, stderr := Stdout(ctx), Stderr(ctx)
+	for _, r := range results {
+		fmt.Fprintf(stdout, "$ %s %s\n", b.top.Name, r.line)
+		if _, err := stdout.Write(r.stdout.Bytes()); err != nil {
+			return err
+		}
+		if _, err := stderr.Write(r.stderr.Bytes()); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// readFile returns the command lines in b.File.
+func (b *batchCmd) readFile(ctx context.Context) ([]string, error) {
+	var r io.Reader
+	if b.File == "-" {
+		r = Stdin(ctx)
+	} else {
+		f, err := os.Open(b.File)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var lines []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, s.Err()
+}
+
+// splitCommandLine splits line into words as a POSIX shell would, without
+// expansions. Single quotes preserve everything within them; within double
+// quotes and outside quotes, a backslash escapes the next character.
+func splitCommandLine(line string) ([]string, error) {
+	var (
+		words []string
+		word  strings.Builder
+		in    bool // in a word
+		quote rune // the open quote, or 0
+	)
+	rs := []rune(line)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			if i+1 == len(rs) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			word.WriteRune(rs[i])
+			in = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			in = true
+		case r == ' ' || r == '\t':
+			if in {
+				words = append(words, word.String())
+				word.Reset()
+				in = false
+			}
+		default:
+			word.WriteRune(r)
+			in = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if in {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type shouter struct {
+	Loud bool     `cli:"flag=loud, shout"`
+	Fail bool     `cli:"flag=fail, fail"`
+	Word []string `cli:"name=WORD, words"`
+}
+
+func (s *shouter) Run(ctx context.Context) error {
+	if s.Fail {
+		return errors.New("failed")
+	}
+	w := strings.Join(s.Word, " ")
+	if s.Loud {
+		w = strings.ToUpper(w)
+	}
+	fmt.Fprintln(Stdout(ctx), w)
+	return nil
+}
+
+func TestBatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cmds")
+	if err := os.WriteFile(file, []byte("# comment\n\nsay c\n  say -loud 'd e'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	top := initFlags(&Command{Name: "prog"})
+	top.AddDryRunFlag()
+	top.Command("say", &shouter{}, "say words")
+	top.AddBatchCommand()
+
+	stdout, stderr, code := top.ExecuteCapture(context.Background(),
+		"batch", "-parallel", "2", "-f", file, "say -loud a", "-dry-run say b")
+	want := "$ prog say -loud a\nA\n$ prog -dry-run say b\nb\n$ prog say c\nc\n$ prog say -loud 'd e'\nD E\n"
+	if stdout != want || stderr != "" || code != 0 {
+		t.Errorf("got (%q, %q, %d), want (%q, \"\", 0)", stdout, stderr, code, want)
+	}
+
+	_, stderr, code = top.ExecuteCapture(context.Background(), "batch", "say a", "say -fail b")
+	if !strings.Contains(stderr, "1 of 2 failed") || code != 3 {
+		t.Errorf("one failure: got (%q, %d), want 1 of 2 failed and code 3", stderr, code)
+	}
+	_, stderr, code = top.ExecuteCapture(context.Background(), "batch", "say 'a")
+	if !strings.Contains(stderr, "unterminated") || code != 2 {
+		t.Errorf("bad line: got (%q, %d), want unterminated and code 2", stderr, code)
+	}
+
+	// With a flag that all runs share, the commands run one at a time.
+	var shared bool
+	top.flags.BoolVar(&shared, "shared", false, "shared")
+	stdout, stderr, code = top.ExecuteCapture(context.Background(),
+		"batch", "-parallel", "3", "-shared say a", "-shared say b", "say c")
+	if want := "$ prog -shared say a\na\n$ prog -shared say b\nb\n$ prog say c\nc\n"; stdout != want || code != 0 {
+		t.Errorf("shared: got (%q, %q, %d), want (%q, \"\", 0)", stdout, stderr, code, want)
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  a  b\tc ", []string{"a", "b", "c"}},
+		{`a 'b c' "d e"`, []string{"a", "b c", "d e"}},
+		{`'it''s' "say \"hi\"" a\ b ''`, []string{"its", `say "hi"`, "a b", ""}},
+		{`'a\b'`, []string{`a\b`}},
+	} {
+		got, err := splitCommandLine(test.in)
+		if err != nil {
+			t.Fatalf("%q: %v", test.in, err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%q: mismatch (-want, +got):\n%s", test.in, diff)
+		}
+	}
+	for _, in := range []string{`'a`, `"a`, `a\`} {
+		if _, err := splitCommandLine(in); err == nil {
+			t.Errorf("%q: got nil, want error", in)
+		}
+	}
+}
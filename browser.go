@@ -0,0 +1,119 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	neturl "net/url"
+	"os/exec"
+	"runtime"
+)
+
+// Opening URLs in a web browser.
+
+// AddNoInputFlag adds a boolean flag named "no-input" to c. When it is set,
+// the functions of this package that interact with the user, like Select and
+// OpenURL, behave as they do when standard input is not a terminal. It is
+// typically called on the top-level command.
+func (c *Command) AddNoInputFlag() {
+	c.noInput = new(bool)
+	c.flags.BoolVar(c.noInput, "no-input", false, "never prompt or open a browser")
+}
+
+// Interactive reports whether the command can interact with the user: its
+// standard input is a terminal, and the flag added by AddNoInputFlag, if any,
+// is not set.
+func Interactive(ctx context.Context) bool {
+	inv := invocationOrDefault(ctx)
+	for c := inv.cmd; c != nil; c = c.super {
+		if c.noInput != nil && *c.noInput {
+			return false
+		}
+	}
+	return isTerminal(inv.stdin)
+}
+
+// ErrNotInteractive is the error of a command with RequiresTTY set that is
+// run when it is not Interactive.
+var ErrNotInteractive = errors.New("requires an interactive terminal")
+
+// checkInteractive returns an error wrapping ErrNotInteractive if c requires
+// a terminal and the invocation is not Interactive.
+func (c *Command) checkInteractive(ctx context.Context) error {
+	if !c.RequiresTTY || Interactive(ctx) {
+		return nil
+	}
+	if c.NonInteractiveHint != "" {
+		return fmt.Errorf("%s: %w; %s", c.Path(), ErrNotInteractive, c.NonInteractiveHint)
+	}
+	return fmt.Errorf("%s: %w", c.Path(), ErrNotInteractive)
+}
+
+// OpenURL shows url to the user in a web browser, as a command that logs in
+// through a web page might. If the command is Interactive, OpenURL asks the
+// user to press Enter, then opens the browser. Otherwise, or if the browser
+// can't be opened, it writes url to standard error for the user to open.
+// OpenURL returns an error for a URL whose scheme is not http or https, since
+// the system's opener would run or show other kinds of URL, like local files,
+// in other ways.
+func OpenURL(ctx context.Context, url string) error {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("OpenURL: %q is not an http or https URL", url)
+	}
+	inv := invocationOrDefault(ctx)
+	if !Interactive(ctx) {
+		_, err := fmt.Fprintf(inv.stderr, "Open this URL in your browser:\n\n  %s\n\n", url)
+		return err
+	}
+	fmt.Fprintf(inv.stderr, "Press Enter to open %s in your browser...", url)
+	if _, err := readLine(inv.stdin); err != nil && err != io.EOF {
+		return err
+	}
+	if err := openBrowser(url); err != nil {
+		_, err := fmt.Fprintf(inv.stderr, "Could not open a browser (%v). Open this URL instead:\n\n  %s\n\n", err, url)
+		return err
+	}
+	return nil
+}
+
+// readLine reads from r up to and including the next newline, and returns
+// what it read. It reads a byte at a time so that it doesn't consume any
+// input after the line, which the command may read later.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			line = append(line, b[0])
+			if b[0] == '\n' {
+				return string(line), nil
+			}
+		}
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
+
+// openBrowser opens url with the system's web browser. It is a variable for
+// testing.
+var openBrowser = func(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Run()
+}
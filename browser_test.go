@@ -0,0 +1,138 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+type loginer struct{}
+
+func (loginer) Run(ctx context.Context) error {
+	return OpenURL(ctx, "https://example.com/login")
+}
+
+func TestOpenURL(t *testing.T) {
+	var opened []string
+	defer func(f func(string) error) { openBrowser = f }(openBrowser)
+	openBrowser = func(url string) error {
+		opened = append(opened, url)
+		return nil
+	}
+	// A file that looks like a terminal.
+	tty, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tty.Close()
+	if !isTerminal(tty) {
+		t.Skipf("%s is not a character device", os.DevNull)
+	}
+
+	for _, test := range []struct {
+		stdin   io.Reader
+		args    []string
+		wantOut string
+		open    bool
+	}{
+		{tty, nil, "Press Enter to open https://example.com/login", true},
+		{tty, []string{"-no-input"}, "Open this URL in your browser:\n\n  https://example.com/login", false},
+		{strings.NewReader(""), nil, "Open this URL in your browser", false},
+	} {
+		opened = nil
+		top := &Command{Name: "prog"}
+		initFlags(top)
+		top.AddNoInputFlag()
+		top.Command("login", &loginer{}, "")
+		var stderr bytes.Buffer
+		ctx := withInvocation(context.Background(), &invocation{stdin: test.stdin, stderr: &stderr})
+		if err := top.Run(ctx, append(test.args, "login")); err != nil {
+			t.Fatal(err)
+		}
+		if got := stderr.String(); !strings.Contains(got, test.wantOut) {
+			t.Errorf("%v: got %q, want it to contain %q", test.args, got, test.wantOut)
+		}
+		if got := len(opened) > 0; got != test.open {
+			t.Errorf("%v: opened browser: got %t, want %t", test.args, got, test.open)
+		}
+	}
+}
+
+func TestOpenURLScheme(t *testing.T) {
+	defer func(f func(string) error) { openBrowser = f }(openBrowser)
+	openBrowser = func(url string) error {
+		t.Errorf("opened %q", url)
+		return nil
+	}
+	ctx := withInvocation(context.Background(), &invocation{stdin: strings.NewReader(""), stderr: io.Discard})
+	for _, url := range []string{"file:///etc/passwd", "javascript:alert(1)", "example.com"} {
+		if err := OpenURL(ctx, url); err == nil {
+			t.Errorf("%q: got nil, want error", url)
+		}
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	r := strings.NewReader("\nrest\n")
+	if line, err := readLine(r); line != "\n" || err != nil {
+		t.Errorf("got (%q, %v), want (\"\\n\", nil)", line, err)
+	}
+	// The rest of the input is still there for the command.
+	if rest, _ := io.ReadAll(r); string(rest) != "rest\n" {
+		t.Errorf("rest: got %q, want %q", rest, "rest\n")
+	}
+	if line, err := readLine(r); line != "" || err != io.EOF {
+		t.Errorf("at EOF: got (%q, %v), want (\"\", EOF)", line, err)
+	}
+}
+
+func TestRequiresTTY(t *testing.T) {
+	defer func(f func(string) error) { openBrowser = f }(openBrowser)
+	openBrowser = func(string) error { return nil }
+	tty, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tty.Close()
+	if !isTerminal(tty) {
+		t.Skipf("%s is not a character device", os.DevNull)
+	}
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	top.AddNoInputFlag()
+	login := top.Command("login", &loginer{}, "")
+	login.RequiresTTY = true
+	login.NonInteractiveHint = "set $TOKEN instead"
+
+	for _, test := range []struct {
+		stdin   io.Reader
+		args    []string
+		wantErr string
+	}{
+		{tty, nil, ""},
+		{tty, []string{"-no-input"}, "prog login: requires an interactive terminal; set $TOKEN instead"},
+		{strings.NewReader(""), nil, "prog login: requires an interactive terminal; set $TOKEN instead"},
+	} {
+		var stderr bytes.Buffer
+		ctx := withInvocation(context.Background(), &invocation{stdin: test.stdin, stderr: &stderr})
+		err := top.Run(ctx, append(test.args, "login"))
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%v: %v", test.args, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.wantErr || !errors.Is(err, ErrNotInteractive) {
+			t.Errorf("%v: got %v, want %q", test.args, err, test.wantErr)
+		}
+		if stderr.Len() > 0 {
+			t.Errorf("%v: command ran: %q", test.args, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Defining flags and arguments without struct tags.
+
+// A ParamBuilder defines a flag or positional argument of a command from a
+// sequence of method calls, as an alternative to a struct tag. Create one
+// with Command.Arg or Command.Flag, call methods to configure it, and finish
+// with Bind. Each method corresponds to a struct tag key and has the same
+// meaning.
+//
+// For example,
+//
+//	c.Flag("env").Doc("environment").OneOf("dev", "prod").Bind(&s.Env)
+//
+// is equivalent to the tag
+//
+//	Env string `cli:"flag=env, oneof=dev|prod, environment"`
+//
+// Flags and arguments defined this way can be mixed with those defined by the
+// command's Struct. They follow them in order.
+type ParamBuilder struct {
+	c    *Command
+	name string
+	tag  map[string]string
+}
+
+// Arg starts the definition of a positional argument of c with the given
+// display name.
+func (c *Command) Arg(name string) *ParamBuilder {
+	return &ParamBuilder{c: c, name: name, tag: map[string]string{"name": name}}
+}
+
+// Flag starts the definition of a flag of c with the given name. Additional
+// names are aliases.
+func (c *Command) Flag(name string, aliases ...string) *ParamBuilder {
+	return &ParamBuilder{c: c, name: name, tag: map[string]string{"flag": strings.Join(append([]string{name}, aliases...), "|")}}
+}
+
+// Doc sets the usage string.
+func (b *ParamBuilder) Doc(doc string) *ParamBuilder { return b.set("doc", doc) }
+
+// OneOf restricts the value to the given choices.
+func (b *ParamBuilder) OneOf(choices ...string) *ParamBuilder {
+	return b.set("oneof", strings.Join(choices, "|"))
+}
+
+// Optional makes the argument, and all following ones, optional.
+func (b *ParamBuilder) Optional() *ParamBuilder { return b.set("opt", "") }
+
+// Min sets the minimum number of values for a slice argument.
+func (b *ParamBuilder) Min(n int) *ParamBuilder { return b.set("min", strconv.Itoa(n)) }
+
+// MinVal sets the smallest allowed value of a number or duration.
+func (b *ParamBuilder) MinVal(v string) *ParamBuilder { return b.set("minval", v) }
+
+// MaxVal sets the largest allowed value of a number or duration.
+func (b *ParamBuilder) MaxVal(v string) *ParamBuilder { return b.set("maxval", v) }
+
+// Match requires string values to match the regular expression re. If msg is
+// not empty, it is the error message for a value that doesn't match.
+func (b *ParamBuilder) Match(re, msg string) *ParamBuilder {
+	b.set("match", re)
+	if msg != "" {
+		b.set("matchmsg", msg)
+	}
+	return b
+}
+
+// Normalize applies the named normalizers, like "trim" or "lower", to string
+// values.
+func (b *ParamBuilder) Normalize(names ...string) *ParamBuilder {
+	return b.set("normalize", strings.Join(names, "|"))
+}
+
+// Xor puts the flag in a group of mutually exclusive flags.
+func (b *ParamBuilder) Xor(group string) *ParamBuilder { return b.set("xor", group) }
+
+// Requires makes it an error to set the flag without the given flags.
+func (b *ParamBuilder) Requires(flags ...string) *ParamBuilder {
+	return b.set("requires", strings.Join(flags, "|"))
+}
+
+// Count makes an integer flag count the number of times it appears.
+func (b *ParamBuilder) Count() *ParamBuilder { return b.set("count", "") }
+
+// Deprecated marks the flag as deprecated, with a message saying what to use
+// instead.
+func (b *ParamBuilder) Deprecated(msg string) *ParamBuilder { return b.set("deprecated", msg) }
+
+func (b *ParamBuilder) set(key, value string) *ParamBuilder {
+	b.tag[key] = value
+	return b
+}
+
+// Bind finishes the definition, associating the flag or argument with the
+// variable that p points to. Bind panics if the definition is invalid, as
+// Register does.
+func (b *ParamBuilder) Bind(p interface{}) {
+	if err := b.bind(p); err != nil {
+		panic(fmt.Sprintf("command %q, %q: %v", b.c.Name, b.name, err))
+	}
+}
+
+func (b *ParamBuilder) bind(p interface{}) error {
+	v := reflect.ValueOf(p)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("Bind: need a non-nil pointer, not %T", p)
+	}
+	if b.name == "" {
+		return errors.New("empty name")
+	}
+	// The field name is used for error messages and default names.
+	sf := reflect.StructField{Name: b.name, Type: v.Type().Elem()}
+	if err := b.c.processTagMap(b.tag, sf, v.Elem(), ""); err != nil {
+		return err
+	}
+	return b.c.checkFields()
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+type built struct {
+	Verbose bool `cli:"flag=v, verbose"`
+}
+
+func (b *built) Run(context.Context) error { return nil }
+
+func TestParamBuilder(t *testing.T) {
+	b := &built{}
+	var (
+		env   string
+		level int
+		files []string
+	)
+	top := Top(nil)
+	c := top.Command("b", b, "")
+	c.Flag("env", "e").Doc("environment").OneOf("dev", "prod").Bind(&env)
+	c.Flag("level").MinVal("1").MaxVal("3").Bind(&level)
+	c.Arg("FILE").Doc("files").Min(1).Bind(&files)
+	c.flags.SetOutput(io.Discard)
+
+	if err := top.Run(context.Background(), []string{"b", "-v", "-e", "prod", "-level", "2", "x", "y"}); err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprintf("%t %s %d %v", b.Verbose, env, level, files)
+	if want := "true prod 2 [x y]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := c.usageHeader(), " b [flags] FILE..."; !strings.HasSuffix(got, want) {
+		t.Errorf("header: got %q, want suffix %q", got, want)
+	}
+
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"b", "-env", "qa", "x"}, "must be one of"},
+		{[]string{"b", "-level", "4", "x"}, "must be between 1 and 3"},
+		{[]string{"b"}, "need at least 1 argument"},
+	} {
+		err := top.Run(context.Background(), test.args)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%v: got %v, want error containing %q", test.args, err, test.want)
+		}
+	}
+
+	var n int
+	if err := c.Arg("N").bind(&n); err == nil {
+		t.Error("arg after slice: got nil, want error")
+	}
+	if err := c.Flag("x").Count().bind(new(string)); err == nil {
+		t.Error("count on string: got nil, want error")
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+)
+
+// Checking for cancellation.
+
+// ErrInterrupted is returned by CheckCancel and Each when the context was
+// canceled, as it is when the user interrupts the program and
+// Command.HandleSignals is set. Main exits with status 130 when a command
+// fails with it. It matches context.Canceled with errors.Is.
+var ErrInterrupted error = interruptedError{}
+
+type interruptedError struct{}
+
+func (interruptedError) Error() string { return "interrupted" }
+func (interruptedError) Unwrap() error { return context.Canceled }
+
+// CheckCancel returns nil if ctx is not done. Otherwise it returns
+// ErrInterrupted if ctx was canceled, or ctx.Err() if its deadline passed.
+// Commands that do long stretches of work without blocking on I/O should
+// call it often, so that they stop promptly when asked:
+//
+//	for _, x := range items {
+//		if err := cli.CheckCancel(ctx); err != nil {
+//			return err
+//		}
+//		...
+//	}
+func CheckCancel(ctx context.Context) error {
+	switch err := ctx.Err(); err {
+	case nil:
+		return nil
+	case context.Canceled:
+		return ErrInterrupted
+	default:
+		return err
+	}
+}
+
+// Each calls fn on each item in order, stopping at the first error.
+// Before each call it checks ctx with CheckCancel, and returns that error if
+// ctx is done. Use ForEach to process items in parallel, or to keep going
+// after a failure.
+func Each[T any](ctx context.Context, items []T, fn func(T) error) error {
+	for _, item := range items {
+		if err := CheckCancel(ctx); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
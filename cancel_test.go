@@ -0,0 +1,62 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckCancel(t *testing.T) {
+	ctx := context.Background()
+	if err := CheckCancel(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err := CheckCancel(cctx)
+	if !errors.Is(err, ErrInterrupted) || !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled: got %v, want ErrInterrupted and context.Canceled", err)
+	}
+
+	dctx, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	if err := CheckCancel(dctx); !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrInterrupted) {
+		t.Errorf("deadline: got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestEach(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var got []int
+	err := Each(ctx, []int{1, 2, 3, 4}, func(i int) error {
+		got = append(got, i)
+		if i == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, ErrInterrupted) {
+		t.Errorf("got %v, want ErrInterrupted", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("got calls on %v, want [1 2]", got)
+	}
+
+	errStop := errors.New("stop")
+	got = nil
+	err = Each(context.Background(), []int{1, 2, 3}, func(i int) error {
+		got = append(got, i)
+		if i == 1 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop || len(got) != 1 {
+		t.Errorf("got %v after %v, want %v after [1]", err, got, errStop)
+	}
+}
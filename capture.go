@@ -0,0 +1,86 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"reflect"
+	"strings"
+)
+
+// Running commands in tests.
+
+// ExecuteCapture runs c, which is normally the top-level command, with args
+// as Main would, and returns what it wrote to its standard output and
+// standard error along with the exit code that Main would return. It is
+// meant for tests:
+//
+//	stdout, stderr, code := top.ExecuteCapture(ctx, "list", "-limit", "3")
+//
+// ExecuteCapture runs each command on new copies of its struct and bundles,
+// made from the registered ones, as if c were Reentrant. So each call starts
+// from the values the commands were registered with, however earlier calls
+// went, and calls can run concurrently. But flags and arguments that the
+// copies share, like those added to a flag set directly, keep their values
+// from one call to the next, and calls that set them can interfere. Standard
+// input is c.Stdin, or empty if that is nil.
+func (c *Command) ExecuteCapture(ctx context.Context, args ...string) (stdout, stderr string, code int) {
+	var out, errw bytes.Buffer
+	inv := &invocation{stdin: c.Stdin, stdout: &out, stderr: &errw, fresh: true}
+	if inv.stdin == nil {
+		inv.stdin = strings.NewReader("")
+	}
+	err := c.Run(withInvocation(ctx, inv), args)
+	if err == nil {
+		err = inv.strictErr()
+	}
+	code = exitCode(err, &errw, false)
+	return out.String(), errw.String(), code
+}
+
+// renewFlags gives c a new flag set for parsing, with the same flags and
+// values as c.flags but no record of the flags set by an earlier parse.
+// c.flags itself is kept, because it may be flag.CommandLine, which the
+// program can still use.
+func (c *Command) renewFlags() {
+	fs := flag.NewFlagSet(c.flags.Name(), flag.ContinueOnError)
+	c.flags.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+		fs.Lookup(f.Name).DefValue = f.DefValue
+	})
+	fs.SetOutput(c.flags.Output())
+	fs.Usage = func() {
+		c.flags.Usage()
+	}
+	c.parsed = fs
+}
+
+// cloneValue returns a copy of v that doesn't share maps or slices with it,
+// or with the exported fields of it and its nested structs, so that changes to
+// the contents of one don't affect the other.
+func cloneValue(v reflect.Value) reflect.Value {
+	c := reflect.New(v.Type()).Elem()
+	switch {
+	case v.Kind() == reflect.Struct:
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(cloneValue(v.Field(i)))
+			}
+		}
+	case v.Kind() == reflect.Map && !v.IsNil():
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), iter.Value())
+		}
+		c.Set(m)
+	case v.Kind() == reflect.Slice && !v.IsNil():
+		c.Set(reflect.AppendSlice(reflect.MakeSlice(v.Type(), 0, v.Len()), v))
+	default:
+		c.Set(v)
+	}
+	return c
+}
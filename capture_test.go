@@ -0,0 +1,83 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type tagger struct {
+	Tags    []string          `cli:"flag=tag, accumulate=, tags"`
+	Labels  map[string]string `cli:"flag=label, labels"`
+	Verbose int               `cli:"flag=v, count=, verbosity"`
+	Name    string            `cli:"opt=, name"`
+}
+
+func (g *tagger) Run(ctx context.Context) error {
+	fmt.Fprintf(Stdout(ctx), "%v %v %d %q\n", g.Tags, g.Labels, g.Verbose, g.Name)
+	return nil
+}
+
+func TestExecuteCapture(t *testing.T) {
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	quiet := top.flags.Bool("quiet", false, "")
+	tg := &tagger{Tags: []string{"x"}, Labels: map[string]string{"a": "1"}}
+	top.Command("tag", tg, "add tags")
+
+	for _, test := range []struct {
+		args     []string
+		wantOut  string
+		wantErr  string
+		wantCode int
+	}{
+		{[]string{"-quiet", "tag", "-tag", "y", "-tag", "z", "-label", "b=2", "-v", "-v", "n"}, `[y z] map[b:2] 2 "n"`, "", 0},
+		// Nothing carries over from the previous run.
+		{[]string{"tag"}, `[x] map[a:1] 0 ""`, "", 0},
+		{[]string{"tag", "-tag", "w"}, `[w] map[a:1] 0 ""`, "", 0},
+		{[]string{"tag", "-nosuch"}, "", "flag provided but not defined", 2},
+		{[]string{"tag", "-h"}, "", "Usage", 0},
+		{[]string{"frob"}, "", "unknown command", 2},
+	} {
+		stdout, stderr, code := top.ExecuteCapture(context.Background(), test.args...)
+		if got := strings.TrimSpace(stdout); got != test.wantOut {
+			t.Errorf("%v: stdout = %q, want %q", test.args, got, test.wantOut)
+		}
+		if !strings.Contains(stderr, test.wantErr) {
+			t.Errorf("%v: stderr = %q, want it to contain %q", test.args, stderr, test.wantErr)
+		}
+		if code != test.wantCode {
+			t.Errorf("%v: code = %d, want %d", test.args, code, test.wantCode)
+		}
+	}
+	// The registered struct is not changed, but a flag that all runs share
+	// keeps its value.
+	if got := fmt.Sprint(tg.Tags, tg.Labels, tg.Verbose, tg.Name); got != "[x] map[a:1] 0" {
+		t.Errorf("registered struct: got %s", got)
+	}
+	if !*quiet {
+		t.Error("shared flag: got false, want true")
+	}
+}
+
+func TestExecuteCaptureConcurrent(t *testing.T) {
+	top := initFlags(&Command{Name: "prog"})
+	top.Command("tag", &tagger{Tags: []string{"x"}}, "add tags")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stdout, stderr, _ := top.ExecuteCapture(context.Background(), "tag", "-tag", fmt.Sprint(i))
+			if got, want := strings.TrimSpace(stdout), fmt.Sprintf(`[%d] map[] 0 ""`, i); got != want {
+				t.Errorf("got %q, want %q (stderr %q)", got, want, stderr)
+			}
+		}()
+	}
+	wg.Wait()
+}
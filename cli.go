@@ -11,6 +11,8 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"math"
+	"os"
 	"reflect"
 	"strings"
 )
@@ -23,6 +25,11 @@ type Command struct {
 	// A short string describing the command.
 	Usage string
 
+	// A longer description of the command, in one or more paragraphs
+	// separated by blank lines. It appears in the command's usage message
+	// after the line with Usage.
+	Long string
+
 	// If not nil, then a pointer to a struct with some exported fields.
 	// Each exported field is either a flag or an argument for the command,
 	// as determined by the struct tag for the field.
@@ -32,38 +39,324 @@ type Command struct {
 	// If the struct pointer has a method Before(context.Context) error,
 	// it is called before arguments and sub-commands are processed. Flags
 	// will have been parsed.
+	// If the struct pointer has a method Default(context.Context) error, it is
+	// called after all flags and arguments have been bound, just before Run.
+	// It is the place to compute defaults that depend on other fields.
+	// If the struct pointer has a method Validate() error, it is called after
+	// Default. A non-nil error is reported as a UsageError. It is the place to
+	// check constraints among fields.
+	// If the struct pointer has a method After(context.Context, error) error,
+	// it is called after Run with the error Run returned, and its own
+	// error becomes the command's. It is the place to release resources or
+	// report results. It is called exactly when Run is: not if the command
+	// fails before that, as in a Before, Default or Validate method or a
+	// BeforeRun hook, and not for a group without a Run method.
 	Struct interface{}
 
-	flags   *flag.FlagSet
-	formals []*formal
-	super   *Command
-	subs    []*Command
+	// If true, the command interacts with the user, so it fails at once with
+	// ErrNotInteractive when it is not Interactive, as when it is run in a
+	// pipeline or by a CI system, instead of waiting for input that won't
+	// come. NonInteractiveHint, if set, is added to the error message to say
+	// how to get the same result without a terminal, as in
+	// "pass the token with -token instead".
+	RequiresTTY        bool
+	NonInteractiveHint string
+
+	// If non-empty, the command is deprecated. Using it prints a warning
+	// with this message, which should say what to use instead.
+	Deprecated string
+
+	// The standard input, output and error of the command and its
+	// sub-commands, if they don't set their own. If nil, they are those of the
+	// command above, or os.Stdin, os.Stdout and os.Stderr for the top-level
+	// command. All of the package's output, like usage messages and errors
+	// from Main, goes to them, as should the output of commands; see Stdout.
+	// Setting them lets a program be tested without replacing the os streams.
+	// (Completion with COMP_LINE is an exception: it always writes to
+	// os.Stdout.)
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Examples of the command's use. They appear in the command's usage
+	// message, and RunExamples checks that they produce the output they
+	// claim.
+	Examples []Example
+
+	// Named sets of choices that the struct tags of this command and its
+	// sub-commands can refer to with "oneof=@name", from name to choices.
+	// Set it in the command passed to Top so the top-level command's own
+	// fields can use them; see also DefineChoices.
+	Choices map[string][]string
+
+	// If true, the arguments to Run may form a pipeline of commands separated
+	// by "|" arguments, as in
+	//
+	//	prog list -all '|' filter -v
+	//
+	// Each command's standard output is connected to the next command's
+	// standard input, so a command that writes its results with WriteJSON can
+	// feed a command with a field tagged "stdin=json".
+	// This feature is experimental.
+	Pipelines bool
+
+	// If true, the sub-commands of this command's sub-commands can also be
+	// invoked with the two names reversed. For example, if the command has a
+	// sub-command "students" that has a sub-command "list", then
+	//
+	//	prog list students
+	//
+	// is the same as "prog students list". That supports both "object verb" and
+	// "verb object" grammars with one set of definitions. If the first name is
+	// itself a sub-command of this command, it takes precedence.
+	Transposable bool
+
+	// If true, a sub-command of this command or of its sub-commands can be
+	// invoked with any prefix of its name that no other sub-command of the same
+	// command shares. For example, "prog sta" runs "prog status" if there is
+	// no "prog start".
+	PrefixMatch bool
+
+	// If true, ForEach stops at the first failure when called from this
+	// command or its sub-commands.
+	FailFast bool
+
+	// If true, the command can be invoked directly by its name, as in
+	// busybox-style multi-call programs. When the program is run under the
+	// command's name, typically through a symbolic link, Main behaves as if the
+	// path to the command had been given on the command line. Such programs
+	// should give the top-level command an explicit Name.
+	Applet bool
+
+	// If true, each argument to this command of the form @FILE, like
+	// "@args.txt", is replaced by the arguments in FILE, as with many
+	// compilers. That helps when a command line would be too long for the
+	// operating system. The arguments in the file are separated by white
+	// space or newlines, and can be quoted as in a shell. An argument that
+	// begins with "@@" stands for itself without the first "@". Arguments
+	// after "--", and those in the file, are not expanded. Usually only the
+	// top-level command sets this field.
+	ResponseFiles bool
+
+	// The heading under which the command is listed in the usage message of
+	// the command above it, like "Management Commands". Commands without a
+	// Category are listed under "Commands".
+	Category string
+
+	// The order in which the categories of this command's sub-commands are
+	// listed in its usage message. Sub-commands without a category come
+	// first, then those in the categories named here, then the rest in the
+	// order their categories first appear.
+	CategoryOrder []string
+
+	// Whether usage messages and errors of this command and its sub-commands
+	// are styled with color and emphasis. The zero value, ColorAuto, styles
+	// them when they are written to a terminal, unless the NO_COLOR
+	// environment variable is set. Usually only the top-level command sets
+	// this field.
+	Color ColorMode
+
+	// HelpDepth and HelpBreadth limit the list of sub-commands in the usage
+	// message of this command and its sub-commands, to keep it usable for
+	// large trees. HelpDepth is the number of levels of sub-commands listed;
+	// zero means one. If HelpBreadth is positive, at most that many
+	// sub-commands of each command are listed. The implicit help command
+	// lists them all when given the -all flag, as in "prog help -all".
+	HelpDepth, HelpBreadth int
+
+	// The order in which the usage messages of this command and its
+	// sub-commands list their arguments and flags.
+	HelpOrder HelpOrder
+
+	// The width in columns to which the descriptions of arguments, flags and
+	// sub-commands are wrapped in usage messages of this command and its
+	// sub-commands. If zero, it is the width of the terminal the message is
+	// written to, which the COLUMNS environment variable overrides; messages
+	// not written to a terminal are not wrapped. If negative, messages are
+	// never wrapped.
+	HelpWidth int
+
+	// If true, the first line of a usage error from this command or its
+	// sub-commands has the form
+	//
+	//	usage-error/VERSION: PATH: MESSAGE
+	//
+	// where VERSION is ParseableErrorsVersion, PATH is the command's path, as
+	// in "prog sub", and MESSAGE is the error message with newlines replaced
+	// by spaces. The usage message follows on subsequent lines as usual.
+	// Programs that wrap the command can rely on the format of a given
+	// version not changing.
+	ParseableErrors bool
+
+	// If true, usage messages for this command and its sub-commands are
+	// written for screen readers: each part is on its own labeled line, and
+	// arguments are described with words like "required" and "optional"
+	// instead of punctuation. Setting the environment variable ACCESSIBLE to
+	// a non-empty value has the same effect for all commands.
+	Accessible bool
+
+	// If non-nil, writes the usage messages for this command and its
+	// sub-commands in place of the built-in layout, unless they are
+	// Accessible. See UsageTemplate.
+	UsageWriter UsageWriter
+
+	// If non-nil, VersionFormat writes the version information set with
+	// SetVersion, instead of the default one-line format. FormatVersionJSON
+	// writes it as JSON.
+	VersionFormat func(w io.Writer, v VersionInfo) error
+
+	// If true, the usage message asked for with -h or the help command is
+	// shown with a pager when it is too long for the terminal, as git does.
+	// The pager is the value of the PAGER environment variable, or "less" if
+	// it is unset; setting PAGER to "cat" or the empty string turns paging
+	// off.
+	PageHelp bool
+
+	// If true, Main cancels the context it passes to the command when the
+	// process receives an interrupt (SIGINT) or termination (SIGTERM) signal.
+	// If the command then fails, Main returns 130 or 143 respectively, as
+	// shells do, so that scripts can tell interruption from failure.
+	// Only the top-level command's setting matters.
+	HandleSignals bool
+
+	// By default, flags of a command without sub-commands can follow its
+	// positional arguments, as with GNU tools: "prog copy src dst -v" sets
+	// -v. An argument of "--" ends the flags. If StrictOrder is true, the
+	// first positional argument ends the flags instead, so the rest are passed
+	// along untouched. That suits commands that run other programs with
+	// their own flags. An argument that starts with "-" but has a space in
+	// its flag name, like a quoted command line, is positional.
+	StrictOrder bool
+
+	// If true, single-letter flags of this command and its sub-commands can be
+	// combined, as in POSIX tools: "-rf" means "-r -f", and the last flag of
+	// a group can take a value written right after it, so "-vn5" means
+	// "-v -n 5". A group is only split if all its letters are flags and it
+	// is not itself the name of a flag.
+	CombineShortFlags bool
+
+	// If true, each run of this command or its sub-commands uses new copies
+	// of the command's Struct and bundles, made from the registered ones,
+	// instead of the registered ones themselves. Exported fields of the
+	// Struct that hold its bundles, or pointers to them, hold the copies; tag
+	// them with `cli:"-"`. A command whose Struct holds a bundle in an
+	// unexported field fails to run. The flags that the package adds, like
+	// -strict and -dry-run, get new values too. So leftover values from one
+	// run can't affect the next, and a command tree can serve concurrent
+	// runs. Values bound with a ParamBuilder and flags added to a command's
+	// flag set in other ways are still shared, so runs that set them can
+	// interfere.
+	//
+	// Since the registered Struct is not modified, a Run method must use its
+	// receiver to get the values of its flags and arguments.
+	Reentrant bool
+
+	flags      *flag.FlagSet
+	parsed     *flag.FlagSet // if non-nil, parses the current run's flags; see renewFlags
+	runner     Runnable      // if non-nil, used instead of Struct to run the command
+	formals    []*formal
+	super      *Command
+	subs       []*Command
+	strict     bool          // value of the strict flag; see AddStrictFlag
+	flagSpecs  []*FlagSpec   // flags from Struct, in order of declaration
+	stdinField reflect.Value // field tagged "stdin=json", if any
+	raw        *formal       // field tagged "raw", if any
+	defaultSub string        // see Default
+	implicit   bool          // added by the package, as by SetVersion; see register
+
+	version     *VersionInfo  // see SetVersion
+	showVersion bool          // value of the version flag
+	checks      []check       // see AddCheck
+	copyFlag    *bool         // value of the copy flag, if added; see AddCopyFlag
+	dryRun      *bool         // value of the dry-run flag, if added; see AddDryRunFlag
+	noInput     *bool         // value of the no-input flag, if added; see AddNoInputFlag
+	bundles     []interface{} // passed to Register or added by methods like AddTransportFlags
+	bundleLinks []bundleLink  // where Struct holds each of bundles; see linkBundle
+	origin      *Command      // if this is an instance for a single run, the registered command
+	config      *configFile   // see LoadConfig
+	configFlag  *configFlag   // see AddConfigFlag
+
+	beforeRun  []func(context.Context, *Command) (context.Context, error) // see BeforeRun
+	middleware []func(RunFunc) RunFunc                                    // see Use
 }
 
 // A formal describes a positional argument.
 type formal struct {
-	name   string        // display name
-	field  reflect.Value // "pointer" to corresponding field
-	usage  string
-	min    int       // for last slice, minimum args needed
-	opt    bool      // if true, this and all following formals are optional
-	parser parseFunc // convert and/or validate
+	ArgSpec
+	field       reflect.Value // "pointer" to corresponding field
+	fieldName   string        // name of the struct field
+	parser      parseFunc     // convert and/or validate
+	flagsBefore int           // number of flags of the command declared before it
 }
 
+// A HelpOrder says in what order a usage message lists the arguments and
+// flags of a command.
+type HelpOrder int
+
+const (
+	// FlagsSorted lists the arguments, then the flags sorted by name, as the
+	// flag package does.
+	FlagsSorted HelpOrder = iota
+	// FlagsDeclared lists the arguments, then the flags in the order their
+	// fields are declared. Flags without fields, like those added to a flag
+	// set directly, come last, sorted by name.
+	FlagsDeclared
+	// Interleaved lists the arguments and flags together, in the order their
+	// fields are declared. Only the built-in layout interleaves them; the
+	// Args and Flags of a UsageInfo are separate, with the flags in declared
+	// order.
+	Interleaved
+)
+
 // A Runnable is a command that can be run.
 // See Command.Struct.
 type Runnable interface {
 	Run(ctx context.Context) error
 }
 
+// runnable returns the Runnable for c, and reports whether there is one.
+func (c *Command) runnable() (Runnable, bool) {
+	if c.runner != nil {
+		return c.runner, true
+	}
+	r, ok := c.Struct.(Runnable)
+	return r, ok
+}
+
+// A RunFunc is a function that runs a command.
+// It adapts a function to a Runnable.
+type RunFunc func(context.Context) error
+
+// Run implements Runnable.
+func (f RunFunc) Run(ctx context.Context) error { return f(ctx) }
+
 func (c *Command) validate() error {
 	// Check that c.c is either a Runnable, or has sub-commands.
-	if _, ok := c.Struct.(Runnable); !ok && len(c.subs) == 0 {
+	if _, ok := c.runnable(); !ok && len(c.subs) == 0 {
 		return fmt.Errorf("%s is not runnable and has no sub-commands", c.Name)
 	}
+	if c.defaultSub != "" {
+		if _, ok := c.runnable(); ok {
+			return fmt.Errorf("%s: a runnable command cannot have a default sub-command", c.Name)
+		}
+		if c.findSub(c.defaultSub) == nil {
+			return fmt.Errorf("%s: default sub-command %q does not exist", c.Name, c.defaultSub)
+		}
+	}
 	return nil
 }
 
+// Default makes the sub-command named name the default for c, a group of
+// commands. Invoking c without a sub-command runs the default with no
+// arguments, instead of failing.
+//
+// Running a command fails before anything runs if the default doesn't exist
+// or c is runnable, since a runnable command runs itself when invoked without
+// a sub-command.
+func (c *Command) Default(name string) {
+	c.defaultSub = name
+}
+
 func (c *Command) validateAll() error {
 	if err := c.validate(); err != nil {
 		return err
@@ -76,38 +369,619 @@ func (c *Command) validateAll() error {
 	return nil
 }
 
-func (c *Command) usage(w io.Writer, single bool) {
-	if single {
-		fmt.Fprintln(w, "Usage:")
+func (c *Command) usage(w io.Writer) {
+	c.writeUsage(w, c.helpStyle(w))
+}
+
+// writeUsage writes the usage message for c in the style st.
+func (c *Command) writeUsage(w io.Writer, st helpStyle) {
+	if c.accessible() {
+		c.accessibleUsage(w)
+		return
+	}
+	if uw := c.usageWriter(); uw != nil {
+		if err := uw.WriteUsage(w, c.usageInfo(st)); err != nil {
+			fmt.Fprintf(w, "writing usage: %v\n", err)
+		}
+		return
 	}
-	// If this is a group and we're only printing this and there are no flags, don't print a header.
-	printHeader := !(single && len(c.subs) > 0 && c.numFlags() == 0)
-	if printHeader {
-		h := c.usageHeader()
-		if single && len(h)+len(c.Usage) <= 76 {
-			fmt.Fprintf(w, "%s    %s\n", h, c.Usage)
+	fmt.Fprintln(w, "Usage:")
+	h := c.usageHeader()
+	limit := 76
+	if st.width > 0 {
+		limit = st.width - 4
+	}
+	fits := displayWidth(h)+displayWidth(c.Usage) <= limit
+	h = st.paint(styleBold, h)
+	switch {
+	case c.Usage == "":
+		fmt.Fprintln(w, h)
+	case fits:
+		fmt.Fprintf(w, "%s    %s\n", h, c.Usage)
+	default:
+		fmt.Fprintln(w, h)
+		writeWrapped(w, "  ", c.Usage, st.width)
+	}
+	if c.Deprecated != "" {
+		fmt.Fprintf(w, "  Deprecated: %s\n", c.Deprecated)
+	}
+	if c.Long != "" {
+		fmt.Fprintf(w, "\n%s\n", indent(c.Long, "  "))
+	}
+	c.printParams(w, st)
+	if len(c.Examples) > 0 {
+		fmt.Fprintln(w, "\nExamples:")
+		c.printExamples(w)
+	}
+	if len(c.subs) > 0 {
+		fmt.Fprintln(w)
+		c.printCommandIndex(w, st)
+	}
+}
+
+// helpWidth returns the width to which usage messages for c written to w
+// are wrapped, or 0 if they are not. See Command.HelpWidth.
+func (c *Command) helpWidth(w io.Writer) int {
+	for a := c; a != nil; a = a.super {
+		if a.HelpWidth > 0 {
+			return a.HelpWidth
+		}
+		if a.HelpWidth < 0 {
+			return 0
+		}
+	}
+	return terminalWidth(w)
+}
+
+// printExamples writes c's Examples, each with its Doc and command line.
+func (c *Command) printExamples(w io.Writer) {
+	for i, ex := range c.Examples {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		prefix := "  "
+		if ex.Doc != "" {
+			fmt.Fprintf(w, "  %s:\n", strings.TrimSuffix(ex.Doc, "."))
+			prefix = "    "
+		}
+		fmt.Fprintf(w, "%s$ %s\n", prefix, ex.commandLine(c.Path()))
+	}
+}
+
+// indent returns s with each of its non-empty lines prefixed by prefix, and
+// with a final newline.
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, l := range lines {
+		if l = strings.TrimRight(l, " \t"); l != "" {
+			l = prefix + l
+		}
+		lines[i] = l
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// printParams writes the descriptions of c's positional arguments and flags,
+// in the order given by c's HelpOrder.
+func (c *Command) printParams(w io.Writer, st helpStyle) {
+	if c.helpOrder() != Interleaved {
+		c.printArgs(w, st.width)
+		c.printFlags(w, st)
+		return
+	}
+	nameWidth := c.argNameWidth()
+	flags := c.orderedFlags()
+	i := 0 // index of the next flag in flags
+	for _, f := range c.formals {
+		for ; i < len(flags) && i < f.flagsBefore; i++ {
+			c.printFlag(w, flags[i], st)
+		}
+		printArg(w, f.ArgSpec, nameWidth, st.width)
+	}
+	for ; i < len(flags); i++ {
+		c.printFlag(w, flags[i], st)
+	}
+	if c.raw != nil {
+		printArg(w, c.raw.ArgSpec, nameWidth, st.width)
+	}
+}
+
+// printArgs writes a line for each of c's positional arguments that has a
+// usage string, with the usage strings aligned and wrapped to width.
+func (c *Command) printArgs(w io.Writer, width int) {
+	nameWidth := c.argNameWidth()
+	for _, f := range c.formals {
+		printArg(w, f.ArgSpec, nameWidth, width)
+	}
+	if c.raw != nil {
+		printArg(w, c.raw.ArgSpec, nameWidth, width)
+	}
+}
+
+// argNameWidth returns the width of the column of argument names written by
+// printArg.
+func (c *Command) argNameWidth() int {
+	nameWidth := 10
+	for _, f := range c.formals {
+		if f.Usage != "" {
+			nameWidth = max(nameWidth, displayWidth(f.Name))
+		}
+	}
+	if c.raw != nil && c.raw.Usage != "" {
+		nameWidth = max(nameWidth, displayWidth(c.raw.Name))
+	}
+	return nameWidth
+}
+
+// printArg writes a line for a, if it has a usage string, with its name
+// padded to nameWidth and its usage string wrapped to width.
+func printArg(w io.Writer, a ArgSpec, nameWidth, width int) {
+	if a.Usage != "" {
+		writeWrapped(w, "  "+padRight(a.Name, nameWidth)+" ", a.Usage, width)
+	}
+}
+
+// accessibleUsage writes the usage message for c in a form suited to screen
+// readers. See Command.Accessible.
+func (c *Command) accessibleUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s\n", c.Path())
+	if c.Usage != "" {
+		fmt.Fprintf(w, "Description: %s\n", c.Usage)
+	}
+	if c.Long != "" {
+		// One line, so it is read as one item.
+		fmt.Fprintf(w, "Details: %s\n", strings.Join(strings.Fields(c.Long), " "))
+	}
+	if c.Deprecated != "" {
+		fmt.Fprintf(w, "Deprecated: %s\n", c.Deprecated)
+	}
+	if _, ok := c.runnable(); !ok && len(c.subs) > 0 {
+		if c.defaultSub != "" {
+			fmt.Fprintf(w, "Command: optional, default %s\n", c.defaultSub)
 		} else {
-			fmt.Fprintf(w, "%s\n  %s\n", h, c.Usage)
+			fmt.Fprintln(w, "Command: required")
 		}
+	}
+	if len(c.formals) > 0 {
+		fmt.Fprintln(w, "Arguments:")
 		for _, f := range c.formals {
-			if f.usage != "" {
-				fmt.Fprintf(w, "  %-10s %s\n", f.name, f.usage)
+			fmt.Fprintf(w, "  %s, %s", f.Name, argRequirement(f.ArgSpec))
+			if f.Usage != "" {
+				fmt.Fprintf(w, ": %s", f.Usage)
 			}
+			fmt.Fprintln(w)
+		}
+	}
+	if c.numFlags() > 0 {
+		fmt.Fprintln(w, "Flags:")
+		c.printFlags(w, helpStyle{})
+	}
+	for _, ex := range c.Examples {
+		fmt.Fprint(w, "Example: ")
+		if ex.Doc != "" {
+			fmt.Fprintf(w, "%s: ", strings.TrimSuffix(ex.Doc, "."))
+		}
+		fmt.Fprintln(w, ex.commandLine(c.Path()))
+	}
+	if len(c.subs) > 0 {
+		c.printCommandIndex(w, helpStyle{})
+	}
+}
+
+// argRequirement describes in words whether the argument a must be provided,
+// and how many times.
+func argRequirement(a ArgSpec) string {
+	switch {
+	case !a.Variadic && a.Optional:
+		return "optional"
+	case !a.Variadic:
+		return "required"
+	case a.Min == 0 || a.Optional:
+		return "optional, any number"
+	default:
+		return fmt.Sprintf("required, at least %d", a.Min)
+	}
+}
+
+func (c *Command) accessible() bool {
+	if os.Getenv("ACCESSIBLE") != "" {
+		return true
+	}
+	for ; c != nil; c = c.super {
+		if c.Accessible {
+			return true
 		}
 	}
-	c.flags.SetOutput(w)
-	c.flags.PrintDefaults()
-	if single {
-		if printHeader && len(c.subs) > 0 {
+	return false
+}
+
+// printCommandIndex writes a list of c's sub-commands, one per line,
+// with their one-line usage strings aligned.
+func (c *Command) printCommandIndex(w io.Writer, st helpStyle) {
+	c.printCommandEntries(w, st)
+	fmt.Fprintf(w, "\n%s\n", c.commandHint(st))
+}
+
+// printCommandEntries writes c's command index: a heading for each category
+// of c's sub-commands, followed by their lines, in the style st.
+func (c *Command) printCommandEntries(w io.Writer, st helpStyle) {
+	depth, breadth := c.helpLimits()
+	if st.all {
+		depth, breadth = math.MaxInt32, 0
+	}
+	cats := c.categories()
+	entries := make([][]indexEntry, len(cats))
+	nameWidth := 0
+	for i, cat := range cats {
+		entries[i] = c.indexEntries(cat.subs, "", depth, breadth)
+		for _, e := range entries[i] {
+			nameWidth = max(nameWidth, displayWidth(e.name))
+		}
+	}
+	for i, cat := range cats {
+		if i > 0 {
 			fmt.Fprintln(w)
 		}
-		for i, s := range c.subs {
+		fmt.Fprintf(w, "%s:\n", cat.name)
+		for _, e := range entries[i] {
+			name := st.paint(styleBold, e.name)
+			if e.usage == "" {
+				fmt.Fprintf(w, "  %s\n", name)
+			} else {
+				writeWrapped(w, "  "+padRight(name, nameWidth)+"  ", e.usage, st.width)
+			}
+		}
+	}
+}
+
+// A category is a heading in the list of sub-commands, with the sub-commands
+// listed under it.
+type category struct {
+	name string
+	subs []*Command
+}
+
+// categories returns c's sub-commands by category, in the order they are
+// listed. See Command.CategoryOrder.
+func (c *Command) categories() []category {
+	var cats []category
+	index := map[string]int{} // from name to index in cats
+	add := func(name string) {
+		if _, ok := index[name]; !ok {
+			index[name] = len(cats)
+			cats = append(cats, category{name: name})
+		}
+	}
+	add("")
+	for _, name := range c.CategoryOrder {
+		add(name)
+	}
+	for _, s := range c.subs {
+		add(s.Category)
+		i := index[s.Category]
+		cats[i].subs = append(cats[i].subs, s)
+	}
+	var res []category
+	for _, cat := range cats {
+		if len(cat.subs) > 0 {
+			if cat.name == "" {
+				cat.name = "Commands"
+			}
+			res = append(res, cat)
+		}
+	}
+	return res
+}
+
+// commandHint returns a sentence telling the user how to get help on
+// c's sub-commands. If the list of them in the style st leaves some out
+// because of HelpBreadth, another sentence says how to list them all.
+func (c *Command) commandHint(st helpStyle) string {
+	var hint string
+	if c.hasHelpCommand() {
+		hint = fmt.Sprintf("Run \"%s help <command>\" for details about a command.", c.Path())
+	} else {
+		hint = fmt.Sprintf("Run \"%s <command> -h\" for details about a command.", c.Path())
+	}
+	if depth, breadth := c.helpLimits(); !st.all && c.indexOmits(depth, breadth) {
+		if all := c.helpAllCommand(); all != "" {
+			hint += fmt.Sprintf("\nRun \"%s\" to list all of them.", all)
+		}
+	}
+	return hint
+}
+
+// indexOmits reports whether the list of c's sub-commands to the given depth
+// leaves some out because there are more than breadth.
+func (c *Command) indexOmits(depth, breadth int) bool {
+	if breadth > 0 && len(c.subs) > breadth {
+		return true
+	}
+	if depth > 1 {
+		for _, s := range c.subs {
+			if s.indexOmits(depth-1, breadth) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// helpAllCommand returns the command line that lists all of c's sub-commands
+// with the -all flag of the implicit help command, or "" if the top-level
+// command has no help command.
+func (c *Command) helpAllCommand() string {
+	top := c
+	for top.super != nil {
+		top = top.super
+	}
+	if !top.hasHelpCommand() {
+		return ""
+	}
+	return strings.TrimSpace(top.Name + " help -all" + strings.TrimPrefix(c.Path(), top.Name))
+}
+
+// hasHelpCommand reports whether c has an implicit "help" sub-command: it is a
+// group of commands, and none of them is named "help".
+func (c *Command) hasHelpCommand() bool {
+	_, ok := c.runnable()
+	return !ok && len(c.subs) > 0 && c.findSub("help") == nil
+}
+
+// An indexEntry is a line of the list of sub-commands in a usage message.
+type indexEntry struct {
+	name, usage string
+}
+
+// commandIndex returns the entries for c's sub-commands, to the given depth.
+// If breadth is positive, at most that many sub-commands of each command
+// are included. Each entry's name is prefixed with prefix.
+func (c *Command) commandIndex(prefix string, depth, breadth int) []indexEntry {
+	return c.indexEntries(c.subs, prefix, depth, breadth)
+}
+
+// indexEntries is like commandIndex, but returns entries only for subs, which
+// are some of c's sub-commands.
+func (c *Command) indexEntries(subs []*Command, prefix string, depth, breadth int) []indexEntry {
+	var entries []indexEntry
+	for i, s := range subs {
+		if breadth > 0 && i >= breadth {
+			entries = append(entries, indexEntry{prefix + "...", fmt.Sprintf("and %d more", len(subs)-i)})
+			break
+		}
+		usage := s.Usage
+		if s.Name == c.defaultSub {
+			usage += " (default)"
+		}
+		if s.Deprecated != "" {
+			usage += " (deprecated)"
+		}
+		entries = append(entries, indexEntry{prefix + s.Name, usage})
+		if depth > 1 {
+			entries = append(entries, s.commandIndex(prefix+s.Name+" ", depth-1, breadth)...)
+		}
+	}
+	return entries
+}
+
+// helpLimits returns the HelpDepth and HelpBreadth that apply to c: the first
+// non-zero value of each, looking at c and then the commands above it.
+func (c *Command) helpLimits() (depth, breadth int) {
+	for ; c != nil; c = c.super {
+		if depth == 0 {
+			depth = c.HelpDepth
+		}
+		if breadth == 0 {
+			breadth = c.HelpBreadth
+		}
+	}
+	return depth, breadth
+}
+
+// printFlags writes the usage for c's flags to w, in the order given by c's
+// HelpOrder.
+func (c *Command) printFlags(w io.Writer, st helpStyle) {
+	for _, f := range c.orderedFlags() {
+		c.printFlag(w, f, st)
+	}
+}
+
+// orderedFlags returns c's flags in the order given by c's HelpOrder,
+// without their aliases.
+func (c *Command) orderedFlags() []*flag.Flag {
+	var flags []*flag.Flag
+	seen := map[string]bool{}
+	if c.helpOrder() != FlagsSorted {
+		for _, spec := range c.flagSpecs {
+			if f := c.flags.Lookup(spec.Name); f != nil && !seen[f.Name] {
+				flags = append(flags, f)
+				seen[f.Name] = true
+			}
+		}
+	}
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if !seen[f.Name] && !c.isAlias(f.Name) {
+			flags = append(flags, f)
+		}
+	})
+	return flags
+}
+
+// helpOrder returns the HelpOrder of c or the nearest command above it that
+// sets one.
+func (c *Command) helpOrder() HelpOrder {
+	for ; c != nil; c = c.super {
+		if c.HelpOrder != FlagsSorted {
+			return c.HelpOrder
+		}
+	}
+	return FlagsSorted
+}
+
+// printFlag writes the usage for f to w, in the format of
+// flag.FlagSet.PrintDefaults. Unlike PrintDefaults, it displays the flag
+// together with its aliases, and wraps and styles it according to st.
+func (c *Command) printFlag(w io.Writer, f *flag.Flag, st helpStyle) {
+	name := f.Name
+	if spec := c.flagSpec(f.Name); spec != nil {
+		for _, a := range spec.Aliases {
+			name += ", -" + a
+		}
+	}
+	// Let the flag package do the formatting, using a FlagSet with only this flag.
+	var b strings.Builder
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(&b)
+	fs.Var(f.Value, name, f.Usage)
+	// Var sets DefValue from the current value, which may have been changed by parsing.
+	fs.Lookup(name).DefValue = f.DefValue
+	fs.PrintDefaults()
+	u := wrapFlagUsage(b.String(), st.width)
+	if st.color {
+		u = strings.Replace(u, "-"+name, st.paint(styleCyan, "-"+name), 1)
+	}
+	io.WriteString(w, u)
+}
+
+// wrapFlagUsage wraps the descriptions in s, the output of PrintDefaults for
+// a flag, to width. PrintDefaults puts each line of a description after a
+// tab that reaches column 8.
+func wrapFlagUsage(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(s, "\n") {
+		before, desc, ok := stringsCut(line, "\t")
+		if !ok {
+			b.WriteString(line)
+			continue
+		}
+		for i, l := range wrapText(strings.TrimSuffix(desc, "\n"), width-8) {
 			if i > 0 {
-				fmt.Fprintln(w)
+				before = "    "
 			}
-			s.usage(w, false)
+			fmt.Fprintf(&b, "%s\t%s\n", before, l)
+		}
+	}
+	return b.String()
+}
+
+// writeWrapped writes prefix followed by text wrapped to width, with later
+// lines indented to align with the first.
+func writeWrapped(w io.Writer, prefix, text string, width int) {
+	pw := displayWidth(prefix)
+	for i, l := range wrapText(text, width-pw) {
+		if i > 0 {
+			prefix = strings.Repeat(" ", pw)
+		}
+		fmt.Fprintf(w, "%s%s\n", prefix, l)
+	}
+}
+
+func (c *Command) isAlias(name string) bool {
+	return c.primaryFlagName(name) != name
+}
+
+// primaryFlagName returns the name of the flag that name is an alias for,
+// or name itself if it is not an alias.
+func (c *Command) primaryFlagName(name string) string {
+	if f := c.flagSpec(name); f != nil {
+		return f.Name
+	}
+	return name
+}
+
+// Changed reports whether the flag with the given name or alias was set on
+// the command line of the current run, as opposed to having its default value
+// or a value from an earlier run. A Run method can
+// use it, through FromContext, to override a setting only when the user asked
+// to. If c doesn't have the flag, Changed looks in the commands above it, so
+// it works for global flags too.
+func (c *Command) Changed(name string) bool {
+	name = strings.TrimLeft(name, "-")
+	for a := c; a != nil; a = a.super {
+		if a.flags.Lookup(name) == nil {
+			continue
+		}
+		primary := a.primaryFlagName(name)
+		changed := false
+		a.parseSet().Visit(func(f *flag.Flag) {
+			if a.primaryFlagName(f.Name) == primary {
+				changed = true
+			}
+		})
+		return changed
+	}
+	return false
+}
+
+// bundleFor returns the bundle of type *T of c or the nearest command above
+// it, or nil if there is none.
+func bundleFor[T any](c *Command) *T {
+	for ; c != nil; c = c.super {
+		for _, b := range c.bundles {
+			if t, ok := b.(*T); ok {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// streams returns the standard streams of c, from the nearest command that
+// sets them, or the os streams.
+func (c *Command) streams() (stdin io.Reader, stdout, stderr io.Writer) {
+	stdin, stdout, stderr = os.Stdin, os.Stdout, os.Stderr
+	var in, out, errw bool
+	for ; c != nil; c = c.super {
+		if !in && c.Stdin != nil {
+			stdin, in = c.Stdin, true
+		}
+		if !out && c.Stdout != nil {
+			stdout, out = c.Stdout, true
+		}
+		if !errw && c.Stderr != nil {
+			stderr, errw = c.Stderr, true
 		}
 	}
+	return stdin, stdout, stderr
+}
+
+func (c *Command) failFast() bool {
+	for ; c != nil; c = c.super {
+		if c.FailFast {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Command) prefixMatch() bool {
+	for ; c != nil; c = c.super {
+		if c.PrefixMatch {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Command) combineShortFlags() bool {
+	for ; c != nil; c = c.super {
+		if c.CombineShortFlags {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Command) parseableErrors() bool {
+	for ; c != nil; c = c.super {
+		if c.ParseableErrors {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *Command) fullName() string {
@@ -121,15 +995,34 @@ func (c *Command) fullName() string {
 	return c.super.fullName() + " " + name
 }
 
+// Path returns the names of c and the commands above it, separated by
+// spaces, as in "prog remote add".
+func (c *Command) Path() string {
+	if c.super == nil {
+		return c.Name
+	}
+	return c.super.Path() + " " + c.Name
+}
+
 func (c *Command) usageHeader() string {
 	var b strings.Builder
 	fmt.Fprint(&b, c.fullName())
+	if _, ok := c.runnable(); !ok && len(c.subs) > 0 {
+		if c.defaultSub != "" {
+			fmt.Fprint(&b, " [<command>]")
+		} else {
+			fmt.Fprint(&b, " <command>")
+		}
+	}
 	for _, f := range c.formals {
-		fmt.Fprintf(&b, " %s", f.name)
-		if f.min >= 0 {
+		fmt.Fprintf(&b, " %s", f.Name)
+		if f.Variadic {
 			fmt.Fprint(&b, "...")
 		}
 	}
+	if c.raw != nil {
+		fmt.Fprintf(&b, " [-- %s...]", c.raw.Name)
+	}
 	return b.String()
 }
 
@@ -139,6 +1032,11 @@ func (c *Command) numFlags() int {
 	return n
 }
 
+// ParseableErrorsVersion is the version of the format of usage errors
+// written by commands with ParseableErrors set. It changes only when the
+// format does.
+const ParseableErrorsVersion = 1
+
 // UsageError is an error in how a command is invoked.
 type UsageError struct {
 	cmd *Command
@@ -153,8 +1051,16 @@ func NewUsageError(err error) *UsageError {
 // Error implements the error interface.
 func (u *UsageError) Error() string {
 	var b strings.Builder
-	fmt.Fprintf(&b, "%s: %v\n", u.cmd.Name, u.Err.Error())
-	u.cmd.usage(&b, true)
+	if u.cmd.parseableErrors() {
+		msg := strings.ReplaceAll(u.Err.Error(), "\n", " ")
+		fmt.Fprintf(&b, "usage-error/%d: %s: %s\n", ParseableErrorsVersion, u.cmd.Path(), msg)
+	} else {
+		fmt.Fprintf(&b, "%s: %v\n", u.cmd.Name, u.Err.Error())
+	}
+	_, _, stderr := u.cmd.streams()
+	// The error is usually written to stderr, but it may be written
+	// elsewhere, so don't use color.
+	u.cmd.writeUsage(&b, helpStyle{width: u.cmd.helpWidth(stderr)})
 	s := b.String()
 	return s[:len(s)-1] // trim final newline
 }
@@ -164,6 +1070,21 @@ func (u *UsageError) Unwrap() error {
 	return u.Err
 }
 
+// SetDefault sets *p to v if *p is the zero value for its type.
+// It is intended for use in a command's Default method, to default one field
+// from others:
+//
+//	func (c *cmd) Default(ctx context.Context) error {
+//	  cli.SetDefault(&c.Region, regionForZone(c.Zone))
+//	  return nil
+//	}
+func SetDefault[T comparable](p *T, v T) {
+	var zero T
+	if *p == zero {
+		*p = v
+	}
+}
+
 // Cut cuts s around the first instance of sep,
 // returning the text before and after sep.
 // The found result reports whether sep appears in s.
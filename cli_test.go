@@ -0,0 +1,358 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCommandIndexLimits(t *testing.T) {
+	top := &Command{Name: "top", HelpDepth: 2, HelpBreadth: 2}
+	initFlags(top)
+	for _, name := range []string{"a", "b", "c"} {
+		g := top.Register(&Command{Name: name, Usage: "group " + name})
+		for _, sub := range []string{"x", "y", "z"} {
+			g.Register(&Command{Name: sub, Struct: &c3{}, Usage: "run " + name + sub})
+		}
+	}
+	var b strings.Builder
+	top.printCommandIndex(&b, helpStyle{})
+	want := `Commands:
+  a      group a
+  a x    run ax
+  a y    run ay
+  a ...  and 1 more
+  b      group b
+  b x    run bx
+  b y    run by
+  b ...  and 1 more
+  ...    and 1 more
+
+Run "top help <command>" for details about a command.
+Run "top help -all" to list all of them.
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	// "help -all" lists everything.
+	stdout, _, code := top.ExecuteCapture(context.Background(), "help", "-all")
+	if code != 0 {
+		t.Fatalf("help -all: exit code %d", code)
+	}
+	for _, line := range []string{"  c z  run cz\n", "  b z  run bz\n"} {
+		if !strings.Contains(stdout, line) {
+			t.Errorf("help -all: missing %q in\n%s", line, stdout)
+		}
+	}
+	if strings.Contains(stdout, "...") || strings.Contains(stdout, "-all") {
+		t.Errorf("help -all: output is limited:\n%s", stdout)
+	}
+	stdout, _, _ = top.ExecuteCapture(context.Background(), "help", "-all", "b")
+	if !strings.Contains(stdout, "  z  run bz\n") {
+		t.Errorf("help -all b: got\n%s", stdout)
+	}
+}
+
+func TestCommandIndexWide(t *testing.T) {
+	top := &Command{Name: "top"}
+	initFlags(top)
+	top.Register(&Command{Name: "一覧", Struct: &c3{}, Usage: "学生を一覧表示する"})
+	top.Register(&Command{Name: "show", Struct: &c3{}, Usage: "show a student"})
+	top.Register(&Command{Name: "café", Struct: &c3{}, Usage: "get coffee"})
+	var b strings.Builder
+	top.printCommandIndex(&b, helpStyle{})
+	want := `Commands:
+  一覧  学生を一覧表示する
+  show  show a student
+  café  get coffee
+
+Run "top help <command>" for details about a command.
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestHelpWidth(t *testing.T) {
+	type args struct {
+		Verbose     bool     `cli:"flag=v, print the name of each file as it is processed, and a summary at the end"`
+		Dest        string   `cli:"the directory to which the files are copied, which must already exist"`
+		SourceFiles []string `cli:"files"`
+	}
+	top := &Command{Name: "prog", HelpWidth: 40}
+	initFlags(top)
+	sub := top.Command("copy", &args{}, "copy files from one place to another, keeping their permissions")
+	top.Command("other", &c3{}, "")
+	var b strings.Builder
+	sub.usage(&b)
+	want := `Usage:
+prog copy [flags] DEST SOURCEFILES...
+  copy files from one place to another,
+  keeping their permissions
+  DEST        the directory to which the
+              files are copied, which
+              must already exist
+  SOURCEFILES files
+  -v	print the name of each file as
+    	it is processed, and a summary
+    	at the end
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	b.Reset()
+	top.usage(&b)
+	want = `Usage:
+prog <command>
+
+Commands:
+  copy   copy files from one place to
+         another, keeping their
+         permissions
+  other
+
+Run "prog help <command>" for details about a command.
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	// A negative width turns off wrapping.
+	top.HelpWidth = -1
+	b.Reset()
+	sub.usage(&b)
+	if got := b.String(); !strings.Contains(got, "  copy files from one place to another, keeping their permissions\n") {
+		t.Errorf("got\n%s\nwant no wrapping", got)
+	}
+}
+
+func TestHelpOrder(t *testing.T) {
+	type args struct {
+		Zone   string `cli:"flag=zone, the zone"`
+		Source string `cli:"where to copy from"`
+		All    bool   `cli:"flag=all, copy everything"`
+		Dest   string `cli:"where to copy to"`
+	}
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	sub := top.Command("copy", &args{}, "")
+	sub.flags.Bool("extra", false, "added directly")
+	for _, test := range []struct {
+		order HelpOrder
+		want  string
+	}{
+		{FlagsSorted, `
+  SOURCE     where to copy from
+  DEST       where to copy to
+  -all
+    	copy everything
+  -extra
+    	added directly
+  -zone value
+    	the zone
+`},
+		{FlagsDeclared, `
+  SOURCE     where to copy from
+  DEST       where to copy to
+  -zone value
+    	the zone
+  -all
+    	copy everything
+  -extra
+    	added directly
+`},
+		{Interleaved, `
+  -zone value
+    	the zone
+  SOURCE     where to copy from
+  -all
+    	copy everything
+  DEST       where to copy to
+  -extra
+    	added directly
+`},
+	} {
+		top.HelpOrder = test.order
+		var b strings.Builder
+		sub.printParams(&b, helpStyle{})
+		if diff := cmp.Diff(test.want[1:], b.String()); diff != "" {
+			t.Errorf("order %d: mismatch (-want, +got):\n%s", test.order, diff)
+		}
+	}
+}
+
+func TestHelpDefaults(t *testing.T) {
+	type args struct {
+		Labels  map[string]string `cli:"flag=label, labels"`
+		Addr    net.IP            `cli:"flag=addr, address"`
+		Wait    time.Duration     `cli:"flag=wait, how long to wait"`
+		Retries int               `cli:"flag=retries, number of retries"`
+		Tags    []string          `cli:"flag=tag, sep=' ', accumulate, tags"`
+	}
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	sub := top.Command("get", &args{
+		Labels:  map[string]string{"b": "2", "a": "1"},
+		Addr:    net.ParseIP("10.0.0.1"),
+		Wait:    time.Minute,
+		Retries: 3,
+		Tags:    []string{"x", "y"},
+	}, "")
+	want := `
+  -addr value
+    	address (default 10.0.0.1)
+  -label value
+    	labels; comma-separated key=value pairs; can be repeated (default map[a:1 b:2])
+  -retries value
+    	number of retries (default 3)
+  -tag value
+    	" "-separated list of tags; can be repeated (default [x y])
+  -wait value
+    	how long to wait (default 1m0s)
+`
+	var b strings.Builder
+	sub.printParams(&b, helpStyle{})
+	if diff := cmp.Diff(want[1:], b.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	var defaults []string
+	for _, f := range sub.Spec().Flags {
+		defaults = append(defaults, f.Default)
+	}
+	if got, want := strings.Join(defaults, " "), "map[a:1 b:2] 10.0.0.1 1m0s 3 [x y]"; got != want {
+		t.Errorf("spec defaults: got %q, want %q", got, want)
+	}
+}
+
+func TestCommandCategories(t *testing.T) {
+	top := &Command{Name: "docker", CategoryOrder: []string{"Management Commands"}}
+	initFlags(top)
+	for _, c := range []struct{ name, category string }{
+		{"system", "Debug Commands"},
+		{"run", ""},
+		{"image", "Management Commands"},
+		{"ps", ""},
+		{"container", "Management Commands"},
+	} {
+		top.Register(&Command{Name: c.name, Struct: &c3{}, Usage: c.name + " things", Category: c.category})
+	}
+	var b strings.Builder
+	top.printCommandIndex(&b, helpStyle{})
+	want := `Commands:
+  run        run things
+  ps         ps things
+
+Management Commands:
+  image      image things
+  container  container things
+
+Debug Commands:
+  system     system things
+
+Run "docker help <command>" for details about a command.
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestLongAndExamples(t *testing.T) {
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	hello := top.Command("hello", &greeter{}, "greet someone")
+	hello.Long = `
+Hello greets someone by name.
+If no name is given, it reads one from standard input.
+
+It is polite.`
+	hello.Examples = []Example{
+		{Doc: "Greet Pat loudly.", Args: []string{"-shout", "Pat"}},
+		{Args: []string{"Pat O'Brien"}},
+	}
+	var b strings.Builder
+	hello.usage(&b)
+	want := `Usage:
+prog hello [flags] NAME    greet someone
+
+  Hello greets someone by name.
+  If no name is given, it reads one from standard input.
+
+  It is polite.
+
+  NAME       who to greet
+  -shout
+    	use capital letters
+
+Examples:
+  Greet Pat loudly:
+    $ prog hello -shout Pat
+
+  $ prog hello 'Pat O'\''Brien'
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestParseableErrors(t *testing.T) {
+	top := &Command{Name: "top", ParseableErrors: true}
+	initFlags(top)
+	sub := top.Register(&Command{Name: "sub", Struct: &c3{}})
+	err := &UsageError{cmd: sub, Err: errors.New("bad\nvalue")}
+	got, _, _ := stringsCut(err.Error(), "\n")
+	if want := "usage-error/1: top sub: bad value"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAccessibleUsage(t *testing.T) {
+	type args struct {
+		Verbose bool     `cli:"flag=v, verbose output"`
+		Name    string   `cli:"the name"`
+		Files   []string `cli:"min=1, files"`
+	}
+	top := &Command{Name: "prog", Accessible: true}
+	initFlags(top)
+	sub := top.Command("sub", &args{}, "do something")
+	sub.Long = "Sub does something\nto each file.\n\nIt takes a while."
+	sub.Examples = []Example{{Doc: "Do it quietly.", Args: []string{"bob", "my file"}}}
+	top.Command("other", &c3{}, "")
+	var b strings.Builder
+	sub.usage(&b)
+	want := `Usage: prog sub
+Description: do something
+Details: Sub does something to each file. It takes a while.
+Arguments:
+  NAME, required: the name
+  FILES, required, at least 1: files
+Flags:
+  -v	verbose output
+Example: Do it quietly: prog sub bob 'my file'
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	b.Reset()
+	top.usage(&b)
+	want = `Usage: prog
+Command: required
+Commands:
+  sub    do something
+  other
+
+Run "prog help <command>" for details about a command.
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
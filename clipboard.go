@@ -0,0 +1,99 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Copying to the system clipboard.
+
+// ErrNoClipboard is returned by WriteClipboard when the system has no
+// clipboard that it knows how to use, as on a server without a display.
+var ErrNoClipboard = errors.New("no clipboard available")
+
+// AddCopyFlag adds a boolean flag named "copy" to c, which asks commands to
+// copy their result to the clipboard with Copy. It is typically called on the
+// top-level command, so that the flag applies to all commands.
+func (c *Command) AddCopyFlag() {
+	c.copyFlag = new(bool)
+	c.flags.BoolVar(c.copyFlag, "copy", false, "also copy the result to the clipboard")
+}
+
+// Copy copies s to the system clipboard, for results like tokens and URLs
+// that users paste elsewhere. If the running command or one above it has the
+// flag added by AddCopyFlag, Copy does nothing unless the flag is set.
+//
+// If there is no clipboard, Copy issues a warning with Warnf instead of
+// failing, since the result has presumably been written to standard output
+// as well.
+func Copy(ctx context.Context, s string) error {
+	for c := invocationOrDefault(ctx).cmd; c != nil; c = c.super {
+		if c.copyFlag != nil {
+			if !*c.copyFlag {
+				return nil
+			}
+			break
+		}
+	}
+	err := WriteClipboard(s)
+	if errors.Is(err, ErrNoClipboard) {
+		Warnf(ctx, "not copying to the clipboard: %v", err)
+		return nil
+	}
+	return err
+}
+
+// WriteClipboard writes s to the system clipboard, using the program for the
+// operating system: pbcopy on macOS, clip on Windows, and on other systems
+// wl-copy under Wayland or xclip or xsel under X. It returns ErrNoClipboard
+// if none applies.
+func WriteClipboard(s string) error {
+	args, err := clipboardCommand()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(s)
+	// Leave the output alone. Capturing it would wait for the program to
+	// close it, but xclip and wl-copy fork a process that keeps it open to
+	// serve the clipboard, so the wait wouldn't end until something else was
+	// copied.
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	return nil
+}
+
+// clipboardCommand returns the command line of a program that copies its
+// standard input to the clipboard. It is a variable for testing.
+var clipboardCommand = func() ([]string, error) {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip"}}
+	default:
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, []string{"wl-copy"})
+		}
+		if os.Getenv("DISPLAY") != "" {
+			candidates = append(candidates,
+				[]string{"xclip", "-selection", "clipboard"},
+				[]string{"xsel", "--clipboard", "--input"})
+		}
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return c, nil
+		}
+	}
+	return nil, ErrNoClipboard
+}
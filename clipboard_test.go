@@ -0,0 +1,71 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tokenCmd struct{}
+
+func (tokenCmd) Run(ctx context.Context) error {
+	return Copy(ctx, "tok123")
+}
+
+func TestCopy(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	file := filepath.Join(t.TempDir(), "clip")
+	defer func(f func() ([]string, error)) { clipboardCommand = f }(clipboardCommand)
+	clipboardCommand = func() ([]string, error) {
+		return []string{"sh", "-c", "cat > " + file}, nil
+	}
+
+	run := func(args ...string) string {
+		t.Helper()
+		os.Remove(file)
+		top := &Command{Name: "prog"}
+		initFlags(top)
+		top.AddCopyFlag()
+		top.Command("token", &tokenCmd{}, "")
+		var stderr bytes.Buffer
+		ctx := withInvocation(context.Background(), &invocation{stderr: &stderr})
+		if err := top.Run(ctx, args); err != nil {
+			t.Fatal(err)
+		}
+		b, _ := os.ReadFile(file)
+		return string(b) + stderr.String()
+	}
+
+	if got := run("token"); got != "" {
+		t.Errorf("without -copy: got %q, want nothing", got)
+	}
+	if got, want := run("-copy", "token"), "tok123"; got != want {
+		t.Errorf("with -copy: got %q, want %q", got, want)
+	}
+
+	// Like xclip, leave a process behind that holds the output open.
+	clipboardCommand = func() ([]string, error) {
+		return []string{"sh", "-c", "cat > " + file + "; sleep 10 &"}, nil
+	}
+	start := time.Now()
+	if got, want := run("-copy", "token"), "tok123"; got != want {
+		t.Errorf("with a lingering process: got %q, want %q", got, want)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("waited %s for the lingering process", d)
+	}
+
+	clipboardCommand = func() ([]string, error) { return nil, ErrNoClipboard }
+	if got, want := run("-copy", "token"), "warning: not copying to the clipboard"; !strings.Contains(got, want) {
+		t.Errorf("no clipboard: got %q, want it to contain %q", got, want)
+	}
+}
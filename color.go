@@ -0,0 +1,87 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"io"
+	"os"
+)
+
+// Color and emphasis in usage messages and errors.
+
+// A ColorMode says whether a command's usage messages and errors are styled
+// with color and emphasis.
+type ColorMode int
+
+const (
+	// ColorAuto styles output written to a terminal. It follows the
+	// conventions of https://no-color.org and https://bixense.com/clicolors
+	// for environment variables, in this order of precedence:
+	//
+	//   - If NO_COLOR is not empty, output is never styled.
+	//   - If CLICOLOR_FORCE is set to anything but "" or "0", output is
+	//     styled even if it isn't written to a terminal.
+	//   - If TERM is "dumb" or CLICOLOR is "0", output is not styled.
+	ColorAuto ColorMode = iota
+	// ColorAlways always styles output.
+	ColorAlways
+	// ColorNever never styles output.
+	ColorNever
+)
+
+// ANSI escape sequences for the styles used.
+const (
+	styleBold  = "\x1b[1m"
+	styleCyan  = "\x1b[36m"
+	styleRed   = "\x1b[31m"
+	styleReset = "\x1b[0m"
+)
+
+// useColor reports whether output of c written to w should be styled.
+// See Command.Color.
+func (c *Command) useColor(w io.Writer) bool {
+	for a := c; a != nil; a = a.super {
+		switch a.Color {
+		case ColorAlways:
+			return true
+		case ColorNever:
+			return false
+		}
+	}
+	return colorFromEnv(isTerminal(w))
+}
+
+// colorFromEnv reports whether to style output in ColorAuto mode, given
+// whether it is written to a terminal. See ColorAuto.
+func colorFromEnv(terminal bool) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if f := os.Getenv("CLICOLOR_FORCE"); f != "" && f != "0" {
+		return true
+	}
+	if os.Getenv("TERM") == "dumb" || os.Getenv("CLICOLOR") == "0" {
+		return false
+	}
+	return terminal
+}
+
+// A helpStyle says how to format a usage message for where it is written.
+type helpStyle struct {
+	width int  // the width to wrap descriptions to, if positive
+	color bool // whether to use color and emphasis
+	all   bool // whether to list all sub-commands, despite HelpDepth and HelpBreadth
+}
+
+// helpStyle returns the style for usage messages of c written to w.
+func (c *Command) helpStyle(w io.Writer) helpStyle {
+	return helpStyle{width: c.helpWidth(w), color: c.useColor(w)}
+}
+
+// paint returns s in the given style, if st uses color.
+func (st helpStyle) paint(style, s string) string {
+	if !st.color || s == "" {
+		return s
+	}
+	return style + s + styleReset
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestColorUsage(t *testing.T) {
+	type args struct {
+		Verbose bool   `cli:"flag=v, verbose output"`
+		Name    string `cli:"the name"`
+	}
+	top := &Command{Name: "prog", Color: ColorAlways}
+	initFlags(top)
+	sub := top.Command("sub", &args{}, "do something")
+	top.Command("other", &c3{}, "")
+	var b strings.Builder
+	sub.usage(&b)
+	want := "Usage:\n" +
+		"\x1b[1mprog sub [flags] NAME\x1b[0m    do something\n" +
+		"  NAME       the name\n" +
+		"  \x1b[36m-v\x1b[0m\tverbose output\n"
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	b.Reset()
+	top.printCommandIndex(&b, top.helpStyle(&b))
+	want = "Commands:\n" +
+		"  \x1b[1msub\x1b[0m    do something\n" +
+		"  \x1b[1mother\x1b[0m\n" +
+		"\n" +
+		"Run \"prog help <command>\" for details about a command.\n"
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	sub.Color = ColorNever
+	if sub.useColor(&b) {
+		t.Error("ColorNever: got color")
+	}
+	top.Color = ColorAuto
+	sub.Color = ColorAuto
+	t.Setenv("CLICOLOR_FORCE", "")
+	if sub.useColor(&b) {
+		t.Error("ColorAuto, not a terminal: got color")
+	}
+}
+
+func TestColorError(t *testing.T) {
+	var b strings.Builder
+	exitCode(errors.New("bad thing\nmore"), &b, true)
+	if got, want := b.String(), "\x1b[31mbad thing\x1b[0m\nmore\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestColorFromEnv(t *testing.T) {
+	for _, test := range []struct {
+		env      map[string]string
+		terminal bool
+		want     bool
+	}{
+		{nil, true, true},
+		{nil, false, false},
+		{map[string]string{"NO_COLOR": "1"}, true, false},
+		{map[string]string{"NO_COLOR": "1", "CLICOLOR_FORCE": "1"}, true, false},
+		{map[string]string{"CLICOLOR_FORCE": "1"}, false, true},
+		{map[string]string{"CLICOLOR_FORCE": "0"}, false, false},
+		{map[string]string{"CLICOLOR_FORCE": "1", "TERM": "dumb", "CLICOLOR": "0"}, false, true},
+		{map[string]string{"TERM": "dumb"}, true, false},
+		{map[string]string{"TERM": "xterm-256color"}, true, true},
+		{map[string]string{"CLICOLOR": "0"}, true, false},
+		{map[string]string{"CLICOLOR": "1"}, true, true},
+		{map[string]string{"CLICOLOR": "1"}, false, false},
+	} {
+		for _, v := range []string{"NO_COLOR", "CLICOLOR_FORCE", "CLICOLOR", "TERM"} {
+			t.Setenv(v, test.env[v])
+		}
+		if got := colorFromEnv(test.terminal); got != test.want {
+			t.Errorf("%v, terminal=%t: got %t, want %t", test.env, test.terminal, got, test.want)
+		}
+	}
+
+	// An explicit mode overrides the environment.
+	t.Setenv("CLICOLOR_FORCE", "1")
+	var b strings.Builder
+	top := &Command{Name: "prog"}
+	if !top.useColor(&b) {
+		t.Error("CLICOLOR_FORCE: got no color")
+	}
+	top.Color = ColorNever
+	if top.useColor(&b) {
+		t.Error("ColorNever with CLICOLOR_FORCE: got color")
+	}
+}
@@ -4,7 +4,13 @@ package cli
 
 // Methods for github.com/posener/complete/v2.Completer.
 
-import "github.com/posener/complete/v2"
+import (
+	"flag"
+	"strings"
+
+	"github.com/posener/complete/v2"
+	"github.com/posener/complete/v2/predict"
+)
 
 func (c *Command) SubCmdList() []string {
 	if c == nil {
@@ -31,10 +37,131 @@ func (c *Command) FlagList() []string {
 	return complete.FlagSet(c.flags).FlagList()
 }
 
+// FlagGet returns a predictor for the values of the named flag. If c's struct
+// implements
+//
+//	PredictFlag(name, prefix string) []string
+//
+// it is called with the flag's primary name, even if flag is an alias. If
+// it returns nil, or for a flag without the method, a flag with choices
+// predicts them, and a flag with a "type" tag key predicts file paths.
 func (c *Command) FlagGet(flag string) complete.Predictor {
-	return complete.FlagSet(c.flags).FlagGet(flag)
+	if c == nil {
+		return nil
+	}
+	def := complete.FlagSet(c.flags).FlagGet(flag)
+	if def == nil || isBoolFlag(c.flags.Lookup(flag)) {
+		return def
+	}
+	name := c.primaryFlagName(flag)
+	var choices []string
+	if spec := c.flagSpec(name); spec != nil {
+		choices = spec.Choices
+		if p := pathPredictor(spec.PathType); p != nil {
+			def = p
+		}
+	}
+	fp, ok := c.Struct.(flagPredictor)
+	if !ok && choices == nil {
+		return def
+	}
+	return complete.PredictFunc(func(prefix string) []string {
+		if ok {
+			if opts := fp.PredictFlag(name, prefix); opts != nil {
+				return opts
+			}
+		}
+		if choices != nil {
+			return choices
+		}
+		return def.Predict(prefix)
+	})
 }
 
-func (c *Command) ArgsGet() complete.Predictor {
+// pathPredictor returns a predictor for file paths of the kind described by
+// the value of a "type" tag key, or nil if pathType is empty.
+func pathPredictor(pathType string) complete.Predictor {
+	switch {
+	case pathType == "existingfile":
+		return predict.Files("*")
+	case pathType == "existingdir":
+		return predict.Dirs("*")
+	case strings.HasPrefix(pathType, "glob:"):
+		return predict.Files(strings.TrimPrefix(pathType, "glob:"))
+	}
 	return nil
 }
+
+// flagPredictor is implemented by command structs that compute completions
+// for the values of their flags.
+type flagPredictor interface {
+	PredictFlag(name, prefix string) []string
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// ArgsGet returns a predictor for the positional arguments of c. Since the
+// predictor isn't told which argument is being completed, it predicts the
+// union of the values of all of them, without duplicates.
+func (c *Command) ArgsGet() complete.Predictor {
+	if c == nil {
+		return nil
+	}
+	var ps []complete.Predictor
+	for _, f := range c.formals {
+		if p := c.argPredictor(f); p != nil {
+			ps = append(ps, p)
+		}
+	}
+	switch len(ps) {
+	case 0:
+		return nil
+	case 1:
+		return ps[0]
+	default:
+		return complete.PredictFunc(func(prefix string) []string {
+			var opts []string
+			seen := map[string]bool{}
+			for _, p := range ps {
+				for _, o := range p.Predict(prefix) {
+					if !seen[o] {
+						seen[o] = true
+						opts = append(opts, o)
+					}
+				}
+			}
+			return opts
+		})
+	}
+}
+
+// argCompleter is implemented by command structs that compute completions for
+// their positional arguments.
+type argCompleter interface {
+	Complete(field, prefix string) []string
+}
+
+// argPredictor returns a predictor for the positional argument f. As with
+// flags, the struct's Complete method is asked first, and if it returns nil,
+// the choices or paths that the argument's tags allow are predicted.
+func (c *Command) argPredictor(f *formal) complete.Predictor {
+	var def complete.Predictor
+	if p := pathPredictor(f.PathType); p != nil {
+		def = p
+	} else if f.Choices != nil {
+		def = predict.Set(f.Choices)
+	}
+	ac, ok := c.Struct.(argCompleter)
+	if !ok {
+		return def
+	}
+	return complete.PredictFunc(func(prefix string) []string {
+		if opts := ac.Complete(f.fieldName, prefix); opts != nil || def == nil {
+			return opts
+		}
+		return def.Predict(prefix)
+	})
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type copyCmd struct {
+	Mode string `cli:"oneof=fast|safe, how to copy"`
+	Src  string `cli:"type=existingfile, source file"`
+	Dst  string `cli:"type=existingdir, destination directory"`
+}
+
+func (*copyCmd) Run(ctx context.Context) error { return nil }
+
+type catCmd struct {
+	Files []string `cli:"type=glob:*.txt, files"`
+}
+
+func (*catCmd) Run(context.Context) error { return nil }
+
+type deployCmd struct {
+	Env     string `cli:"the environment"`
+	Service string `cli:"the service"`
+	Tier    string `cli:"oneof=free|paid, the tier"`
+}
+
+func (*deployCmd) Run(ctx context.Context) error { return nil }
+
+func (*deployCmd) Complete(field, prefix string) []string {
+	switch field {
+	case "Env":
+		return []string{"dev", "prod"}
+	case "Service":
+		return []string{"api", "web"}
+	}
+	return nil
+}
+
+func TestArgsGet(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	top := initFlags(&Command{Name: "prog"})
+	cp := top.Command("cp", &copyCmd{}, "")
+	deploy := top.Command("deploy", &deployCmd{}, "")
+	none := top.Command("none", &c3{}, "")
+	cat := top.Command("cat", &catCmd{}, "")
+
+	predict := func(c *Command, prefix string) []string {
+		p := c.ArgsGet()
+		if p == nil {
+			return nil
+		}
+		var got []string
+		for _, s := range p.Predict(prefix) {
+			if strings.HasPrefix(s, prefix) {
+				got = append(got, s)
+			}
+		}
+		sort.Strings(got)
+		return got
+	}
+	for _, test := range []struct {
+		cmd    *Command
+		prefix string
+		want   []string
+	}{
+		{cp, "", []string{"./", "a.txt", "b.txt", "fast", "safe", "sub/"}},
+		{cp, "f", []string{"fast"}},
+		{deploy, "", []string{"api", "dev", "free", "paid", "prod", "web"}},
+		{none, "", nil},
+		{cat, "a", []string{"a.txt"}},
+	} {
+		got := predict(test.cmd, test.prefix)
+		if !cmp.Equal(got, test.want) {
+			t.Errorf("%s %q: got %v, want %v", test.cmd.Name, test.prefix, got, test.want)
+		}
+	}
+}
+
+type getCmd struct {
+	Region string `cli:"flag=region|r, the region"`
+	Format string `cli:"flag=format, oneof=json|text, output format"`
+	Zone   string `cli:"flag=zone, the zone"`
+	Quiet  bool   `cli:"flag=quiet, less output"`
+}
+
+func (*getCmd) Run(context.Context) error { return nil }
+
+func (*getCmd) PredictFlag(name, prefix string) []string {
+	if name == "region" {
+		return []string{"us-east", "us-west", "eu-north"}
+	}
+	return nil
+}
+
+func TestFlagGet(t *testing.T) {
+	top := initFlags(&Command{Name: "prog"})
+	get := top.Command("get", &getCmd{}, "")
+	for _, test := range []struct {
+		flag string
+		want []string
+	}{
+		{"region", []string{"us-east", "us-west", "eu-north"}},
+		{"r", []string{"us-east", "us-west", "eu-north"}},
+		{"format", []string{"json", "text"}},
+		{"zone", []string{""}},
+	} {
+		p := get.FlagGet(test.flag)
+		if p == nil {
+			t.Fatalf("%s: nil predictor", test.flag)
+		}
+		if got := p.Predict(""); !cmp.Equal(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.flag, got, test.want)
+		}
+	}
+	if p := get.FlagGet("nope"); p != nil {
+		t.Errorf("nope: got %v, want nil", p)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Static shell completion scripts.
+
+// CompletionShells are the shells supported by GenCompletionScript.
+var CompletionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// GenCompletionScript writes to w a completion script for the given shell,
+// which must be one of CompletionShells. The script completes the names of
+// sub-commands and flags of top and its sub-commands. Unlike the completion
+// provided by Main, it does not run the program, so it can be installed where
+// that isn't possible. It must be regenerated when the commands change.
+func GenCompletionScript(w io.Writer, top *Command, shell string) error {
+	tmpl, ok := completionTemplates[shell]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q; want one of %s", shell, strings.Join(CompletionShells, ", "))
+	}
+	data := struct {
+		Prog  string
+		Func  string
+		Paths []completionPath
+	}{
+		Prog:  top.Name,
+		Func:  "_" + nonIdentRegexp.ReplaceAllString(top.Name, "_") + "_complete",
+		Paths: completionPaths(top.Spec(), nil),
+	}
+	return tmpl.Execute(w, data)
+}
+
+// AddCompletionCommand registers a sub-command of c named "completion" that
+// writes a completion script for the shell named by its argument.
+// See GenCompletionScript.
+func (c *Command) AddCompletionCommand() *Command {
+	return c.Command("completion", &completionCmd{top: c}, "write a shell completion script")
+}
+
+type completionCmd struct {
+	Shell string `cli:"oneof=bash|zsh|fish|powershell, the shell"`
+	top   *Command
+}
+
+func (cc *completionCmd) Run(ctx context.Context) error {
+	return GenCompletionScript(invocationOrDefault(ctx).stdout, cc.top, cc.Shell)
+}
+
+var nonIdentRegexp = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// A completionPath is a command's path from the top, and the words that can
+// follow it.
+type completionPath struct {
+	Path  string   // names separated by spaces
+	Subs  []string // sub-command names
+	Words []string // sub-command names and flags
+}
+
+func completionPaths(s *Spec, parent []string) []completionPath {
+	path := append(parent[:len(parent):len(parent)], s.Name)
+	cp := completionPath{Path: strings.Join(path, " ")}
+	for _, sub := range s.Commands {
+		cp.Subs = append(cp.Subs, sub.Name)
+	}
+	cp.Words = append(cp.Words, cp.Subs...)
+	for _, f := range s.Flags {
+		cp.Words = append(cp.Words, "-"+f.Name)
+		for _, a := range f.Aliases {
+			cp.Words = append(cp.Words, "-"+a)
+		}
+	}
+	cps := []completionPath{cp}
+	for _, sub := range s.Commands {
+		cps = append(cps, completionPaths(sub, path)...)
+	}
+	return cps
+}
+
+var completionTemplates = map[string]*template.Template{}
+
+func init() {
+	funcs := template.FuncMap{
+		"join": strings.Join,
+		// quote single-quotes a string for bash, zsh and fish.
+		"quote": func(s string) string {
+			return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+		},
+		// psquote single-quotes a string for PowerShell.
+		"psquote": func(s string) string {
+			return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+		},
+	}
+	bash := template.Must(template.New("bash").Funcs(funcs).Parse(bashCompletion))
+	completionTemplates["bash"] = bash
+	completionTemplates["zsh"] = template.Must(template.Must(bash.Clone()).New("zsh").Parse(zshCompletion))
+	completionTemplates["fish"] = template.Must(template.New("fish").Funcs(funcs).Parse(fishCompletion))
+	completionTemplates["powershell"] = template.Must(template.New("powershell").Funcs(funcs).Parse(powershellCompletion))
+}
+
+const bashCompletion = `# bash completion for {{.Prog}}. Generated; do not edit.
+{{.Func}}() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local path={{quote .Prog}}
+    local i w
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        w="${COMP_WORDS[i]}"
+        case "$w" in -*) continue ;; esac
+        case "$path $w" in
+{{- range .Paths}}{{$p := .Path}}{{range .Subs}}
+            {{quote (print $p " " .)}}) path="$path $w" ;;
+{{- end}}{{end}}
+        esac
+    done
+    local words=""
+    case "$path" in
+{{- range .Paths}}
+        {{quote .Path}}) words={{quote (join .Words " ")}} ;;
+{{- end}}
+    esac
+    COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -F {{.Func}} {{quote .Prog}}
+`
+
+const zshCompletion = `# zsh completion for {{.Prog}}. Generated; do not edit.
+autoload -U +X bashcompinit && bashcompinit
+{{template "bash" .}}`
+
+const fishCompletion = `# fish completion for {{.Prog}}. Generated; do not edit.
+function {{.Func}}
+    set -l path {{quote .Prog}}
+    for w in (commandline -opc)[2..-1]
+        if string match -q -- '-*' $w
+            continue
+        end
+        switch "$path $w"
+{{- range .Paths}}{{$p := .Path}}{{range .Subs}}
+            case {{quote (print $p " " .)}}
+                set path "$path $w"
+{{- end}}{{end}}
+        end
+    end
+    switch $path
+{{- range .Paths}}
+        case {{quote .Path}}
+            printf '%s\n' {{range .Words}}{{quote .}} {{end}}
+{{- end}}
+    end
+end
+complete -c {{quote .Prog}} -f -a '({{.Func}})'
+`
+
+const powershellCompletion = `# PowerShell completion for {{.Prog}}. Generated; do not edit.
+Register-ArgumentCompleter -Native -CommandName {{psquote .Prog}} -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $commands = @{
+{{- range .Paths}}
+        {{psquote .Path}} = @({{range $i, $w := .Words}}{{if $i}}, {{end}}{{psquote $w}}{{end}})
+{{- end}}
+    }
+    $path = {{psquote .Prog}}
+    $words = $commandAst.CommandElements | Select-Object -Skip 1 |
+        Where-Object { $_.Extent.EndOffset -lt $cursorPosition } |
+        ForEach-Object { $_.ToString() }
+    foreach ($w in $words) {
+        if ($w.StartsWith('-')) { continue }
+        if ($commands.ContainsKey("$path $w")) { $path = "$path $w" }
+    }
+    $commands[$path] | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`
@@ -0,0 +1,71 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenCompletionScript(t *testing.T) {
+	top := initFlags(&Command{Name: "prog"})
+	g := top.Command("students", nil, "")
+	g.Command("list", &c4{}, "")
+	top.AddCompletionCommand()
+
+	for _, shell := range CompletionShells {
+		var b strings.Builder
+		if err := GenCompletionScript(&b, top, shell); err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		if !strings.Contains(b.String(), "-region") {
+			t.Errorf("%s: script does not mention -region:\n%s", shell, b.String())
+		}
+	}
+	if err := GenCompletionScript(&strings.Builder{}, top, "csh"); err == nil {
+		t.Error("csh: got nil, want error")
+	}
+}
+
+func TestBashCompletionScript(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("no bash")
+	}
+	top := initFlags(&Command{Name: "prog"})
+	g := top.Command("students", nil, "")
+	g.Command("list", &c4{}, "")
+	top.Command("status", &c1{}, "")
+
+	var b strings.Builder
+	if err := GenCompletionScript(&b, top, "bash"); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "prog.bash")
+	if err := os.WriteFile(script, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		words string // words on the command line, the last being completed
+		want  string
+	}{
+		{"prog s", "students status"},
+		{"prog students ", "list"},
+		{"prog students list -r", "-region"},
+	} {
+		cmd := exec.Command(bash, "-c", `source "$1"; COMP_WORDS=($2); `+
+			`COMP_CWORD=$(( ${#COMP_WORDS[@]} - 1 )); `+
+			`[[ "$2" == *" " ]] && COMP_WORDS+=("") && COMP_CWORD=$(( COMP_CWORD + 1 )); `+
+			`_prog_complete; echo "${COMPREPLY[*]}"`, "bash", script, test.words)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%q: %v\n%s", test.words, err, out)
+		}
+		if got := strings.TrimSpace(string(out)); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.words, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Configuration files and environment variables.
+
+// A Format is the format of a configuration file. Its value is also the
+// file extension for the format, without the dot.
+type Format string
+
+// The formats of configuration files. Only JSON can be read unless a decoder
+// for the format is added with RegisterConfigFormat.
+const (
+	JSON Format = "json"
+	YAML Format = "yaml" // also for files ending in .yml
+	TOML Format = "toml"
+)
+
+// A ConfigDecoder decodes the contents of a configuration file into v, which
+// is a *map[string]interface{}. The Unmarshal functions of most encoding
+// packages, like gopkg.in/yaml.v3 and github.com/BurntSushi/toml, can be used
+// as they are.
+type ConfigDecoder func(data []byte, v interface{}) error
+
+var (
+	configMu       sync.RWMutex
+	configDecoders = map[Format]ConfigDecoder{JSON: decodeJSON}
+)
+
+// RegisterConfigFormat makes LoadConfig and the -config flag accept files in
+// format f, decoding them with decode. It can also add a format of the
+// program's own, like Format("ini"), for files with that extension.
+//
+// Only JSON is built in. Reading YAML or TOML needs a third-party package,
+// and building one in would make every program that uses this package depend
+// on it. So a program that wants those formats registers a decoder for them,
+// usually from main or an init function:
+//
+//	cli.RegisterConfigFormat(cli.YAML, yaml.Unmarshal)
+func RegisterConfigFormat(f Format, decode ConfigDecoder) {
+	if f == "" || strings.HasPrefix(string(f), ".") || decode == nil {
+		panic(fmt.Sprintf("cli.RegisterConfigFormat: bad format %q or nil decoder", f))
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	configDecoders[Format(strings.ToLower(string(f)))] = decode
+}
+
+// formatOf returns the format of the file at path, from its extension.
+func formatOf(path string) Format {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if ext == "yml" {
+		return YAML
+	}
+	return Format(ext)
+}
+
+// configDecoder returns the decoder for format f, or nil if there is none.
+func configDecoder(f Format) ConfigDecoder {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return configDecoders[f]
+}
+
+// configExtensions returns the extensions of the formats that have decoders,
+// with ".json" first and the others in sorted order.
+func configExtensions() []string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	var exts []string
+	for f := range configDecoders {
+		if f == JSON {
+			continue
+		}
+		exts = append(exts, "."+string(f))
+		if f == YAML {
+			exts = append(exts, ".yml")
+		}
+	}
+	sort.Strings(exts)
+	return append([]string{".json"}, exts...)
+}
+
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Preserve large integers.
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// A configFile is a decoded configuration file.
+type configFile struct {
+	path   string
+	values map[string]interface{}
+}
+
+// LoadConfig reads the configuration file at path, which is in the given
+// format. If format is empty, it comes from the file's extension: .json,
+// .yaml or .yml, .toml, or that of a format added with RegisterConfigFormat.
+// JSON is always accepted; the other formats need a decoder added with
+// RegisterConfigFormat.
+//
+// Flags whose tags have the "config" key take their values from the file
+// when c or one of its sub-commands runs. The value of the key is a
+// dot-separated path into the file: with the tag "config=server.port", the
+// value comes from
+//
+//	{"server": {"port": 8080}}
+//
+// A value is converted to a string and then parsed like the flag's argument
+// on the command line. A list becomes a list separated by the flag's
+// separator (a comma, unless the tag has the "sep" key), and a map of scalars
+// becomes a list of "key=value" pairs. A key that is missing from the file
+// leaves the flag alone.
+//
+// If a sub-command also loads a configuration file, flags of that command and
+// its sub-commands use that file instead.
+func (c *Command) LoadConfig(path string, format Format) error {
+	cf, err := readConfig(path, format)
+	if err != nil {
+		return fmt.Errorf("cli.LoadConfig: %w", err)
+	}
+	c.config = cf
+	return nil
+}
+
+// readConfig reads and decodes the configuration file at path, in format f
+// or, if f is empty, the format of its extension.
+func readConfig(path string, f Format) (*configFile, error) {
+	if f == "" {
+		f = formatOf(path)
+	}
+	decode := configDecoder(f)
+	if decode == nil {
+		if f == YAML || f == TOML {
+			return nil, fmt.Errorf("%s: no decoder for %s; add one with RegisterConfigFormat", path, strings.ToUpper(string(f)))
+		}
+		return nil, fmt.Errorf("%s: unknown configuration format", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	if err := decode(data, &values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &configFile{path: path, values: values}, nil
+}
+
+// A configFlag holds the state of the flag added by AddConfigFlag.
+type configFlag struct {
+	path   string      // value of the flag
+	loaded *configFile // the file it names, or the one found; nil if none
+}
+
+// AddConfigFlag adds a -config flag to c, which is usually the top-level
+// command. Its value is the path of a configuration file that sets the flags
+// of c and its sub-commands, as with LoadConfig; the format comes from the
+// file's extension. It is an error if the file doesn't exist.
+//
+// Without the flag, the first of these files that exists is used, where
+// PROG is the name of c and EXT is .json or the extension of a format with a
+// decoder added with RegisterConfigFormat:
+//
+//	$HOME/.PROG.EXT
+//	$XDG_CONFIG_HOME/PROG/config.EXT (see os.UserConfigDir)
+//	./.PROG.EXT
+//
+// The file in the current directory comes last, so that a directory can't
+// override the user's own settings.
+//
+// If none exists, the file loaded with LoadConfig, if any, is used.
+func (c *Command) AddConfigFlag() {
+	c.configFlag = &configFlag{}
+	c.flags.StringVar(&c.configFlag.path, "config", "", "configuration file (default: search standard locations)")
+}
+
+// ConfigFile returns the path of the configuration file that sets the flags
+// of c, or the empty string if there is none. While c runs, it reflects the
+// -config flag; see AddConfigFlag.
+func (c *Command) ConfigFile() string {
+	if cf := c.configFile(); cf != nil {
+		return cf.path
+	}
+	return ""
+}
+
+// configFile returns the configuration file for c, from the nearest command
+// that has one.
+func (c *Command) configFile() *configFile {
+	for ; c != nil; c = c.super {
+		if c.configFlag != nil && c.configFlag.loaded != nil {
+			return c.configFlag.loaded
+		}
+		if c.config != nil {
+			return c.config
+		}
+	}
+	return nil
+}
+
+// loadConfigFlag loads the file named by c's -config flag, or the first one
+// found in the standard locations.
+func (c *Command) loadConfigFlag() error {
+	cf := c.configFlag
+	cf.loaded = nil
+	path := cf.path
+	if path == "" {
+		path = findConfig(c.Name)
+		if path == "" {
+			return nil
+		}
+	}
+	loaded, err := readConfig(path, "")
+	if err != nil {
+		return fmt.Errorf("-config: %w", err)
+	}
+	cf.loaded = loaded
+	return nil
+}
+
+// findConfig returns the first of the standard configuration files for the
+// program prog that exists, or the empty string if none does.
+func findConfig(prog string) string {
+	exts := configExtensions()
+	var paths []string
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, ext := range exts {
+			paths = append(paths, filepath.Join(home, "."+prog+ext))
+		}
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		for _, ext := range exts {
+			paths = append(paths, filepath.Join(dir, prog, "config"+ext))
+		}
+	}
+	for _, ext := range exts {
+		paths = append(paths, "."+prog+ext)
+	}
+	for _, p := range paths {
+		if fi, err := os.Stat(p); err == nil && fi.Mode().IsRegular() {
+			return p
+		}
+	}
+	return ""
+}
+
+// applySettings sets the flags of c that have the "config" or "env" tag keys
+// from the nearest configuration file and from the environment, in that
+// order. It skips flags that were set on the command line. It sets the flags'
+// values directly, so they don't count as set on the command line; see
+// Changed.
+func (c *Command) applySettings() error {
+	cf := c.configFile()
+	for _, spec := range c.flagSpecs {
+		if (spec.ConfigKey == "" && spec.Env == "") || c.Changed(spec.Name) {
+			continue
+		}
+		f := c.flags.Lookup(spec.Name)
+		if f == nil {
+			continue
+		}
+		if spec.ConfigKey != "" && cf != nil {
+			if v, ok := lookupConfig(cf.values, spec.ConfigKey); ok {
+				s, err := configString(v, spec.sep)
+				if err == nil {
+					err = setFlagValue(f, s)
+				}
+				if err != nil {
+					return fmt.Errorf("%s: %s: %w", cf.path, spec.ConfigKey, err)
+				}
+			}
+		}
+		if spec.Env != "" {
+			if s := os.Getenv(spec.Env); s != "" {
+				if err := setFlagValue(f, s); err != nil {
+					return fmt.Errorf("$%s: %w", spec.Env, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// lookupConfig returns the value at the dot-separated key in m.
+// It reports false if the key is missing or its value is null.
+func lookupConfig(m map[string]interface{}, key string) (interface{}, bool) {
+	var v interface{} = m
+	for _, k := range strings.Split(key, ".") {
+		switch mv := v.(type) {
+		case map[string]interface{}:
+			v = mv[k]
+		case map[interface{}]interface{}:
+			v = mv[k]
+		default:
+			return nil, false
+		}
+		if v == nil {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// configString converts a value from a configuration file to a string
+// that a flag can parse. Lists and maps are joined with sep, or a comma
+// if sep is empty.
+func configString(v interface{}, sep string) (string, error) {
+	if sep == "" {
+		sep = ","
+	}
+	switch v := v.(type) {
+	case []interface{}:
+		var elems []string
+		for _, e := range v {
+			s, err := scalarString(e)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, s)
+		}
+		return strings.Join(elems, sep), nil
+	case map[string]interface{}:
+		var elems []string
+		for k, e := range v {
+			s, err := scalarString(e)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, k+"="+s)
+		}
+		sort.Strings(elems)
+		return strings.Join(elems, sep), nil
+	default:
+		return scalarString(v)
+	}
+}
+
+func scalarString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case bool, int, int64, uint64, json.Number:
+		return fmt.Sprint(v), nil
+	case nil:
+		return "", nil
+	default:
+		if s, ok := v.(fmt.Stringer); ok {
+			// For example, a TOML local date from a registered decoder.
+			return s.String(), nil
+		}
+		return "", errors.New("value is not a string, number or boolean")
+	}
+}
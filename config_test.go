@@ -0,0 +1,309 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type server struct {
+	Host    string            `cli:"flag=host, env=SERVER_HOST, config=server.host, host name"`
+	Port    int               `cli:"flag=port, env=SERVER_PORT, config=server.port, port"`
+	Timeout time.Duration     `cli:"flag=timeout, config=server.timeout, timeout"`
+	Debug   bool              `cli:"flag=debug, config=debug, debug mode"`
+	Users   []string          `cli:"flag=users, config=users, users"`
+	Labels  map[string]string `cli:"flag=label, config=labels, labels"`
+}
+
+func (s *server) Run(ctx context.Context) error {
+	fmt.Fprintf(Stdout(ctx), "%s:%d %s %t %v %v changed=%t\n",
+		s.Host, s.Port, s.Timeout, s.Debug, s.Users, s.Labels, FromContext(ctx).Changed("port"))
+	return nil
+}
+
+func init() {
+	RegisterConfigFormat("flat", decodeFlat)
+}
+
+// decodeFlat decodes lines of the form "a.b = value", with string values,
+// to test RegisterConfigFormat.
+func decodeFlat(data []byte, v interface{}) error {
+	p := v.(*map[string]interface{})
+	if *p == nil {
+		*p = map[string]interface{}{}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key, val, ok := stringsCut(line, "=")
+		if !ok {
+			return fmt.Errorf("missing '=' in %q", line)
+		}
+		m := *p
+		keys := strings.Split(strings.TrimSpace(key), ".")
+		for _, k := range keys[:len(keys)-1] {
+			sub, _ := m[k].(map[string]interface{})
+			if sub == nil {
+				sub = map[string]interface{}{}
+				m[k] = sub
+			}
+			m = sub
+		}
+		m[keys[len(keys)-1]] = strings.TrimSpace(val)
+	}
+	return nil
+}
+
+func TestLoadConfig(t *testing.T) {
+	files := map[string]string{
+		"c.json": `{"server": {"host": "h", "port": 8080, "timeout": "5s"}, "debug": true,
+			"users": ["a", "b"], "labels": {"x": "1", "y": 2}}`,
+		"c.flat": `
+debug = true
+users = a,b
+labels = x=1,y=2
+server.host = h
+server.port = 8080
+server.timeout = 5s
+`,
+	}
+	const want = "h:8080 5s true [a b] map[x:1 y:2] changed=false"
+	dir := t.TempDir()
+	for name, contents := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			top := initFlags(&Command{Name: "prog"})
+			top.Command("serve", &server{Host: "localhost", Port: 80}, "serve")
+			if err := top.LoadConfig(path, ""); err != nil {
+				t.Fatal(err)
+			}
+			stdout, stderr, _ := top.ExecuteCapture(context.Background(), "serve")
+			if got := strings.TrimSpace(stdout); got != want {
+				t.Errorf("got %q, want %q (stderr %q)", got, want, stderr)
+			}
+		})
+	}
+}
+
+func TestSettingsPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "c.json")
+	if err := os.WriteFile(path, []byte(`{"server": {"host": "config", "port": 1}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	top := initFlags(&Command{Name: "prog"})
+	top.Command("serve", &server{Host: "default", Port: 80}, "serve")
+	run := func(args ...string) string {
+		t.Helper()
+		stdout, stderr, _ := top.ExecuteCapture(context.Background(), append([]string{"serve"}, args...)...)
+		if stderr != "" {
+			t.Fatalf("%v: %s", args, stderr)
+		}
+		host, _, _ := stringsCut(strings.TrimSpace(stdout), " ")
+		return host
+	}
+	if got, want := run(), "default:80"; got != want {
+		t.Errorf("no config: got %q, want %q", got, want)
+	}
+	if err := top.LoadConfig(path, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := run(), "config:1"; got != want {
+		t.Errorf("config: got %q, want %q", got, want)
+	}
+	t.Setenv("SERVER_PORT", "2")
+	if got, want := run(), "config:2"; got != want {
+		t.Errorf("env: got %q, want %q", got, want)
+	}
+	if got, want := run("-port", "3"), "config:3"; got != want {
+		t.Errorf("command line: got %q, want %q", got, want)
+	}
+	// An empty variable is ignored.
+	t.Setenv("SERVER_PORT", "")
+	if got, want := run(), "config:1"; got != want {
+		t.Errorf("empty env: got %q, want %q", got, want)
+	}
+}
+
+func TestSettingsErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "c.json")
+	if err := os.WriteFile(path, []byte(`{"server": {"port": [1, 2]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	top := initFlags(&Command{Name: "prog"})
+	top.Command("serve", &server{}, "serve")
+	if err := top.LoadConfig(path, ""); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code := top.ExecuteCapture(context.Background(), "serve")
+	if want := "server.port"; code != 2 || !strings.Contains(stderr, want) {
+		t.Errorf("bad config: code %d, stderr %q; want 2 and %q", code, stderr, want)
+	}
+
+	top = initFlags(&Command{Name: "prog"})
+	top.Command("serve", &server{}, "serve")
+	t.Setenv("SERVER_PORT", "eighty")
+	_, stderr, code = top.ExecuteCapture(context.Background(), "serve")
+	if want := "$SERVER_PORT"; code != 2 || !strings.Contains(stderr, want) {
+		t.Errorf("bad env: code %d, stderr %q; want 2 and %q", code, stderr, want)
+	}
+
+	if err := top.LoadConfig(filepath.Join(dir, "c.ini"), ""); err == nil {
+		t.Error("unknown extension: got nil, want error")
+	}
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := top.LoadConfig(bad, ""); err == nil {
+		t.Error("bad JSON: got nil, want error")
+	}
+	yml := filepath.Join(dir, "c.yml")
+	if err := os.WriteFile(yml, []byte("server.port = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := top.LoadConfig(yml, ""); err == nil || !strings.Contains(err.Error(), "RegisterConfigFormat") {
+		t.Errorf("YAML without a decoder: got %v, want error mentioning RegisterConfigFormat", err)
+	}
+}
+
+func TestConfigFormat(t *testing.T) {
+	dir := t.TempDir()
+	// The format is given, so the extension doesn't matter.
+	path := filepath.Join(dir, "settings.conf")
+	if err := os.WriteFile(path, []byte(`{"server": {"port": 7}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	top := initFlags(&Command{Name: "prog"})
+	top.Command("serve", &server{Host: "h"}, "serve")
+	if err := top.LoadConfig(path, ""); err == nil {
+		t.Error("unknown extension: got nil, want error")
+	}
+	if err := top.LoadConfig(path, JSON); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, _ := top.ExecuteCapture(context.Background(), "serve")
+	if got, want := stdout, "h:7 "; !strings.HasPrefix(got, want) {
+		t.Errorf("got %q, want prefix %q (stderr %q)", got, want, stderr)
+	}
+
+	// A decoder for YAML reads files ending in .yaml and .yml.
+	RegisterConfigFormat(YAML, decodeFlat)
+	defer func() {
+		configMu.Lock()
+		delete(configDecoders, YAML)
+		configMu.Unlock()
+	}()
+	if got, want := strings.Join(configExtensions(), " "), ".json .flat .yaml .yml"; got != want {
+		t.Errorf("extensions: got %q, want %q", got, want)
+	}
+	for _, name := range []string{"c.yaml", "c.YML"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("server.port = 9\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := top.LoadConfig(path, ""); err != nil {
+			t.Fatal(err)
+		}
+		stdout, stderr, _ := top.ExecuteCapture(context.Background(), "serve")
+		if got, want := stdout, "h:9 "; !strings.HasPrefix(got, want) {
+			t.Errorf("%s: got %q, want prefix %q (stderr %q)", name, got, want, stderr)
+		}
+	}
+}
+
+func TestConfigFlag(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	work := filepath.Join(dir, "work")
+	if err := os.Mkdir(work, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	t.Setenv("SERVER_PORT", "")
+
+	top := initFlags(&Command{Name: "prog"})
+	top.AddConfigFlag()
+	top.Command("serve", &server{Host: "default"}, "serve")
+	run := func(args ...string) string {
+		t.Helper()
+		stdout, stderr, _ := top.ExecuteCapture(context.Background(), args...)
+		if stderr != "" {
+			return stderr
+		}
+		host, _, _ := stringsCut(strings.TrimSpace(stdout), ":")
+		return host
+	}
+	if got, want := run("serve"), "default"; got != want {
+		t.Errorf("no files: got %q, want %q", got, want)
+	}
+	// Each file found takes precedence over the ones after it.
+	for _, f := range []struct{ path, host string }{
+		{"work/.prog.json", "work"},
+		{"xdg/prog/config.json", "xdg"},
+		{"home/.prog.flat", "home"},
+	} {
+		if filepath.Ext(f.path) == ".flat" {
+			write(f.path, "server.host = "+f.host+"\n")
+		} else {
+			write(f.path, `{"server": {"host": "`+f.host+`"}}`)
+		}
+		if got := run("serve"); got != f.host {
+			t.Errorf("%s: got %q, want %q", f.path, got, f.host)
+		}
+	}
+	explicit := write("explicit.json", `{"server": {"host": "explicit"}}`)
+	if got, want := run("-config", explicit, "serve"), "explicit"; got != want {
+		t.Errorf("-config: got %q, want %q", got, want)
+	}
+	if got, want := run("-config", filepath.Join(dir, "nosuch.json"), "serve"), "no such file"; !strings.Contains(got, want) {
+		t.Errorf("missing file: got %q, want it to contain %q", got, want)
+	}
+
+	// The flag doesn't carry over to the next run.
+	for _, test := range []struct {
+		args []string
+		host string
+	}{
+		{[]string{"-config", explicit, "serve"}, "explicit"},
+		{[]string{"serve"}, "home"},
+	} {
+		var out strings.Builder
+		ctx := withInvocation(context.Background(), &invocation{stdout: &out, stderr: &out})
+		if err := top.Run(ctx, test.args); err != nil {
+			t.Fatalf("%v: %v", test.args, err)
+		}
+		if got, _, _ := stringsCut(strings.TrimSpace(out.String()), ":"); got != test.host {
+			t.Errorf("Run %v: got %q, want %q", test.args, got, test.host)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// State shared by all the commands of a single invocation.
+
+// An invocation holds state for a single call to the top-most Run.
+type invocation struct {
+	cmd      *Command // the command being run
+	args     []string // the arguments to the outermost Run
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+	strict   bool            // treat warnings as errors
+	warnings int             // number of warnings issued
+	warned   map[string]bool // keys passed to warnOnce
+	dryRun   bool            // DryRun reports true; see AddLearnCommand
+
+	instances map[*Command]*Command // for Reentrant commands, from registered commands to their instances
+	fresh     bool                  // run every command on an instance, as if Reentrant were set
+	// Take the arguments as they are, without expanding response files,
+	// splitting pipelines or splitting them at "--" for a raw field.
+	exact bool
+
+	// Whether the streams come from the Stdin, Stdout and Stderr fields of
+	// the commands being run, rather than from the caller of Run.
+	ownStreams bool
+
+	depth   int        // number of calls to Run in progress
+	mu      sync.Mutex // guards warnings, warned, tempDir, phases and the writing of warnings and events
+	tempDir string     // see TempDir
+	phases  []Phase    // see timePhase
+}
+
+// newInvocation returns an invocation with the streams of c, which may be nil.
+func newInvocation(c *Command) *invocation {
+	inv := &invocation{}
+	inv.stdin, inv.stdout, inv.stderr = c.streams()
+	return inv
+}
+
+type invocationKey struct{}
+
+// strictErr returns an error if inv is in strict mode and there were warnings.
+func (inv *invocation) strictErr() error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if inv.strict && inv.warnings > 0 {
+		return fmt.Errorf("%d warning(s) treated as errors (-strict)", inv.warnings)
+	}
+	return nil
+}
+
+// invocationFrom returns the invocation stored in ctx, or nil if none.
+func invocationFrom(ctx context.Context) *invocation {
+	inv, _ := ctx.Value(invocationKey{}).(*invocation)
+	return inv
+}
+
+// invocationOrDefault is like invocationFrom, but returns an invocation
+// with the default streams if ctx has none.
+func invocationOrDefault(ctx context.Context) *invocation {
+	if inv := invocationFrom(ctx); inv != nil {
+		return inv
+	}
+	return newInvocation(nil)
+}
+
+func withInvocation(ctx context.Context, inv *invocation) context.Context {
+	return context.WithValue(ctx, invocationKey{}, inv)
+}
+
+// FromContext returns the command that is running, or nil if ctx doesn't
+// come from Command.Run. Shared code that logs or reports errors can use the
+// command's Path to say what was running:
+//
+//	if c := cli.FromContext(ctx); c != nil {
+//	  log.Printf("%s: %v", c.Path(), err)
+//	}
+func FromContext(ctx context.Context) *Command {
+	if inv := invocationFrom(ctx); inv != nil {
+		return inv.cmd
+	}
+	return nil
+}
+
+// RawArgs returns the command-line arguments as they were passed to the
+// outermost call of Command.Run, before any parsing. For a program run with
+// Main, they are os.Args[1:], except that if the top-level command has
+// ResponseFiles set, "@file" arguments are replaced by the arguments in the
+// files. For a segment of a pipeline, they are that segment's arguments.
+// RawArgs returns nil if ctx doesn't come from Command.Run.
+func RawArgs(ctx context.Context) []string {
+	if inv := invocationFrom(ctx); inv != nil {
+		return inv.args
+	}
+	return nil
+}
+
+// Stdin returns the command's standard input. It is usually os.Stdin, but
+// differs when the command is part of a pipeline, is run by RunExamples, or
+// has the Stdin field set on it or a command above it.
+func Stdin(ctx context.Context) io.Reader {
+	return invocationOrDefault(ctx).stdin
+}
+
+// Stdout returns the command's standard output. Commands should write their
+// output to it rather than to os.Stdout, so that it can be piped or captured.
+func Stdout(ctx context.Context) io.Writer {
+	return invocationOrDefault(ctx).stdout
+}
+
+// Stderr returns the command's standard error.
+func Stderr(ctx context.Context) io.Writer {
+	return invocationOrDefault(ctx).stderr
+}
+
+// Warnf formats a warning message and writes it to standard error.
+// If the command was invoked with the strict flag (see Command.AddStrictFlag),
+// the invocation will fail after the command returns.
+func Warnf(ctx context.Context, format string, args ...interface{}) {
+	inv := invocationOrDefault(ctx)
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.warnings++
+	fmt.Fprintf(inv.stderr, "warning: %s\n", fmt.Sprintf(format, args...))
+}
+
+// WriteJSON writes v as JSON to the command's standard output.
+// A command can use it to produce structured output that the next command in a
+// pipeline can read with a field tagged "stdin=json". See Command.Pipelines.
+// If the command has a FieldsOptions bundle whose flag is set, WriteJSON
+// writes only the selected fields of v; see Project. Then if it has a
+// QueryOptions bundle whose flag is set, WriteJSON writes the results of the
+// query instead of v.
+func WriteJSON(ctx context.Context, v interface{}) error {
+	inv := invocationOrDefault(ctx)
+	if f := bundleFor[FieldsOptions](inv.cmd); f != nil {
+		var err error
+		v, err = Project(v, f.Fields)
+		if err != nil {
+			return NewUsageError(err)
+		}
+	}
+	vals := []interface{}{v}
+	if q := bundleFor[QueryOptions](inv.cmd); q != nil && !q.Query.IsZero() {
+		var err error
+		vals, err = q.Query.Eval(v)
+		if err != nil {
+			return NewUsageError(err)
+		}
+	}
+	enc := json.NewEncoder(inv.stdout)
+	enc.SetIndent("", "  ")
+	for _, v := range vals {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TempDir returns a directory for the temporary files of the running
+// command. It creates the directory on the first call, and later calls during
+// the same invocation return the same one. The directory and everything in it
+// are removed when the outermost Command.Run returns, even if it panics.
+// TempDir returns an error if ctx doesn't come from Command.Run.
+func TempDir(ctx context.Context) (string, error) {
+	inv := invocationFrom(ctx)
+	if inv == nil {
+		return "", errors.New("cli.TempDir: not running a command")
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if inv.tempDir == "" {
+		prog := "cli"
+		for c := inv.cmd; c != nil; c = c.super {
+			prog = c.Name
+		}
+		dir, err := os.MkdirTemp("", prog+"-")
+		if err != nil {
+			return "", err
+		}
+		inv.tempDir = dir
+	}
+	return inv.tempDir, nil
+}
+
+// removeTempDir removes the directory created by TempDir, if any.
+func (inv *invocation) removeTempDir() error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if inv.tempDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(inv.tempDir)
+	inv.tempDir = ""
+	return err
+}
+
+// warnOnce calls Warnf, unless it has already been called with key
+// during this invocation.
+func warnOnce(ctx context.Context, key, format string, args ...interface{}) {
+	if inv := invocationFrom(ctx); inv != nil {
+		inv.mu.Lock()
+		seen := inv.warned[key]
+		if !seen {
+			if inv.warned == nil {
+				inv.warned = map[string]bool{}
+			}
+			inv.warned[key] = true
+		}
+		inv.mu.Unlock()
+		if seen {
+			return
+		}
+	}
+	Warnf(ctx, format, args...)
+}
+
+// StdinIsPipe reports whether the command's standard input comes from a pipe
+// or file rather than a terminal. Commands can use it to decide whether to
+// read input or prompt for it.
+func StdinIsPipe(ctx context.Context) bool {
+	return !isTerminal(invocationOrDefault(ctx).stdin)
+}
+
+// StdoutIsTTY reports whether the command's standard output is a terminal.
+// Commands can use it to decide whether to produce output meant for people,
+// like colors or progress indicators, or output meant for other programs.
+func StdoutIsTTY(ctx context.Context) bool {
+	return isTerminal(invocationOrDefault(ctx).stdout)
+}
+
+// isTerminal reports whether x is a file that refers to a terminal.
+// It approximates that by checking for a character device.
+func isTerminal(x interface{}) bool {
+	f, ok := x.(*os.File)
+	if !ok || f == nil {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
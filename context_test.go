@@ -0,0 +1,223 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStreamDetection(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	for _, test := range []struct {
+		name       string
+		inv        *invocation
+		wantPipe   bool
+		wantStdTTY bool
+	}{
+		{"buffers", &invocation{stdin: strings.NewReader(""), stdout: &bytes.Buffer{}}, true, false},
+		{"pipes", &invocation{stdin: r, stdout: w}, true, false},
+	} {
+		ctx := withInvocation(context.Background(), test.inv)
+		if got := StdinIsPipe(ctx); got != test.wantPipe {
+			t.Errorf("%s: StdinIsPipe = %t, want %t", test.name, got, test.wantPipe)
+		}
+		if got := StdoutIsTTY(ctx); got != test.wantStdTTY {
+			t.Errorf("%s: StdoutIsTTY = %t, want %t", test.name, got, test.wantStdTTY)
+		}
+	}
+}
+
+type limiter struct {
+	Limit   int  `cli:"flag=n|limit, max results"`
+	Verbose bool `cli:"flag=v, verbose"`
+
+	changed []string
+}
+
+func (l *limiter) Run(ctx context.Context) error {
+	l.changed = nil
+	for _, name := range []string{"limit", "n", "-v", "quiet", "nosuch"} {
+		if FromContext(ctx).Changed(name) {
+			l.changed = append(l.changed, name)
+		}
+	}
+	return nil
+}
+
+func TestChanged(t *testing.T) {
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	top.flags.Bool("quiet", false, "")
+	l := &limiter{}
+	top.Command("list", l, "")
+	for _, test := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"list"}, nil},
+		{[]string{"list", "-n", "10"}, []string{"limit", "n"}},
+		{[]string{"list", "-limit", "0"}, []string{"limit", "n"}},
+		{[]string{"-quiet", "list", "-v"}, []string{"-v", "quiet"}},
+		// Flags set by earlier runs of the same tree don't count.
+		{[]string{"list"}, nil},
+	} {
+		if err := top.Run(context.Background(), test.args); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(l.changed, test.want) {
+			t.Errorf("%v: got %v, want %v", test.args, l.changed, test.want)
+		}
+	}
+	if FromContext(context.Background()) != nil {
+		t.Error("FromContext of a background context is not nil")
+	}
+}
+
+type reporter struct {
+	Name string
+
+	path string
+	args []string
+}
+
+func (r *reporter) Run(ctx context.Context) error {
+	r.path = FromContext(ctx).Path()
+	r.args = RawArgs(ctx)
+	return nil
+}
+
+func TestFromContextAndRawArgs(t *testing.T) {
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	top.flags.Bool("v", false, "")
+	r := &reporter{}
+	top.Command("remote", nil, "").Command("add", r, "")
+	args := []string{"-v", "remote", "add", "origin"}
+	if err := top.Run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	if want := "prog remote add"; r.path != want {
+		t.Errorf("path: got %q, want %q", r.path, want)
+	}
+	if !slices.Equal(r.args, args) {
+		t.Errorf("args: got %q, want %q", r.args, args)
+	}
+	if RawArgs(context.Background()) != nil || FromContext(context.Background()) != nil {
+		t.Error("got non-nil values for a background context")
+	}
+}
+
+type echoer struct {
+	Words []string
+}
+
+func (e *echoer) Run(ctx context.Context) error {
+	_, err := io.Copy(Stdout(ctx), Stdin(ctx))
+	fmt.Fprintln(Stdout(ctx), strings.Join(e.Words, " "))
+	return err
+}
+
+func TestCommandStreams(t *testing.T) {
+	var stdout, stderr, subout bytes.Buffer
+	top := &Command{Name: "prog", Stdin: strings.NewReader("in "), Stdout: &stdout, Stderr: &stderr}
+	initFlags(top)
+	top.Command("echo", &echoer{}, "echo words")
+	top.Register(&Command{Name: "echo2", Struct: &echoer{}, Stdout: &subout})
+
+	check := func(name string, b *bytes.Buffer, want string) {
+		t.Helper()
+		if got := b.String(); !strings.Contains(got, want) {
+			t.Errorf("%s: got %q, want it to contain %q", name, got, want)
+		}
+		b.Reset()
+	}
+
+	if err := top.Run(context.Background(), []string{"echo", "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	check("stdout", &stdout, "in hello")
+
+	// A sub-command's stream overrides the one above it; the others are inherited.
+	if err := top.Run(context.Background(), []string{"echo2", "bye"}); err != nil {
+		t.Fatal(err)
+	}
+	check("sub-command stdout", &subout, "bye")
+
+	if err := top.Run(context.Background(), []string{"help", "echo"}); err != nil {
+		t.Fatal(err)
+	}
+	check("help", &stdout, "prog echo WORDS")
+
+	if code := top.mainWithArgs(context.Background(), []string{"echo", "-x"}); code != 2 {
+		t.Errorf("got exit code %d, want 2", code)
+	}
+	check("flag error", &stderr, "flag provided but not defined: -x")
+	if stdout.Len() > 0 {
+		t.Errorf("unexpected output on stdout: %q", stdout.String())
+	}
+}
+
+type scratcher struct {
+	Panic bool `cli:"flag=panic, panic after writing"`
+	dir   string
+}
+
+func (s *scratcher) Run(ctx context.Context) error {
+	dir, err := TempDir(ctx)
+	if err != nil {
+		return err
+	}
+	s.dir = dir
+	if again, _ := TempDir(ctx); again != dir {
+		return fmt.Errorf("second call: got %q, want %q", again, dir)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "f"), []byte("x"), 0644); err != nil {
+		return err
+	}
+	if s.Panic {
+		panic("scratch")
+	}
+	return nil
+}
+
+func TestTempDir(t *testing.T) {
+	s := &scratcher{}
+	top := initFlags(&Command{Name: "prog"})
+	top.Command("scratch", s, "use a temporary directory")
+	if err := top.Run(context.Background(), []string{"scratch"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.dir == "" || !strings.HasPrefix(filepath.Base(s.dir), "prog-") {
+		t.Fatalf("got directory %q", s.dir)
+	}
+	if _, err := os.Stat(s.dir); !os.IsNotExist(err) {
+		t.Errorf("after Run: got %v, want not-exist", err)
+	}
+
+	s.dir = ""
+	func() {
+		defer func() { recover() }()
+		top.Run(context.Background(), []string{"scratch", "-panic"})
+	}()
+	if _, err := os.Stat(s.dir); s.dir == "" || !os.IsNotExist(err) {
+		t.Errorf("after panic: got %q, %v; want not-exist", s.dir, err)
+	}
+
+	if _, err := TempDir(context.Background()); err == nil {
+		t.Error("outside Run: got nil, want error")
+	}
+}
@@ -0,0 +1,390 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Storing secrets.
+
+// A CredentialStore keeps secrets, like passwords and tokens, identified by
+// a service name and an account name.
+type CredentialStore interface {
+	// Get returns the secret, or ErrCredentialNotFound if there is none.
+	Get(service, account string) (string, error)
+	Set(service, account, secret string) error
+	// Delete removes the secret. It is not an error if there is none.
+	Delete(service, account string) error
+}
+
+var (
+	// ErrCredentialNotFound is returned by CredentialStore.Get when there is
+	// no secret for the service and account.
+	ErrCredentialNotFound = errors.New("credential not found")
+
+	// ErrNoKeychain is returned by KeychainStore when the system has no
+	// keychain that it knows how to use.
+	ErrNoKeychain = errors.New("no keychain available")
+)
+
+// KeychainStore returns a CredentialStore that keeps secrets in the
+// operating system's keychain: the login keychain on macOS, using the
+// security program; the Windows Credential Manager; and on other systems the
+// Secret Service, like GNOME Keyring or KWallet, using the secret-tool
+// program. It returns ErrNoKeychain if none applies.
+//
+// Secrets are passed to those programs on their standard input, never as
+// arguments, which other processes could see.
+func KeychainStore() (CredentialStore, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return wincredStore()
+	case "darwin":
+		if _, err := exec.LookPath("security"); err != nil {
+			return nil, ErrNoKeychain
+		}
+		return macKeychain{}, nil
+	default:
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return nil, ErrNoKeychain
+		}
+		if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+			// secret-tool can't reach the Secret Service.
+			return nil, ErrNoKeychain
+		}
+		return secretService{}, nil
+	}
+}
+
+// runKeychainCommand runs a program with stdin as its standard input and
+// returns its standard output and exit code. An exit code of -1 means the
+// program couldn't be run. It is a variable for testing.
+var runKeychainCommand = func(stdin string, name string, args ...string) (string, int, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		err = fmt.Errorf("%s: %s", name, strings.TrimSpace(stderr.String()))
+		return string(out), ee.ExitCode(), err
+	}
+	if err != nil {
+		return "", -1, err
+	}
+	return string(out), 0, nil
+}
+
+// macKeychain uses the macOS security program.
+type macKeychain struct{}
+
+// The exit code of the security program when an item isn't found.
+const macNotFound = 44
+
+func (macKeychain) Get(service, account string) (string, error) {
+	out, code, err := runKeychainCommand("", "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	if code == macNotFound {
+		return "", ErrCredentialNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+func (macKeychain) Set(service, account, secret string) error {
+	// The -w option of add-generic-password takes the secret as an argument,
+	// so send the whole command to security's interactive mode instead.
+	// A line break would end the command, so reject it in any word.
+	for _, w := range []struct{ name, value string }{{"service", service}, {"account", account}, {"secret", secret}} {
+		if strings.ContainsAny(w.value, "\r\n") {
+			return fmt.Errorf("keychain: %s contains a line break", w.name)
+		}
+	}
+	cmd := strings.Join([]string{"add-generic-password", "-U",
+		"-s", securityQuote(service), "-a", securityQuote(account), "-w", securityQuote(secret)}, " ")
+	_, _, err := runKeychainCommand(cmd+"\n", "security", "-i")
+	return err
+}
+
+// securityQuote quotes s as a single word for the interactive mode of the
+// security program.
+func securityQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func (macKeychain) Delete(service, account string) error {
+	_, code, err := runKeychainCommand("", "security", "delete-generic-password", "-s", service, "-a", account)
+	if code == macNotFound {
+		return nil
+	}
+	return err
+}
+
+// secretService uses the secret-tool program from libsecret.
+type secretService struct{}
+
+func (secretService) Get(service, account string) (string, error) {
+	out, code, err := runKeychainCommand("", "secret-tool", "lookup", "service", service, "account", account)
+	// secret-tool exits with status 1 and no output when there is no item.
+	if code == 1 && out == "" {
+		return "", ErrCredentialNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+func (secretService) Set(service, account, secret string) error {
+	label := service + " (" + account + ")"
+	_, _, err := runKeychainCommand(secret, "secret-tool", "store", "--label="+label, "service", service, "account", account)
+	return err
+}
+
+func (s secretService) Delete(service, account string) error {
+	_, code, err := runKeychainCommand("", "secret-tool", "clear", "service", service, "account", account)
+	if code == 1 {
+		// secret-tool exits with status 1 both when there is nothing to clear
+		// and when clearing fails, as with a locked keyring. Only the first
+		// is success.
+		if _, gerr := s.Get(service, account); errors.Is(gerr, ErrCredentialNotFound) {
+			return nil
+		}
+	}
+	return err
+}
+
+// EncryptedFileStore returns a CredentialStore that keeps secrets in the file
+// at path, encrypted with a key derived from a passphrase. It is for systems
+// without a keychain. The passphrase function is called at most once, when
+// the file is first read or written; it can ask the user with Input, or read
+// an environment variable.
+func EncryptedFileStore(path string, passphrase func() ([]byte, error)) CredentialStore {
+	return &encryptedFile{path: path, passphrase: passphrase}
+}
+
+type encryptedFile struct {
+	path       string
+	passphrase func() ([]byte, error)
+
+	mu  sync.Mutex
+	pw  []byte
+	err error
+}
+
+// The on-disk form of an encryptedFile.
+type encryptedFileData struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"` // an encrypted JSON map from service and account to secret
+}
+
+// pbkdf2Iterations is the number of iterations used to derive a key from a
+// passphrase. It is a variable for testing.
+var pbkdf2Iterations = 200_000
+
+func (e *encryptedFile) Get(service, account string) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	m, err := e.read()
+	if err != nil {
+		return "", err
+	}
+	s, ok := m[credentialKey(service, account)]
+	if !ok {
+		return "", ErrCredentialNotFound
+	}
+	return s, nil
+}
+
+func (e *encryptedFile) Set(service, account, secret string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	m, err := e.read()
+	if err != nil {
+		return err
+	}
+	m[credentialKey(service, account)] = secret
+	return e.write(m)
+}
+
+func (e *encryptedFile) Delete(service, account string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	m, err := e.read()
+	if err != nil {
+		return err
+	}
+	k := credentialKey(service, account)
+	if _, ok := m[k]; !ok {
+		return nil
+	}
+	delete(m, k)
+	return e.write(m)
+}
+
+func credentialKey(service, account string) string {
+	return service + "\x00" + account
+}
+
+func (e *encryptedFile) getPassphrase() ([]byte, error) {
+	if e.pw == nil && e.err == nil {
+		e.pw, e.err = e.passphrase()
+		if e.err == nil && len(e.pw) == 0 {
+			e.err = errors.New("empty passphrase")
+		}
+	}
+	return e.pw, e.err
+}
+
+// read returns the secrets in the file, or an empty map if it doesn't exist.
+func (e *encryptedFile) read() (map[string]string, error) {
+	m := map[string]string{}
+	data, err := os.ReadFile(e.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var fd encryptedFileData
+	if err := json.Unmarshal(data, &fd); err != nil {
+		return nil, fmt.Errorf("%s: %w", e.path, err)
+	}
+	pw, err := e.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(pw, fd.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, fd.Nonce, fd.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: wrong passphrase or corrupt file", e.path)
+	}
+	if err := json.Unmarshal(plain, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", e.path, err)
+	}
+	return m, nil
+}
+
+// write encrypts m with a new salt and nonce and writes it to the file.
+func (e *encryptedFile) write(m map[string]string) error {
+	pw, err := e.getPassphrase()
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	fd := encryptedFileData{Salt: make([]byte, 16)}
+	if _, err := rand.Read(fd.Salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(pw, fd.Salt)
+	if err != nil {
+		return err
+	}
+	fd.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(fd.Nonce); err != nil {
+		return err
+	}
+	fd.Data = gcm.Seal(nil, fd.Nonce, plain, nil)
+	data, err := json.Marshal(fd)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(e.path), 0o700); err != nil {
+		return err
+	}
+	// Write atomically, so that a failed write can't lose every secret. The
+	// store has no context, so a dry run still writes.
+	return WriteFileAtomic(context.Background(), e.path, data, 0o600)
+}
+
+func newGCM(passphrase, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2SHA256(passphrase, salt, pbkdf2Iterations))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a 32-byte key from a password with PBKDF2 (RFC 8018)
+// using HMAC-SHA256. A 32-byte key needs only the first block.
+func pbkdf2SHA256(password, salt []byte, iter int) []byte {
+	prf := hmac.New(sha256.New, password)
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < iter; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// CredentialTokenStore returns a TokenStore, for use with AddAuthCommands,
+// that keeps the token in cs under the given service and account.
+func CredentialTokenStore(cs CredentialStore, service, account string) TokenStore {
+	return &credentialTokenStore{cs, service, account}
+}
+
+type credentialTokenStore struct {
+	cs               CredentialStore
+	service, account string
+}
+
+func (s *credentialTokenStore) Load() (*Token, error) {
+	v, err := s.cs.Get(s.service, s.account)
+	if errors.Is(err, ErrCredentialNotFound) {
+		return nil, ErrNotLoggedIn
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t Token
+	if err := json.Unmarshal([]byte(v), &t); err != nil {
+		return nil, fmt.Errorf("stored token: %w", err)
+	}
+	return &t, nil
+}
+
+func (s *credentialTokenStore) Save(t *Token) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return s.cs.Set(s.service, s.account, string(data))
+}
+
+func (s *credentialTokenStore) Delete() error {
+	return s.cs.Delete(s.service, s.account)
+}
@@ -0,0 +1,255 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPBKDF2(t *testing.T) {
+	for _, test := range []struct {
+		password, salt string
+		iter           int
+		want           string // the first 32 bytes of the derived key
+	}{
+		// From RFC 7914, section 11.
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56"},
+		// The cases of RFC 6070, with SHA-256 in place of SHA-1.
+		{"password", "salt", 1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{
+			"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096,
+			"348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1",
+		},
+		{"pass\x00word", "sa\x00lt", 4096, "89b69d0516f829893c696226650a86878c029ac13ee276509d5ae58b6466a724"},
+	} {
+		got := hex.EncodeToString(pbkdf2SHA256([]byte(test.password), []byte(test.salt), test.iter))
+		if got != test.want {
+			t.Errorf("%q, %q, %d: got %s, want %s", test.password, test.salt, test.iter, got, test.want)
+		}
+	}
+}
+
+func TestEncryptedFileStore(t *testing.T) {
+	defer func(n int) { pbkdf2Iterations = n }(pbkdf2Iterations)
+	pbkdf2Iterations = 10
+
+	path := filepath.Join(t.TempDir(), "creds")
+	calls := 0
+	pass := func(pw string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			calls++
+			return []byte(pw), nil
+		}
+	}
+	cs := EncryptedFileStore(path, pass("open sesame"))
+	if _, err := cs.Get("svc", "pat"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("got %v, want ErrCredentialNotFound", err)
+	}
+	if err := cs.Set("svc", "pat", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Set("svc", "kim", "swordfish"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cs.Get("svc", "pat"); err != nil || got != "hunter2" {
+		t.Errorf("got %q, %v; want hunter2", got, err)
+	}
+	if calls != 1 {
+		t.Errorf("passphrase asked for %d times, want 1", calls)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Error("secret is stored in plain text")
+	}
+
+	// A new store for the same file reads what the first wrote.
+	cs2 := EncryptedFileStore(path, pass("open sesame"))
+	if got, err := cs2.Get("svc", "kim"); err != nil || got != "swordfish" {
+		t.Errorf("got %q, %v; want swordfish", got, err)
+	}
+	if err := cs2.Delete("svc", "kim"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs2.Get("svc", "kim"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Errorf("after Delete: got %v, want ErrCredentialNotFound", err)
+	}
+	// Writes go through a temporary file that is renamed into place.
+	if entries, err := os.ReadDir(filepath.Dir(path)); err != nil || len(entries) != 1 {
+		t.Errorf("directory holds %v, %v; want only the store", entries, err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("store file: %v, %v; want mode 0600", fi, err)
+	}
+
+	bad := EncryptedFileStore(path, pass("guess"))
+	if _, err := bad.Get("svc", "pat"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("wrong passphrase: got %v", err)
+	}
+}
+
+func TestSecretService(t *testing.T) {
+	items := map[string]string{}
+	locked := false
+	defer func(f func(string, string, ...string) (string, int, error)) { runKeychainCommand = f }(runKeychainCommand)
+	runKeychainCommand = func(stdin, name string, args ...string) (string, int, error) {
+		if name != "secret-tool" {
+			t.Fatalf("ran %s", name)
+		}
+		key := strings.Join(args[len(args)-4:], " ")
+		switch args[0] {
+		case "store":
+			items[key] = stdin
+		case "lookup":
+			v, ok := items[key]
+			if !ok {
+				return "", 1, errors.New("exit status 1")
+			}
+			return v, 0, nil
+		case "clear":
+			if _, ok := items[key]; !ok || locked {
+				return "", 1, errors.New("exit status 1")
+			}
+			delete(items, key)
+		}
+		return "", 0, nil
+	}
+
+	ts := CredentialTokenStore(secretService{}, "prog", "default")
+	if _, err := ts.Load(); !errors.Is(err, ErrNotLoggedIn) {
+		t.Fatalf("got %v, want ErrNotLoggedIn", err)
+	}
+	if err := ts.Save(&Token{AccessToken: "tok", Account: "pat"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := items["service prog account default"]; !strings.Contains(got, `"access_token":"tok"`) {
+		t.Errorf("stored %q", got)
+	}
+	tok, err := ts.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "tok" || tok.Account != "pat" {
+		t.Errorf("got %+v", tok)
+	}
+	if err := ts.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.Load(); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("after Delete: got %v, want ErrNotLoggedIn", err)
+	}
+
+	// A failure to clear an item that exists is an error.
+	if err := ts.Save(&Token{AccessToken: "tok"}); err != nil {
+		t.Fatal(err)
+	}
+	locked = true
+	if err := ts.Delete(); err == nil {
+		t.Error("Delete with a locked keyring: got nil, want error")
+	}
+}
+
+func TestMacKeychainSet(t *testing.T) {
+	defer func(f func(string, string, ...string) (string, int, error)) { runKeychainCommand = f }(runKeychainCommand)
+	var gotStdin string
+	var gotArgs []string
+	runKeychainCommand = func(stdin, name string, args ...string) (string, int, error) {
+		gotStdin, gotArgs = stdin, append([]string{name}, args...)
+		return "", 0, nil
+	}
+	if err := (macKeychain{}).Set("prog", "pat", `s3"c\ret`); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"security", "-i"}; !cmp.Equal(gotArgs, want) {
+		t.Errorf("args: got %q, want %q", gotArgs, want)
+	}
+	if want := `add-generic-password -U -s "prog" -a "pat" -w "s3\"c\\ret"` + "\n"; gotStdin != want {
+		t.Errorf("stdin: got %q, want %q", gotStdin, want)
+	}
+	// A line break would end the command.
+	for _, words := range [][3]string{
+		{"prog", "pat", "a\nb"},
+		{"prog", "a\nb", "s"},
+		{"prog\r", "pat", "s"},
+	} {
+		if err := (macKeychain{}).Set(words[0], words[1], words[2]); err == nil {
+			t.Errorf("%q: got nil, want error", words)
+		}
+	}
+
+	// Hostile names and secrets stay single words, and can't add options or
+	// commands.
+	for _, words := range [][3]string{
+		{`x" -w "evil`, `pat`, `s`},
+		{`prog`, `a\`, `b"`},
+		{`\"; delete-generic-password -s "prog`, `-a`, `-w`},
+		{`pro g`, `"`, `\\"\"`},
+		{``, `'`, `$(rm -rf /)`},
+	} {
+		if err := (macKeychain{}).Set(words[0], words[1], words[2]); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"add-generic-password", "-U", "-s", words[0], "-a", words[1], "-w", words[2]}
+		if got := splitSecurityCommand(t, gotStdin); !cmp.Equal(got, want) {
+			t.Errorf("%q: got words %q, want %q", words, got, want)
+		}
+	}
+}
+
+// splitSecurityCommand splits a line of input to the interactive mode of
+// the security program into words, as it does: words are separated by
+// spaces, and within double quotes a backslash escapes the next character.
+func splitSecurityCommand(t *testing.T, line string) []string {
+	t.Helper()
+	if !strings.HasSuffix(line, "\n") || strings.Count(line, "\n") != 1 {
+		t.Fatalf("not a single line: %q", line)
+	}
+	var words []string
+	var w strings.Builder
+	inWord, quoted := false, false
+	for i := 0; i < len(line)-1; i++ {
+		ch := line[i]
+		switch {
+		case quoted && ch == '\\':
+			i++
+			if i == len(line)-1 {
+				t.Fatalf("backslash at end of line: %q", line)
+			}
+			w.WriteByte(line[i])
+		case ch == '"':
+			quoted = !quoted
+			inWord = true
+		case !quoted && ch == ' ':
+			if inWord {
+				words = append(words, w.String())
+				w.Reset()
+				inWord = false
+			}
+		default:
+			w.WriteByte(ch)
+			inWord = true
+		}
+	}
+	if quoted {
+		t.Fatalf("unterminated quote: %q", line)
+	}
+	if inWord {
+		words = append(words, w.String())
+	}
+	return words
+}
@@ -0,0 +1,171 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defining commands from data.
+
+// A CommandDef describes a command in a declarative command tree.
+// See Command.Load.
+type CommandDef struct {
+	Name     string        `json:"name"`
+	Usage    string        `json:"usage"`
+	Action   string        `json:"action"`   // name of the Action that runs the command; empty for a group
+	Fields   []FieldDef    `json:"fields"`   // flags and arguments
+	Commands []*CommandDef `json:"commands"` // sub-commands
+}
+
+// A FieldDef describes a flag or positional argument of a CommandDef.
+// It corresponds to a field of a command's struct.
+type FieldDef struct {
+	// The name of the flag or argument. It is also the key of the field's
+	// value in the map passed to the Action.
+	Name string `json:"name"`
+
+	// The type of the field: one of "string", "bool", "int", "int64", "uint",
+	// "float64" or "duration", or "[]" followed by one of those, or
+	// "map[string]string".
+	Type string `json:"type"`
+
+	// If true, the field is a flag. Otherwise it is a positional argument.
+	Flag bool `json:"flag"`
+
+	// Other keys for the field's tag, like "oneof=a|b" or "opt=".
+	// See the package documentation for the syntax.
+	Tag string `json:"tag"`
+
+	// The usage string of the field.
+	Doc string `json:"doc"`
+}
+
+// An Action runs a command defined by a CommandDef. It is passed the values
+// of the command's flags and arguments, keyed by name.
+type Action func(ctx context.Context, values map[string]interface{}) error
+
+var fieldDefTypes = map[string]reflect.Type{
+	"string":            reflect.TypeOf(""),
+	"bool":              reflect.TypeOf(false),
+	"int":               reflect.TypeOf(0),
+	"int64":             reflect.TypeOf(int64(0)),
+	"uint":              reflect.TypeOf(uint(0)),
+	"float64":           reflect.TypeOf(0.0),
+	"duration":          reflect.TypeOf(time.Duration(0)),
+	"map[string]string": reflect.TypeOf(map[string]string(nil)),
+}
+
+// Load reads a JSON-encoded list of CommandDefs from r and registers them as
+// sub-commands of c. Each command with an Action runs the function of that
+// name in actions. Load lets programs define simple commands, like wrappers
+// for other tools, without writing Go code for each.
+func (c *Command) Load(r io.Reader, actions map[string]Action) error {
+	var defs []*CommandDef
+	if err := json.NewDecoder(r).Decode(&defs); err != nil {
+		return fmt.Errorf("cli.Load: %w", err)
+	}
+	for _, d := range defs {
+		if err := c.registerDef(d, actions); err != nil {
+			return fmt.Errorf("cli.Load: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Command) registerDef(d *CommandDef, actions map[string]Action) error {
+	sub := &Command{Name: d.Name, Usage: d.Usage}
+	var v reflect.Value // the struct holding the command's fields
+	if len(d.Fields) > 0 {
+		var err error
+		v, err = structForFields(d.Fields)
+		if err != nil {
+			return fmt.Errorf("command %q: %w", d.Name, err)
+		}
+		sub.Struct = v.Addr().Interface()
+	}
+	if d.Action != "" {
+		action, ok := actions[d.Action]
+		if !ok {
+			return fmt.Errorf("command %q: no action named %q", d.Name, d.Action)
+		}
+		sub.runner = RunFunc(func(ctx context.Context) error {
+			values := map[string]interface{}{}
+			if len(d.Fields) > 0 {
+				// The running command may be a copy of sub; see Reentrant.
+				v := reflect.ValueOf(FromContext(ctx).Struct).Elem()
+				for i, f := range d.Fields {
+					values[f.Name] = v.Field(i).Interface()
+				}
+			}
+			return action(ctx, values)
+		})
+	}
+	if err := c.register(sub); err != nil {
+		return err
+	}
+	for _, sd := range d.Commands {
+		if err := sub.registerDef(sd, actions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structForFields returns a new struct value with a tagged field for each FieldDef.
+func structForFields(fds []FieldDef) (reflect.Value, error) {
+	var sfs []reflect.StructField
+	for i, fd := range fds {
+		if fd.Name == "" {
+			return reflect.Value{}, fmt.Errorf("field %d has no name", i)
+		}
+		t, ok := fieldDefTypes[fd.Type]
+		if !ok && strings.HasPrefix(fd.Type, "[]") {
+			if et, ok2 := fieldDefTypes[fd.Type[2:]]; ok2 && et.Kind() != reflect.Map {
+				t, ok = reflect.SliceOf(et), true
+			}
+		}
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("field %q: unknown type %q", fd.Name, fd.Type)
+		}
+		var tag string
+		if fd.Flag {
+			tag = "flag=" + fd.Name
+		} else {
+			tag = "name=" + strings.ToUpper(fd.Name)
+		}
+		if fd.Tag != "" {
+			tag += ", " + fd.Tag
+		}
+		if fd.Doc != "" {
+			// The doc comes last and without a key, so it can contain commas.
+			tag += ", " + fd.Doc
+		}
+		sfs = append(sfs, reflect.StructField{
+			// The Go name doesn't matter, but it must be unique and exported.
+			Name: fmt.Sprintf("F%d_%s", i, goName(fd.Name)),
+			Type: t,
+			Tag:  reflect.StructTag("cli:" + strconv.Quote(tag)),
+		})
+	}
+	return reflect.New(reflect.StructOf(sfs)).Elem(), nil
+}
+
+// goName returns the ASCII letters and digits of s,
+// suitable as part of a Go identifier.
+func goName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	const defs = `[
+		{
+			"name": "tools",
+			"usage": "wrapped tools",
+			"commands": [
+				{
+					"name": "fetch",
+					"usage": "fetch some URLs",
+					"action": "fetch",
+					"fields": [
+						{"name": "retries", "type": "int", "flag": true, "doc": "number of retries, at most 5"},
+						{"name": "env", "type": "string", "flag": true, "tag": "oneof=dev|prod"},
+						{"name": "urls", "type": "[]string", "tag": "min=1"}
+					]
+				}
+			]
+		}
+	]`
+	var got string
+	actions := map[string]Action{
+		"fetch": func(ctx context.Context, values map[string]interface{}) error {
+			got = fmt.Sprintf("%v %v %v", values["retries"], values["env"], values["urls"])
+			return nil
+		},
+	}
+	top := Top(nil)
+	if err := top.Load(strings.NewReader(defs), actions); err != nil {
+		t.Fatal(err)
+	}
+	if err := top.Run(context.Background(), []string{"tools", "fetch", "-retries", "3", "u1", "u2"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "3  [u1 u2]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	f := top.findSub("tools").findSub("fetch")
+	if got, want := f.flags.Lookup("retries").Usage, "number of retries, at most 5"; got != want {
+		t.Errorf("usage: got %q, want %q", got, want)
+	}
+	if got, want := f.formals[0].Name, "URLS"; got != want {
+		t.Errorf("arg name: got %q, want %q", got, want)
+	}
+
+	err := Top(nil).Load(strings.NewReader(`[{"name": "x", "action": "nope"}]`), actions)
+	if err == nil || !strings.Contains(err.Error(), `no action named "nope"`) {
+		t.Errorf("got %v, want missing action error", err)
+	}
+}
@@ -50,17 +50,45 @@ or more succinctly,
 
 That code can be put in an init method or at the start of main.
 
+Additional structs passed to Command or Register add their fields to the
+command's flags and arguments. That lets several commands share a set of
+options:
+
+	top.Command("fetch", &fetch{}, "fetch a file", &authOpts)
+
+Call [Command.SetVersion] on the top-level command to add a "-version" flag
+and, if it is a group of commands, a "version" sub-command.
+
+Commands can add health checks with [Command.AddCheck], and
+[Command.AddDoctor] adds a "doctor" command that runs them all.
+
+Every group of commands has an implicit "help" sub-command: "prog help compare"
+prints the same usage message as "prog compare -h". Registering a sub-command
+named "help" replaces it.
+
+A group of commands can name one of its sub-commands as the default with
+[Command.Default]; it runs when the group is invoked with no sub-command.
+
 The Top function takes a Command just like the RegisterCommand function, so you
 can provide behavior for the top-level command by defining a struct with a Run
 method, constructing a Command with it, and passing it to Top.
 
+Commands can also be defined by data instead of code. [Command.Load] reads a
+JSON description of a command tree and registers it, binding each command to a
+Go function by name.
+
+To convert a program written with the standard flag package, call
+[GenFromFlagSet] from it once its flags are defined. It writes a program that
+defines the same flags with a tagged struct, as a place to start.
+
 # Struct Tags
 
 The struct associated with a command completely describes the command's flags
 and positional arguments. Each exported field can have a struct tag with a "cli"
 key that provides the usage documentation for the argument or flag as well as
 some options. An exported field without a tag is treated as a positional
-argument with no documentation. Unexported fields are ignored.
+argument with no documentation. Unexported fields, and fields with the tag
+`cli:"-"`, are ignored.
 
 A field's type can be any string, bool, integer, floating point or duration
 type, or a slice of one of those types. If the slice is used for a flag, the
@@ -68,18 +96,81 @@ flag's value is split on commas to populate the slice. Otherwise, the slice
 field must represent the last positional argument, and its value is taken from
 the remaining command-line arguments.
 
+A field whose pointer type implements [flag.Value] is registered directly with
+the flag set if it is a flag, and parsed with its Set method if it is a
+positional argument. So custom flag types written for the standard flag package
+can be used as is.
+
+A field whose type implements [encoding.TextUnmarshaler], or whose pointer type
+does, is parsed with its UnmarshalText method. That includes [net.IP] and
+[time.Time].
+
+A field can also be a map whose keys and values are of those types. Its value
+is written as comma-separated key=value pairs, as in "-label a=1,b=2". A map
+flag can be repeated; the first occurrence replaces the default or configured
+map, and later ones add to it.
+
 The tag syntax is a comma-separated lists of key=value pairs. The keys are:
 
   - flag:  The field is a flag. The value is the flag's name; if empty, the lower-cased
-    field name is used.
+    field name is used. Aliases can follow the name, separated by "|":
+    "flag=v|verbose" defines both -v and -verbose.
   - name:  The value is the name of the positional argument, used in documentation.
     If empty, the upper-cased field name is used.
   - doc:   The value is the usage string. This key can be omitted when the usage string
     is last.
   - opt:   This and the following positional arguments are optional.
   - oneof: The value is a "|"-separated list of strings that the provided value
-    must match. A field with "oneof" must be of type string.
+    must match. A field with "oneof" must be of type string. The value can
+    also be "@" followed by the name of a list of choices defined with
+    [Command.DefineChoices] or in [Command.Choices] on the command or one
+    above it.
   - min:   For positional slice fields, the minimum number of arguments.
+  - match: For string fields, or slices of them, a regular expression that
+    values must match.
+  - matchmsg: The error message for a value that doesn't match.
+  - normalize: For string fields, or slices or maps of them, a "|"-separated
+    list of conversions applied to each value before it is checked and stored:
+    "trim" removes surrounding white space, "lower" and "upper" change case,
+    and "path" cleans a file path with [path/filepath.Clean].
+  - minval, maxval: For numeric and duration fields, or slices of them, the
+    smallest and largest allowed values. Values out of range are usage errors.
+  - sep:   For slice flags and maps, the separator between elements or
+    key=value pairs, instead of a comma. Use a quoted value for a space.
+  - accumulate: For slice flags, repeating the flag appends to the values
+    of earlier occurrences instead of replacing them, as in
+    "-tag a -tag b". Write it as "accumulate" or "accumulate=".
+  - prefix: The field is a struct whose fields are all flags. The value is
+    prepended to their names, so a field tagged "prefix=db." containing a
+    flag named "host" defines the flag "-db.host".
+  - xor:   The value names a group of mutually exclusive flags. It is a usage
+    error to set more than one flag of the group.
+  - requires: The value is a "|"-separated list of flag names. It is a usage
+    error to set this flag without setting those.
+  - count: The flag is an integer that counts the number of times it appears,
+    as in "-v -v -v". It takes no value; write it as "count" or "count=".
+  - env:   The value is the name of an environment variable that sets the
+    flag when it is not empty, as in "env=SERVER_PORT".
+  - config: The value is a dot-separated key, like "server.port", whose value
+    in the configuration file sets the flag. See [Command.LoadConfig].
+  - secret: The flag holds a credential, like a password or token. Its default
+    is shown as "(hidden)" in help and in the command's [Spec], and invalid
+    values are left out of error messages. Write it as "secret" or "secret=".
+  - deprecated: The flag is deprecated. The value is a message saying what to
+    use instead. Setting the flag prints a warning.
+  - type: For string fields, or slices of them, the kind of file path the
+    value must be: "existingfile" or "existingdir" for a file or directory
+    that exists, or "glob:" followed by a pattern, like "glob:*.json", that
+    the last element of the path must match. Values are checked when they are
+    parsed, and shell completion suggests matching paths.
+  - raw:   The field, which must be a []string, receives the arguments after
+    the first "--", exactly as given, even if they look like flags. It is
+    neither a flag nor a positional argument, and its command cannot have
+    sub-commands. It is for commands that run other programs, as in
+    "prog run -v -- ./server -port 8080". Write it as "raw" or "raw=".
+  - stdin: The value must be "json". The field is neither a flag nor an argument;
+    if standard input is not a terminal, JSON is decoded from it into the field
+    before the command runs. Only one field of a command can have this key.
 
 For example, the field and struct tag
 
@@ -89,7 +180,18 @@ will display the argument as ENV in documentation along with the string
 "development environment", and will check that the value on the command line is
 either "dev" or "prod".
 
-See the package examples for more.
+A value can be enclosed in single quotes, so that it can contain commas or
+text that looks like a key. Two single quotes in a row stand for one. The
+usage string at the end of a tag can be quoted too:
+
+	Labels map[string]string `cli:"flag=label, 'key=value pairs, comma-separated'"`
+	Name   string            `cli:"matchmsg='can''t contain spaces', match=^\\S+$"`
+
+Flags and arguments can also be defined without tags, with a builder:
+
+	c.Flag("env").Doc("environment").OneOf("dev", "prod").Bind(&env)
+
+See [ParamBuilder] for details, and the package examples for more.
 
 The Go flag package provides control over the word printed as the flag's value in documentation,
 by looking for backticks in the usage string. To use this feature, enclose the struct tag
@@ -114,11 +216,227 @@ init functions, then the entire main function can be
 For more control, you can call Command.Run with a context and a slice of arguments,
 and handle the error yourself.
 
+Flags of a command without sub-commands can appear before, after or among its
+positional arguments, as with GNU tools. An argument of "--" ends the flags.
+Set StrictOrder on a command to end them at the first positional argument
+instead, as the standard flag package does.
+
+Set CombineShortFlags on the top-level command to let single-letter flags be
+grouped, as in "-rf" for "-r -f", with a value attached to the last one, as in
+"-n5".
+
+Set the Stdin, Stdout and Stderr fields of a Command to redirect its input and
+output, and that of its sub-commands. Usage messages and errors are written to
+them as well, so a program can be tested without replacing os.Stdout.
+Set Reentrant on the top-level command to run each command on a new copy of
+its struct, so runs can't leave state behind and can happen concurrently.
+[Command.AddBatchCommand] adds a "batch" command that runs several command
+lines that way, at once if they share no values, and reports how each went.
+For table-driven tests, [Command.ExecuteCapture] runs a command line as Main
+would and returns its output and exit code, starting each time from the
+flag and argument values the commands were registered with.
+
+Scripts that wrap a program can set the ParseableErrors field of the top-level
+Command to get usage errors whose first line has a stable, versioned format,
+like "usage-error/1: prog sub: flag provided but not defined: -x".
+
+Set ResponseFiles on the top-level Command to let users write long command
+lines in a file: an argument like "@args.txt" is replaced by the arguments
+in args.txt.
+
+Set HandleSignals on the top-level Command to have Main cancel the command's
+context on SIGINT or SIGTERM and exit with the conventional status, 130 or 143.
+Commands that compute for a long time without blocking should call
+[CheckCancel] in their loops, or loop with [Each], so that they stop promptly;
+both return [ErrInterrupted] once the context is canceled.
+
+A program can also act like busybox, running a sub-command directly when it is
+invoked under that sub-command's name. Set the Applet field of each such
+sub-command, and install symbolic links to the program with their names.
+
+A program can read settings from a configuration file with
+[Command.LoadConfig]. JSON is built in; for YAML, TOML or another format, the
+program supplies a decoder with [RegisterConfigFormat], so that this package
+doesn't depend on any parser for them. Flags tagged with "config" and "env"
+take their values from the file and the environment. A flag's value comes from
+the first of these that provides one: the command line, the environment, the
+configuration file, and finally the field's initial value. Values from the
+environment or the file don't count as set for [Command.Changed].
+[Command.AddConfigFlag] adds a "-config" flag for choosing the file, which is
+otherwise found in standard places like $HOME/.PROG.json.
+
+A Run method can get its Command with [FromContext], and ask it whether a flag
+was set on the command line with [Command.Changed]:
+
+	if cli.FromContext(ctx).Changed("limit") {
+	  cfg.Limit = c.Limit
+	}
+
+Helpers shared by many commands, like those that log or report errors, can
+use [FromContext] and [RawArgs] to find out what was run.
+
+Setup that applies to many commands, like configuring logging, can be
+registered once with [Command.BeforeRun]; it runs before the command and any
+of its sub-commands. Similarly, [Command.Use] adds middleware that wraps the
+execution of a command and its sub-commands.
+
+When a value is missing, a command can ask for it with [Select],
+[MultiSelect] or [Input], which prompt on the terminal using the choices and
+checks declared in the field's tag. They fail if the command is not
+[Interactive]: if standard input is not a terminal, or if the user passed the
+"-no-input" flag added by [Command.AddNoInputFlag]. [OpenURL] similarly opens
+a web page only for an interactive command, and otherwise prints its URL.
+A command that can't work without a terminal should set RequiresTTY, so that
+it fails at once with [ErrNotInteractive] when it isn't interactive, and
+NonInteractiveHint, to tell script writers what to do instead.
+
+A command whose result users will paste elsewhere, like a token, can also
+copy it to the clipboard with [Copy]. Call [Command.AddCopyFlag] to make that
+happen only when the user passes "-copy".
+
+Commands that write files can use [WriteFileAtomic], so that a failure never
+leaves a file half written. With [Command.AddDryRunFlag], the user can pass
+"-dry-run" to see what would be written instead; commands check it with
+[DryRun].
+
+Programs that talk to an API can call [Command.AddAuthCommands] for "auth
+login", "auth status" and "auth logout" commands. They get a token from a
+[TokenSource], like [DeviceFlow] for the OAuth device authorization grant, and
+keep it in a [TokenStore], like the one from [DefaultTokenStore], which uses
+the system's keychain when there is one.
+
+[Command.AddTransportFlags] adds the "-proxy", "-cacert" and
+"-insecure-skip-verify" flags of [TransportOptions], and [HTTPClient] returns
+a client that honors them, so all the commands of a program connect to
+servers the same way.
+
+Commands that list results from a server a page at a time can take the
+"-limit", "-page-size" and "-all" flags of a [PageOptions] bundle, and fetch
+with a [Pager] or [Paginate], which stop at the limit and tell the user on
+standard error when they do. The "-filter" flag of a [FilterOptions] bundle
+takes a [Filter], like "status=open,assignee!=me", that commands check each
+result against. And the "-fields" flag of a [FieldsOptions] bundle makes
+[WriteJSON] show only the selected fields of results; see [Project]. The
+"-query" flag of a [QueryOptions] bundle extracts values from them with a
+jq-like expression, like ".items[].name"; see [Query]. Results meant for
+people can be written as a [Table] with [WriteTable], which aligns columns
+and fits the table to the terminal; the "-no-trunc" flag of a
+[TableOptions] bundle turns off truncation.
+
+Commands that only read can take the "-watch" flag of a [WatchOptions]
+bundle, which runs the command again every few seconds, clearing the screen
+or showing only what changed, until the user interrupts it. The
+"-wait-until" flag of a [WaitOptions] bundle instead runs it until its JSON
+output matches a [Filter], or fails with exit code 4 after a timeout.
+
+The "-timeout" flag of a [TimeoutOptions] bundle limits how long a command
+runs. A command that runs out of time fails with a [TimeoutError], which says
+whether it was in a Before method, Run or an After method at the time, and how
+long each took.
+
+Long-running commands can report their progress as a stream of events with
+[Emit]. The "-output" flag of an [OutputOptions] bundle chooses whether the
+events are written as text for people or as lines of JSON for programs.
+
+Secrets are better kept in a [CredentialStore] than in plain files.
+[KeychainStore] uses the operating system's keychain, and [EncryptedFileStore]
+encrypts secrets with a passphrase on systems without one.
+[CredentialTokenStore] lets the auth commands use either.
+
+Commands can report problems that are not fatal with [Warnf]. A program that
+calls [Command.AddStrictFlag] gets a "-strict" flag that makes any warning
+cause a non-zero exit, which is useful for catching drift in CI.
+
+Commands that need scratch space can call [TempDir], which creates a
+directory on first use and removes it when the command finishes, even if it
+panics.
+
+The same commands can be served over HTTP with [Handler], which maps a JSON
+object of flag and argument values to a command line.
+
+# Documentation
+
+Usage messages can be made easier to follow with a screen reader by setting
+the Accessible field of the top-level command, or the ACCESSIBLE environment
+variable.
+
+To change the layout of usage messages, set the UsageWriter field of the
+top-level command. [UsageTemplate] makes a [UsageWriter] from a text/template
+that arranges the generated parts of the message, like the list of flags;
+[DefaultUsageTemplate] is a place to start.
+
+Usage messages and errors written to a terminal are styled with color and
+emphasis, unless the NO_COLOR environment variable is set, TERM is "dumb" or
+CLICOLOR is "0". Setting CLICOLOR_FORCE styles them even when they aren't
+written to a terminal, as in CI logs. The Color field of the top-level command
+can turn styling on or off regardless of the environment.
+
+Usage messages written to a terminal are wrapped to its width, which the
+COLUMNS environment variable overrides. Set HelpWidth on the top-level
+command to choose a width yourself, or to turn off wrapping.
+
+Usage messages list flags sorted by name, as the flag package does. Set the
+HelpOrder field of a command to list them in the order they are declared
+instead, optionally interleaved with the positional arguments.
+
+Set PageHelp on the top-level command to show help that doesn't fit on the
+terminal with a pager, as git does.
+
+A command with many sub-commands can list them under headings, like
+"Management Commands", by setting their Category fields. The CategoryOrder
+field of the command above them orders the headings.
+
+[Command.Spec] returns a description of a command tree that programs can use
+to generate documentation. [GenManPages] writes man pages from it, and
+[GenMarkdownTree] writes linked Markdown files. [SecurityReport] lists the
+flags that look like they take secrets, file names or URLs, for review.
+[BreakingChanges] compares a saved Spec with the current one and reports
+changes that could break existing scripts, for use in release checks.
+
+The Long field of a Command holds a description of one or more paragraphs
+that follows the Usage line in the command's usage message, its man page and
+its Markdown documentation.
+
+The Examples field of a Command documents sample invocations with their
+output. They are listed under "Examples:" in the command's usage message.
+[Command.RunExamples] runs them with captured output, so a test can
+keep them from going stale. Commands should read and write through [Stdin],
+[Stdout] and [Stderr] for their output to be captured.
+[Command.AddLearnCommand] adds a "learn" command that presents the examples to
+new users as a guided tour.
+
 # Completion
 
 Shell completion for common shells is supported with the
 github.com/posener/complete/v2 package. Completion logic is automatically
 invoked if your program calls Command.Main. To install completion for a program,
 run it with the COMP_INSTALL environment variable set to 1.
+
+Positional arguments with a "type" tag key complete file or directory names,
+and those with "oneof" complete their choices. For anything else, the
+command's struct can implement
+
+	Complete(field, prefix string) []string
+
+where field is the name of the argument's struct field. It should return the
+possible values that start with prefix, or nil to fall back to the choices or
+paths that the argument's tags allow.
+
+Similarly, flags with "oneof" complete their choices and those with "type"
+complete file paths. The struct can compute the values of a flag at
+completion time, like the names of live resources, by implementing
+
+	PredictFlag(name, prefix string) []string
+
+where name is the flag's name without the leading hyphen. Returning nil falls
+back to the flag's choices, if any.
+
+Completion through posener/complete runs the program each time a word is
+completed. For shells it doesn't support, or where that is undesirable,
+GenCompletionScript writes a self-contained script for bash, zsh, fish or
+PowerShell that completes sub-command and flag names. Command.AddCompletionCommand
+adds a "completion" sub-command that writes the script:
+
+	prog completion bash > /etc/bash_completion.d/prog
 */
 package cli
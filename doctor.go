@@ -0,0 +1,127 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+)
+
+// Health checks and the doctor command.
+
+// A CheckStatus is the outcome of a health check.
+type CheckStatus int
+
+// The outcomes of a health check.
+const (
+	CheckPass CheckStatus = iota
+	CheckWarn
+	CheckFail
+)
+
+var checkStatusNames = []string{"pass", "warn", "fail"}
+
+// String returns "pass", "warn" or "fail".
+func (s CheckStatus) String() string {
+	if s < 0 || int(s) >= len(checkStatusNames) {
+		return fmt.Sprintf("CheckStatus(%d)", int(s))
+	}
+	return checkStatusNames[s]
+}
+
+// MarshalText implements encoding.TextMarshaler, so a CheckStatus appears in
+// JSON as its name.
+func (s CheckStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *CheckStatus) UnmarshalText(b []byte) error {
+	for i, n := range checkStatusNames {
+		if n == string(b) {
+			*s = CheckStatus(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown check status %q", b)
+}
+
+// A CheckFunc is a health check. It returns the outcome and a message
+// explaining it, which may be empty for a passing check.
+type CheckFunc func(ctx context.Context) (CheckStatus, string)
+
+// A CheckResult is the result of running a health check.
+type CheckResult struct {
+	Command string      `json:"command"` // path of the command that added the check
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message,omitempty"`
+}
+
+type check struct {
+	name string
+	f    CheckFunc
+}
+
+// AddCheck adds a health check to c, to be run by the doctor command.
+// Typically a command adds checks for what it depends on, like a readable
+// configuration file or valid credentials.
+func (c *Command) AddCheck(name string, f CheckFunc) {
+	c.checks = append(c.checks, check{name, f})
+}
+
+// RunChecks runs the health checks of c and all its sub-commands, in order,
+// and returns their results.
+func (c *Command) RunChecks(ctx context.Context) []CheckResult {
+	var rs []CheckResult
+	for _, ch := range c.checks {
+		st, msg := ch.f(ctx)
+		rs = append(rs, CheckResult{Command: c.Path(), Name: ch.name, Status: st, Message: msg})
+	}
+	for _, s := range c.subs {
+		rs = append(rs, s.RunChecks(ctx)...)
+	}
+	return rs
+}
+
+// AddDoctor registers a sub-command of c named "doctor" that runs the health
+// checks of c and all its sub-commands and reports the results, one per line.
+// With the -json flag, it writes the results as a JSON array of CheckResult.
+// The command fails if any check fails.
+func (c *Command) AddDoctor() *Command {
+	d := &doctor{top: c}
+	return c.Command("doctor", d, "check the health of the program's environment")
+}
+
+type doctor struct {
+	JSON bool `cli:"flag=json, write results as JSON"`
+	top  *Command
+}
+
+func (d *doctor) Run(ctx context.Context) error {
+	rs := d.top.RunChecks(ctx)
+	if d.JSON {
+		if err := WriteJSON(ctx, rs); err != nil {
+			return err
+		}
+	} else {
+		w := invocationOrDefault(ctx).stdout
+		for _, r := range rs {
+			fmt.Fprintf(w, "%s  %s: %s", r.Status, r.Command, r.Name)
+			if r.Message != "" {
+				fmt.Fprintf(w, ": %s", r.Message)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	failed := 0
+	for _, r := range rs {
+		if r.Status == CheckFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(rs))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDoctor(t *testing.T) {
+	newTop := func(fail bool) *Command {
+		top := initFlags(&Command{Name: "prog"})
+		top.AddCheck("config", func(context.Context) (CheckStatus, string) {
+			return CheckPass, ""
+		})
+		sub := top.Command("sub", &c1{}, "")
+		sub.AddCheck("creds", func(context.Context) (CheckStatus, string) {
+			if fail {
+				return CheckFail, "expired"
+			}
+			return CheckWarn, "expire soon"
+		})
+		top.AddDoctor()
+		return top
+	}
+	run := func(top *Command, args ...string) (string, error) {
+		var out bytes.Buffer
+		ctx := withInvocation(context.Background(), &invocation{stdout: &out, stderr: io.Discard})
+		err := top.Run(ctx, args)
+		return out.String(), err
+	}
+
+	got, err := run(newTop(false), "doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "pass  prog: config\nwarn  prog sub: creds: expire soon\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	got, err = run(newTop(true), "doctor", "-json")
+	if err == nil || err.Error() != "1 of 2 checks failed" {
+		t.Errorf("got %v, want failure", err)
+	}
+	var rs []CheckResult
+	if err := json.Unmarshal([]byte(got), &rs); err != nil {
+		t.Fatal(err)
+	}
+	wantRs := []CheckResult{
+		{Command: "prog", Name: "config", Status: CheckPass},
+		{Command: "prog sub", Name: "creds", Status: CheckFail, Message: "expired"},
+	}
+	if diff := cmp.Diff(wantRs, rs); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Streaming events from long-running commands.
+
+// An Event is something that happened while a command ran, like progress on
+// a long task. Commands report events with Emit.
+type Event struct {
+	Time    time.Time   `json:"time"`
+	Type    string      `json:"type"`              // the kind of event, like "progress" or "done"
+	Message string      `json:"message,omitempty"` // a description for people
+	Data    interface{} `json:"data,omitempty"`    // details for programs; must be encodable as JSON
+}
+
+// OutputOptions is a bundle with an "-output" flag that chooses how the
+// events of a command are written: "text", the default, for people, or
+// "json", for programs. Pass a *OutputOptions to Command or Register.
+type OutputOptions struct {
+	Output string `cli:"flag=output, oneof=text|json, 'how to write events: text for people, json for programs'"`
+}
+
+// Emit writes e to the command's standard output. If the command has an
+// OutputOptions bundle whose flag is "json", e is written as a line of JSON.
+// Otherwise it is written as a line of text: its Message, or if that is empty,
+// its Type and Data. If e.Time is zero, it is set to the current time.
+//
+// Emit can be called from several goroutines at once; each event is written
+// on its own line.
+func Emit(ctx context.Context, e Event) error {
+	if e.Time.IsZero() {
+		e.Time = timeNow()
+	}
+	inv := invocationOrDefault(ctx)
+	var line string
+	if o := bundleFor[OutputOptions](inv.cmd); o != nil && o.Output == "json" {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		line = string(data)
+	} else {
+		line = e.text()
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	_, err := fmt.Fprintln(inv.stdout, line)
+	return err
+}
+
+// text returns the form of e for people.
+func (e Event) text() string {
+	s := e.Message
+	if s == "" {
+		s = e.Type
+		if e.Data != nil {
+			s += ": " + fmt.Sprint(e.Data)
+		}
+	}
+	// Keep the event on one line.
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// timeNow is time.Now, replaced in tests.
+var timeNow = time.Now
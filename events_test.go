@@ -0,0 +1,48 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type progresser struct{}
+
+func (*progresser) Run(ctx context.Context) error {
+	for _, e := range []Event{
+		{Type: "start", Message: "copying 2 files"},
+		{Type: "progress", Data: map[string]int{"done": 1}},
+		{Type: "done"},
+	} {
+		if err := Emit(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestEmit(t *testing.T) {
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC) }
+
+	top := initFlags(&Command{Name: "prog"})
+	top.Command("copy", &progresser{}, "copy files", &OutputOptions{})
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"copy"}, "copying 2 files\nprogress: map[done:1]\ndone\n"},
+		{[]string{"copy", "-output", "text"}, "copying 2 files\nprogress: map[done:1]\ndone\n"},
+		{[]string{"copy", "-output", "json"}, `{"time":"2021-03-04T05:06:07Z","type":"start","message":"copying 2 files"}
+{"time":"2021-03-04T05:06:07Z","type":"progress","data":{"done":1}}
+{"time":"2021-03-04T05:06:07Z","type":"done"}
+`},
+	} {
+		stdout, stderr, _ := top.ExecuteCapture(context.Background(), test.args...)
+		if stdout != test.want {
+			t.Errorf("%v: got\n%s\nwant\n%s\n(stderr %q)", test.args, stdout, test.want, stderr)
+		}
+	}
+}
@@ -128,13 +128,13 @@ func Example_subs() {
 	// b &{2}
 	// subs: missing sub-command
 	// Usage:
-	// cli.test [flags] subs [flags]    doc for subs
+	// cli.test [flags] subs [flags] <command>    doc for subs
 	//   -f value
 	//     	a flag
 	//
-	// cli.test [flags] subs [flags] a A
-	//   doc for a
+	// Commands:
+	//   a  doc for a
+	//   b  doc for b
 	//
-	// cli.test [flags] subs [flags] b B
-	//   doc for b
+	// Run "cli.test subs help <command>" for details about a command.
 }
@@ -0,0 +1,115 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Running the examples of commands.
+
+// An Example shows a use of a command and the output it produces.
+// Examples are documentation, and RunExamples keeps them accurate.
+type Example struct {
+	Doc    string   // what the example shows
+	Args   []string // command-line arguments, following the command's name
+	Stdin  string   // standard input to the command
+	Output string   // expected standard output
+}
+
+// RunExamples runs the Examples of c and all its sub-commands, and returns an
+// error describing each one that fails or whose standard output differs from
+// its Output. Leading and trailing white space is ignored when comparing
+// output.
+//
+// Each example is run from the top-level command, as it would be from a shell,
+// but with its standard input read from Stdin and its output captured rather
+// than written to the process's streams. For that to work, commands must use
+// Stdin, Stdout and Stderr instead of the os package's files.
+// Each example runs on new copies of the commands' structs and bundles, as if
+// Reentrant were set, so values set by one example don't affect the next.
+//
+// A typical use is in a test:
+//
+//	func TestExamples(t *testing.T) {
+//	    if err := top.RunExamples(context.Background()); err != nil {
+//	        t.Error(err)
+//	    }
+//	}
+func (c *Command) RunExamples(ctx context.Context) error {
+	var errs []error
+	for i, ex := range c.Examples {
+		if err := c.runExample(ctx, ex); err != nil {
+			errs = append(errs, fmt.Errorf("%s: example %d: %w", c.Path(), i+1, err))
+		}
+	}
+	for _, s := range c.subs {
+		if err := s.RunExamples(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// exampleArgs returns the top-level command of c's tree and the arguments
+// to pass to its Run method to run ex.
+func (c *Command) exampleArgs(ex Example) (*Command, []string) {
+	var args []string
+	top := c
+	for ; top.super != nil; top = top.super {
+		args = append([]string{top.Name}, args...)
+	}
+	return top, append(args, ex.Args...)
+}
+
+func (c *Command) runExample(ctx context.Context, ex Example) error {
+	top, args := c.exampleArgs(ex)
+	var stdout, stderr bytes.Buffer
+	inv := &invocation{
+		stdin:  strings.NewReader(ex.Stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+		fresh:  true,
+	}
+	err := top.Run(withInvocation(ctx, inv), args)
+	if err == nil {
+		err = inv.strictErr()
+	}
+	if err != nil {
+		if stderr.Len() > 0 {
+			return fmt.Errorf("%w\nstderr:\n%s", err, stderr.String())
+		}
+		return err
+	}
+	got := strings.TrimSpace(stdout.String())
+	want := strings.TrimSpace(ex.Output)
+	if got != want {
+		return fmt.Errorf("output mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	return nil
+}
+
+// commandLine returns the command line of ex, for the command with the given
+// path, as it would be typed to a shell.
+func (ex Example) commandLine(path string) string {
+	words := []string{path}
+	for _, a := range ex.Args {
+		words = append(words, shellQuote(a))
+	}
+	return strings.Join(words, " ")
+}
+
+// shellQuote returns s quoted for a POSIX shell, if it needs to be.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_./=:,@%+", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
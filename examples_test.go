@@ -0,0 +1,75 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+type greeter struct {
+	Shout bool   `cli:"flag=shout, use capital letters"`
+	Name  string `cli:"opt=, who to greet"`
+}
+
+func (g *greeter) Run(ctx context.Context) error {
+	name := g.Name
+	if name == "" {
+		b, err := io.ReadAll(Stdin(ctx))
+		if err != nil {
+			return err
+		}
+		name = strings.TrimSpace(string(b))
+	}
+	msg := "hello, " + name
+	if g.Shout {
+		msg = strings.ToUpper(msg)
+	}
+	_, err := fmt.Fprintln(Stdout(ctx), msg)
+	return err
+}
+
+func TestRunExamples(t *testing.T) {
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	g := top.Command("say", nil, "")
+	hello := g.Command("hello", &greeter{}, "")
+	hello.Examples = []Example{
+		{Doc: "read the name", Stdin: "Al\n", Output: "hello, Al"},
+		{Doc: "greet someone", Args: []string{"Pat"}, Output: "hello, Pat\n"},
+	}
+	if err := top.RunExamples(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values set by one example don't carry over to the next.
+	hello.Examples = []Example{
+		{Args: []string{"-shout", "Pat"}, Output: "HELLO, PAT"},
+		{Stdin: "Al\n", Output: "hello, Al"},
+	}
+	if err := top.RunExamples(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	hello.Examples = []Example{
+		{Args: []string{"-shout", "Pat"}, Output: "hello, Pat"},
+		{Args: []string{"-loud", "Pat"}},
+	}
+	err := top.RunExamples(context.Background())
+	if err == nil {
+		t.Fatal("got nil, want error")
+	}
+	got := err.Error()
+	for _, want := range []string{
+		"prog say hello: example 1: output mismatch",
+		"HELLO, PAT",
+		"prog say hello: example 2: hello: flag provided but not defined: -loud",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("error does not contain %q:\n%s", want, got)
+		}
+	}
+}
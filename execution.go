@@ -3,12 +3,19 @@
 package cli
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/posener/complete/v2"
 )
@@ -19,6 +26,11 @@ import (
 // the given context. It returns the exit code for the process.
 // Main returns 0 for success, 1 for an error in command execution, and 2
 // for a usage error (wrong number of arguments, unknown flag, etc.).
+// It returns 3 if the command failed for only some of the items it processed;
+// see ItemErrors. It returns 4 if a -wait-until condition timed out; see
+// WaitOptions. It returns 130 if the command failed with ErrInterrupted. If
+// c.HandleSignals is true, it returns 130 or 143 if the command failed after
+// an interrupt or termination signal.
 //
 // Typically, Main is called on the top Command with the background context, and
 // its return value is passed to os.Exit, like so:
@@ -26,7 +38,32 @@ import (
 //     var top = cli.Top(nil)
 //     os.Exit(top.Main(context.Background()))
 func (c *Command) Main(ctx context.Context) int {
-	return c.mainWithArgs(ctx, os.Args[1:])
+	args := os.Args[1:]
+	prog := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	if path := c.appletPath(prog); path != nil {
+		args = append(path, args...)
+	}
+	if c.HandleSignals {
+		return c.mainWithSignals(ctx, args)
+	}
+	return c.mainWithArgs(ctx, args)
+}
+
+// appletPath returns the path from c to the sub-command named name that has
+// Applet set, or nil if there is none.
+func (c *Command) appletPath(name string) []string {
+	if name == c.Name {
+		return nil
+	}
+	for _, s := range c.subs {
+		if s.Applet && s.Name == name {
+			return []string{s.Name}
+		}
+		if p := s.appletPath(name); p != nil {
+			return append([]string{s.Name}, p...)
+		}
+	}
+	return nil
 }
 
 // Separated for testing.
@@ -38,18 +75,41 @@ func (c *Command) mainWithArgs(ctx context.Context, args []string) int {
 	if c.flags == flag.CommandLine {
 		c.flags.Init(flag.CommandLine.Name(), flag.ContinueOnError)
 	}
-	if err := c.Run(ctx, args); err != nil {
-		if errors.Is(err, flag.ErrHelp) {
-			return 0
-		}
-		fmt.Fprintln(flag.CommandLine.Output(), err)
-		var uerr *UsageError
-		if errors.As(err, &uerr) {
-			return 2
+	_, _, stderr := c.streams()
+	return exitCode(c.Run(ctx, args), stderr, c.useColor(stderr))
+}
+
+// exitCode writes err, if any, to stderr, and returns the exit code for it
+// as documented for Main. If color is true, the first line of the error is
+// written in red.
+func exitCode(err error, stderr io.Writer, color bool) int {
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	msg := err.Error()
+	if color {
+		first, rest, _ := stringsCut(msg, "\n")
+		msg = helpStyle{color: true}.paint(styleRed, first)
+		if rest != "" {
+			msg += "\n" + rest
 		}
-		return 1
 	}
-	return 0
+	fmt.Fprintln(stderr, msg)
+	var uerr *UsageError
+	if errors.As(err, &uerr) {
+		return 2
+	}
+	var ierr *ItemErrors
+	if errors.As(err, &ierr) && ierr.partial() {
+		return 3
+	}
+	if errors.Is(err, ErrWaitTimeout) {
+		return 4
+	}
+	if errors.Is(err, ErrInterrupted) {
+		return 130
+	}
+	return 1
 }
 
 // Run invokes the command on the arguments.
@@ -69,14 +129,130 @@ func (c *Command) Run(ctx context.Context, args []string) (err error) {
 		}
 	}()
 
+	// An instance's arguments were expanded by the Run of its command.
+	expand := c.origin == nil
+	if inv := invocationFrom(ctx); inv != nil && inv.exact {
+		expand = false
+	}
+	if c.ResponseFiles && expand {
+		var err error
+		args, err = expandResponseFiles(args)
+		if err != nil {
+			return &UsageError{c, err}
+		}
+	}
+	if c.Pipelines && expand {
+		segments, err := splitPipeline(args)
+		if err != nil {
+			return &UsageError{c, err}
+		}
+		if len(segments) > 1 {
+			return c.runPipeline(ctx, segments)
+		}
+	}
+
+	inv := invocationFrom(ctx)
+	if inv == nil {
+		// This is the outermost Run.
+		inv = newInvocation(c)
+		inv.ownStreams = true
+		ctx = withInvocation(ctx, inv)
+		defer func() {
+			if err == nil {
+				err = inv.strictErr()
+			}
+		}()
+	}
+	inv.depth++
+	defer func() {
+		inv.depth--
+		if inv.depth == 0 {
+			// The outermost Run for inv is done.
+			if rerr := inv.removeTempDir(); err == nil && rerr != nil {
+				err = fmt.Errorf("removing temporary directory: %w", rerr)
+			}
+		}
+	}()
+	if inv.ownStreams {
+		// c may have streams of its own.
+		inv.stdin, inv.stdout, inv.stderr = c.streams()
+	}
+	if c.origin == nil && (inv.fresh || c.reentrant()) {
+		inst, err := c.instantiate(inv)
+		if err != nil {
+			return err
+		}
+		return inst.Run(ctx, args)
+	}
+	if inv.args == nil {
+		// The outermost Run, or the first with this invocation.
+		inv.args = append([]string{}, args...)
+	}
+
 	if err := c.validate(); err != nil {
 		return err
 	}
-	if err := c.flags.Parse(args); err != nil {
+	var raw []string
+	if c.raw != nil && !inv.exact {
+		// Everything after the first "--" goes to the raw field untouched.
+		for i, a := range args {
+			if a == "--" {
+				args, raw = args[:i], args[i+1:]
+				break
+			}
+		}
+	}
+	// The flag package writes errors and usage messages to its output.
+	c.flags.SetOutput(inv.stderr)
+	if c.flags.Parsed() {
+		// Forget which flags an earlier run set, for Changed.
+		c.renewFlags()
+	}
+	if c.configFlag != nil {
+		// Forget the flag and file from an earlier run; the file is loaded
+		// after parsing.
+		c.configFlag.path = ""
+		c.configFlag.loaded = nil
+	}
+	// Values from the config file and environment come before the
+	// command line, so that it overrides them.
+	if err := c.applySettings(); err != nil {
+		return &UsageError{c, err}
+	}
+	if err := c.parseFlagsHelp(args); err != nil {
+		if s := c.flagSuggestion(err); s != "" {
+			err = fmt.Errorf("%w%s", err, s)
+		}
+		return &UsageError{c, err}
+	}
+	if c.showVersion {
+		return c.printVersion(ctx)
+	}
+	if err := c.checkFlags(); err != nil {
 		return &UsageError{c, err}
 	}
+	if c.configFlag != nil {
+		if err := c.loadConfigFlag(); err != nil {
+			return &UsageError{c, err}
+		}
+		// Now that the file is known, it can set c's own flags.
+		if err := c.applySettings(); err != nil {
+			return &UsageError{c, err}
+		}
+	}
+	if c.strict {
+		inv.strict = true
+	}
+	if t := c.timeout(); t > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t)
+		defer cancel()
+		tctx := ctx
+		defer func() { err = inv.timeoutError(tctx, t, err) }()
+	}
+	c.warnDeprecations(ctx)
 	if b, ok := c.Struct.(interface{ Before(context.Context) error }); ok {
-		if err := b.Before(ctx); err != nil {
+		if err := inv.timePhase("Before", func() error { return b.Before(ctx) }); err != nil {
 			return err
 		}
 	}
@@ -85,50 +261,470 @@ func (c *Command) Run(ctx context.Context, args []string) (err error) {
 		if subc := c.findSub(c.flags.Arg(0)); subc != nil {
 			return subc.Run(ctx, c.flags.Args()[1:])
 		}
+		if c.flags.Arg(0) == "help" && c.hasHelpCommand() {
+			return c.help(ctx, c.flags.Args()[1:])
+		}
+		subc, err := c.matchSub(c.flags.Arg(0))
+		if err != nil {
+			return &UsageError{c, err}
+		}
+		if subc != nil {
+			return subc.Run(ctx, c.flags.Args()[1:])
+		}
+		if subc, args := c.findTransposed(c.flags.Args()); subc != nil {
+			return subc.Run(ctx, args)
+		}
 		// If there are sub-commands but no formals, then the error should be
 		// that the sub-command is unknown, not that there are too many args.
 		if len(c.subs) > 0 && len(c.formals) == 0 {
-			return &UsageError{c, fmt.Errorf("unknown command %q", c.flags.Arg(0))}
+			name := c.flags.Arg(0)
+			return &UsageError{c, fmt.Errorf("unknown command %q%s", name, c.commandSuggestion(name))}
 		}
 	}
 	if err := c.bindFormals(c.formals, c.flags.Args()); err != nil {
 		return err
 	}
-	if r, ok := c.Struct.(Runnable); ok {
-		return r.Run(ctx)
+	if c.raw != nil {
+		c.raw.field.Set(reflect.ValueOf(raw))
+	}
+	// From here on, c is the command being run, so Default and Validate
+	// can use functions like Select that refer to it.
+	inv.cmd = c
+	if c.stdinField.IsValid() && StdinIsPipe(ctx) {
+		err := json.NewDecoder(inv.stdin).Decode(c.stdinField.Addr().Interface())
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("decoding standard input: %w", err)
+		}
+	}
+	if d, ok := c.Struct.(interface{ Default(context.Context) error }); ok {
+		if err := d.Default(ctx); err != nil {
+			return err
+		}
+	}
+	if v, ok := c.Struct.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return &UsageError{c, err}
+		}
+	}
+	if r, ok := c.runnable(); ok {
+		if err := c.checkInteractive(ctx); err != nil {
+			return err
+		}
+		err := inv.timePhase("Before", func() error {
+			var err error
+			ctx, err = c.runBeforeHooks(ctx)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		err = inv.timePhase("Run", func() error { return c.runWatched(ctx, c.wrap(r.Run)) })
+		if a, ok := c.Struct.(interface {
+			After(context.Context, error) error
+		}); ok {
+			err = inv.timePhase("After", func() error { return a.After(ctx, err) })
+		}
+		return err
 	}
 	// c is a group, but it is not a command.
+	if c.defaultSub != "" && c.flags.NArg() == 0 {
+		return c.findSub(c.defaultSub).Run(ctx, nil)
+	}
 	return &UsageError{c, errors.New("missing sub-command")}
 }
 
+// help implements the implicit "help" sub-command of c, a command with
+// sub-commands. It writes the usage message of the sub-command of c named by
+// args to standard output, or that of c itself if args is empty. If args
+// begins with "-all", the message lists all sub-commands, despite HelpDepth
+// and HelpBreadth.
+func (c *Command) help(ctx context.Context, args []string) error {
+	all := false
+	if len(args) > 0 && (args[0] == "-all" || args[0] == "--all") {
+		all = true
+		args = args[1:]
+	}
+	cmd := c
+	for _, name := range args {
+		sub := cmd.findSub(name)
+		if sub == nil {
+			var err error
+			sub, err = cmd.matchSub(name)
+			if err != nil {
+				return &UsageError{cmd, err}
+			}
+		}
+		if sub == nil {
+			return &UsageError{cmd, fmt.Errorf("unknown command %q%s", name, cmd.commandSuggestion(name))}
+		}
+		cmd = sub
+	}
+	cmd.writeHelp(invocationOrDefault(ctx).stdout, all)
+	return nil
+}
+
+// findTransposed handles "verb object" command lines for a Transposable
+// command. If args begins with the name of a sub-command of one of c's
+// sub-commands, followed by the name of that sub-command, it returns the
+// sub-command and the arguments for it. Otherwise it returns nil.
+func (c *Command) findTransposed(args []string) (*Command, []string) {
+	if !c.Transposable || len(args) < 2 {
+		return nil, nil
+	}
+	obj := c.findSub(args[1])
+	if obj == nil || obj.findSub(args[0]) == nil {
+		return nil, nil
+	}
+	return obj, append([]string{args[0]}, args[2:]...)
+}
+
+// BeforeRun registers a function to be called before c or any of its
+// sub-commands runs. The function is passed the command about to run, after
+// its flags and arguments have been bound and validated. It can return a new
+// context, which is passed to later hooks and to the command. If it returns an
+// error, the command does not run and the error is returned.
+//
+// Hooks are called in order, starting with those of the top-level command.
+// They are the place for setup that applies to all commands, like logging or
+// authentication.
+func (c *Command) BeforeRun(f func(context.Context, *Command) (context.Context, error)) {
+	c.beforeRun = append(c.beforeRun, f)
+}
+
+// runBeforeHooks calls the BeforeRun hooks of c and the commands above it,
+// outermost first.
+func (c *Command) runBeforeHooks(ctx context.Context) (context.Context, error) {
+	var cmds []*Command
+	for a := c; a != nil; a = a.super {
+		cmds = append([]*Command{a}, cmds...)
+	}
+	for _, a := range cmds {
+		for _, f := range a.beforeRun {
+			var err error
+			ctx, err = f(ctx, c)
+			if err != nil {
+				return ctx, err
+			}
+		}
+	}
+	return ctx, nil
+}
+
+// Use adds middleware to c. When c or any of its sub-commands runs, the
+// middleware is called with a RunFunc that runs the command, and the RunFunc
+// it returns is called instead. So middleware can act before and after a
+// command runs, as for timing, tracing or recovering from panics, or decide
+// whether to run it at all, as for retries.
+//
+// Middleware of a command wraps that of its sub-commands, and the first
+// middleware added to a command is the outermost. All middleware runs after
+// BeforeRun hooks.
+func (c *Command) Use(mw func(next RunFunc) RunFunc) {
+	c.middleware = append(c.middleware, mw)
+}
+
+// wrap applies the middleware of c and the commands above it to run.
+func (c *Command) wrap(run RunFunc) RunFunc {
+	for a := c; a != nil; a = a.super {
+		for i := len(a.middleware) - 1; i >= 0; i-- {
+			run = a.middleware[i](run)
+		}
+	}
+	return run
+}
+
+// checkFlags checks constraints among the flags that were set.
+func (c *Command) checkFlags() error {
+	set := map[string]bool{}
+	c.parseSet().Visit(func(f *flag.Flag) {
+		set[c.primaryFlagName(f.Name)] = true
+	})
+	for _, f := range c.flagSpecs {
+		if !set[f.Name] {
+			continue
+		}
+		for _, r := range f.Requires {
+			if !set[c.primaryFlagName(r)] {
+				return fmt.Errorf("flag -%s requires -%s", f.Name, r)
+			}
+		}
+	}
+	var groups []string
+	xor := map[string][]string{} // from group name to flags given
+	for _, f := range c.flagSpecs {
+		if f.Exclusive == "" || !set[f.Name] {
+			continue
+		}
+		if xor[f.Exclusive] == nil {
+			groups = append(groups, f.Exclusive)
+		}
+		xor[f.Exclusive] = append(xor[f.Exclusive], "-"+f.Name)
+	}
+	sort.Strings(groups)
+	for _, g := range groups {
+		if given := xor[g]; len(given) > 1 {
+			return fmt.Errorf("flags %s are mutually exclusive", strings.Join(given, ", "))
+		}
+	}
+	return nil
+}
+
+// warnDeprecations warns if c is deprecated, or if any deprecated flags of c
+// were set. Each warning is issued at most once per invocation.
+func (c *Command) warnDeprecations(ctx context.Context) {
+	if c.Deprecated != "" {
+		warnOnce(ctx, "command "+c.Path(), "command %q is deprecated: %s", c.Path(), c.Deprecated)
+	}
+	c.parseSet().Visit(func(f *flag.Flag) {
+		fs := c.flagSpec(f.Name)
+		if fs == nil || fs.Deprecated == "" {
+			return
+		}
+		warnOnce(ctx, "flag "+fs.Name, "flag -%s is deprecated: %s", fs.Name, fs.Deprecated)
+	})
+}
+
+// splitPipeline splits args into pipeline segments at each "|".
+func splitPipeline(args []string) ([][]string, error) {
+	var segments [][]string
+	start := 0
+	for i := 0; i <= len(args); i++ {
+		if i == len(args) || args[i] == "|" {
+			if i == start && len(args) > 0 {
+				return nil, errors.New("empty command in pipeline")
+			}
+			segments = append(segments, args[start:i])
+			start = i + 1
+		}
+	}
+	return segments, nil
+}
+
+// parseFlagsHelp is like parseFlags, but when the flags ask for help, as
+// with -h, it writes the usage message with writeHelp.
+func (c *Command) parseFlagsHelp(args []string) error {
+	// The flag package calls Usage both for errors and for -h, and only the
+	// error it returns tells them apart. So call Usage after it returns.
+	usage := c.flags.Usage
+	called := false
+	c.flags.Usage = func() { called = true }
+	c.takeSecretErr() // from an earlier run
+	err := c.parseFlags(args)
+	if serr := c.takeSecretErr(); err == nil && serr != nil {
+		// Report it as the flag package reports invalid values.
+		err = serr
+		fmt.Fprintln(c.flags.Output(), err)
+		c.flags.Usage()
+	}
+	c.flags.Usage = usage
+	switch {
+	case !called:
+	case errors.Is(err, flag.ErrHelp):
+		c.writeHelp(c.flags.Output(), false)
+	default:
+		c.flags.Usage()
+	}
+	return err
+}
+
+// parseFlags parses args with c's flag set. Unless c has sub-commands or
+// StrictOrder is set, flags may appear among the positional arguments; when
+// parseFlags returns, c.flags.Args() holds the positional arguments in order.
+func (c *Command) parseFlags(args []string) error {
+	if c.combineShortFlags() {
+		args = c.splitShortFlags(args)
+	}
+	if c.StrictOrder {
+		args = c.endStrictFlags(args)
+	}
+	fs := c.parseSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// Whichever set parses args, the positional arguments end up in
+	// c.flags.Args().
+	defer c.syncArgs()
+	if c.StrictOrder || len(c.subs) > 0 || fs.NArg() == 0 {
+		return nil
+	}
+	var positional []string
+	for fs.NArg() > 0 {
+		rest := fs.Args()
+		if used := len(args) - len(rest); used > 0 && args[used-1] == "--" {
+			// Everything after "--" is positional.
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+		// A negative number that isn't a flag name is an argument.
+		for len(args) > 0 && c.isNegativeNumber(args[0]) {
+			positional = append(positional, args[0])
+			args = args[1:]
+		}
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+	}
+	return fs.Parse(append([]string{"--"}, positional...))
+}
+
+// parseSet returns the flag set that parses c's command line in the current
+// run, and so knows which flags it set.
+func (c *Command) parseSet() *flag.FlagSet {
+	if c.parsed != nil {
+		return c.parsed
+	}
+	return c.flags
+}
+
+// syncArgs makes c.flags.Args() hold the positional arguments that c.parsed
+// left, and c.flags.Parsed() report true, without setting any flags.
+func (c *Command) syncArgs() {
+	if c.parsed != nil {
+		c.flags.Parse(append([]string{"--"}, c.parsed.Args()...))
+	}
+}
+
+// endStrictFlags returns args with "--" inserted before the first positional
+// argument if it starts with "-" but can't be a flag because its name has a
+// space, like the quoted command line "-v say a". See Command.StrictOrder.
+func (c *Command) endStrictFlags(args []string) []string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-" || arg == "--" || !strings.HasPrefix(arg, "-") {
+			return args
+		}
+		name, _, hasValue := stringsCut(strings.TrimLeft(arg, "-"), "=")
+		if strings.ContainsAny(name, " \t") {
+			return append(append(args[:i:i], "--"), args[i:]...)
+		}
+		if !hasValue && c.flags.Lookup(name) != nil && !c.isBoolFlag(name) {
+			i++ // skip the flag's value
+		}
+	}
+	return args
+}
+
+// splitShortFlags expands groups of single-letter flags in args, like "-rf",
+// into separate flags. See Command.CombineShortFlags.
+func (c *Command) splitShortFlags(args []string) []string {
+	var out []string
+	needValue := false // the previous argument is a flag that takes the next one as its value
+	for i, arg := range args {
+		if arg == "--" {
+			return append(out, args[i:]...)
+		}
+		if needValue {
+			out = append(out, arg)
+			needValue = false
+			continue
+		}
+		if split := c.splitShortFlagGroup(arg); split != nil {
+			out = append(out, split...)
+			last := split[len(split)-1]
+			needValue = !strings.Contains(last, "=") && !c.isBoolFlag(last[1:])
+			continue
+		}
+		out = append(out, arg)
+		if strings.HasPrefix(arg, "-") && !strings.Contains(arg, "=") {
+			name := strings.TrimLeft(arg, "-")
+			needValue = c.flags.Lookup(name) != nil && !c.isBoolFlag(name)
+		}
+	}
+	return out
+}
+
+// splitShortFlagGroup returns the flags that arg stands for if it is a group
+// of single-letter flags, or nil if it is not.
+func (c *Command) splitShortFlagGroup(arg string) []string {
+	if len(arg) < 3 || arg[0] != '-' || arg[1] == '-' || strings.Contains(arg, "=") {
+		return nil
+	}
+	group := arg[1:]
+	if c.flags.Lookup(group) != nil {
+		return nil
+	}
+	var flags []string
+	for i, r := range group {
+		name := string(r)
+		if c.flags.Lookup(name) == nil {
+			return nil
+		}
+		if !c.isBoolFlag(name) {
+			if rest := group[i+len(name):]; rest != "" {
+				// The rest of the group is the value.
+				return append(flags, "-"+name+"="+rest)
+			}
+		}
+		flags = append(flags, "-"+name)
+	}
+	return flags
+}
+
+func (c *Command) isBoolFlag(name string) bool {
+	f := c.flags.Lookup(name)
+	return f != nil && isBoolFlag(f)
+}
+
+func (c *Command) isNegativeNumber(arg string) bool {
+	if !strings.HasPrefix(arg, "-") || c.flags.Lookup(arg[1:]) != nil {
+		return false
+	}
+	_, err := strconv.ParseFloat(arg, 64)
+	return err == nil
+}
+
+// runPipeline runs each segment of a pipeline in turn, with the standard output
+// of one connected to the standard input of the next.
+func (c *Command) runPipeline(ctx context.Context, segments [][]string) error {
+	base := invocationOrDefault(ctx)
+	in := base.stdin
+	for i, seg := range segments {
+		inv := &invocation{stdin: in, stdout: base.stdout, stderr: base.stderr}
+		var out bytes.Buffer
+		if i < len(segments)-1 {
+			inv.stdout = &out
+		}
+		if err := c.Run(withInvocation(ctx, inv), seg); err != nil {
+			return err
+		}
+		if err := inv.strictErr(); err != nil {
+			return err
+		}
+		in = &out
+	}
+	return nil
+}
+
 func (c *Command) bindFormals(formals []*formal, args []string) error {
 	a := 0 // index into args
 	for i, f := range formals {
-		if f.min >= 0 {
+		if f.Variadic {
 			// "Rest" arg. We've already checked that this is the last formal.
 			nArgsLeft := len(args) - i
-			if nArgsLeft < f.min {
+			if nArgsLeft < f.Min {
 				arg := "argument"
-				if f.min != 1 {
+				if f.Min != 1 {
 					arg += "s"
 				}
 				return &UsageError{
 					cmd: c,
-					Err: fmt.Errorf("%s: need at least %d %s, got %d", f.name, f.min, arg, nArgsLeft),
+					Err: fmt.Errorf("%s: need at least %d %s, got %d", f.Name, f.Min, arg, nArgsLeft),
 				}
 			}
 			slice := reflect.MakeSlice(f.field.Type(), 0, nArgsLeft)
 			for j := i; j < len(args); j++ {
 				v, err := f.parser(args[j])
 				if err != nil {
-					return fmt.Errorf("%s: %v", f.name, err)
+					return &UsageError{cmd: c, Err: fmt.Errorf("%s: %v", f.Name, err)}
 				}
 				slice = reflect.Append(slice, reflect.ValueOf(v))
 			}
 			f.field.Set(slice)
 			return nil
 		} else if i >= len(args) {
-			if f.opt {
+			if f.Optional {
 				// This and all following args are optional, so we can skip.
 				return nil
 			}
@@ -136,7 +732,7 @@ func (c *Command) bindFormals(formals []*formal, args []string) error {
 		} else {
 			v, err := f.parser(args[a])
 			if err != nil {
-				return fmt.Errorf("%s: %v", f.name, err)
+				return &UsageError{cmd: c, Err: fmt.Errorf("%s: %v", f.Name, err)}
 			}
 			f.field.Set(reflect.ValueOf(v))
 			a++
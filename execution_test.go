@@ -3,11 +3,18 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 type runnable struct {
@@ -33,6 +40,21 @@ func TestExitCode(t *testing.T) {
 			return context.Canceled
 		}}}, "")
 
+	top.Command("part", &runnable{func(ctx context.Context) error {
+		return ForEach(ctx, []int{1, 2}, 1, func(_ context.Context, i int) error {
+			if i == 2 {
+				return errors.New("fail")
+			}
+			return nil
+		})
+	}}, "")
+
+	top.Command("stop", &runnable{func(ctx context.Context) error {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		return CheckCancel(ctx)
+	}}, "")
+
 	for _, test := range []struct {
 		args []string
 		want int
@@ -45,6 +67,8 @@ func TestExitCode(t *testing.T) {
 		{args: []string{"com", "sub"}, want: 1},
 		{args: []string{"com", "sub", "-h"}, want: 0},
 		{args: []string{"com", "sub", "foo"}, want: 2}, // too many args
+		{args: []string{"part"}, want: 3},
+		{args: []string{"stop"}, want: 130},
 
 	} {
 		got := top.mainWithArgs(context.Background(), test.args)
@@ -58,6 +82,14 @@ type (
 	c1 struct{ A int }
 	c2 struct{ B bool }
 	c3 struct{}
+	c4 struct {
+		Region string `cli:"flag=region"`
+		Zone   string
+	}
+	c5 struct {
+		Lo int `cli:"flag=lo"`
+		Hi int `cli:"flag=hi"`
+	}
 )
 
 func (c *c1) Run(context.Context) error {
@@ -76,10 +108,36 @@ func (c *c3) Run(context.Context) error {
 	return errors.New("should not be called")
 }
 
+func (c *c4) Default(context.Context) error {
+	if len(c.Zone) < 3 {
+		return fmt.Errorf("bad zone %q", c.Zone)
+	}
+	// A zone is its region followed by a letter, as in "us-east1-b".
+	SetDefault(&c.Region, c.Zone[:len(c.Zone)-2])
+	return nil
+}
+
+func (c *c4) Run(context.Context) error {
+	return fmt.Errorf("region=%s", c.Region)
+}
+
+func (c *c5) Validate() error {
+	if c.Lo > c.Hi {
+		return errors.New("-lo cannot exceed -hi")
+	}
+	return nil
+}
+
+func (c *c5) Run(context.Context) error {
+	return fmt.Errorf("%d-%d", c.Lo, c.Hi)
+}
+
 func TestRun(t *testing.T) {
 	top := Top(nil)
 	top.Command("c1", &c1{}, "").Command("c2", &c2{}, "")
 	top.Command("c3", &c3{}, "")
+	top.Command("c4", &c4{}, "")
+	top.Command("c5", &c5{}, "")
 
 	ctx := context.Background()
 	for _, test := range []struct {
@@ -93,6 +151,11 @@ func TestRun(t *testing.T) {
 		{[]string{"c1", "c2", "true"}, "B=true"},
 		{[]string{"c1", "c2"}, "too few arguments"},
 		{[]string{"c3"}, "c3.Before"},
+		{[]string{"c4", "us-east1-b"}, "region=us-east1"},
+		{[]string{"c4", "-region", "eu", "us-east1-b"}, "region=eu"},
+		{[]string{"c4", "b"}, `bad zone "b"`},
+		{[]string{"c5", "-lo", "1", "-hi", "2"}, "1-2"},
+		{[]string{"c5", "-lo", "3", "-hi", "2"}, "-lo cannot exceed -hi"},
 	} {
 		err := top.Run(ctx, test.args)
 		var got string
@@ -106,4 +169,555 @@ func TestRun(t *testing.T) {
 			t.Errorf("%v:\ngot %q\nwant %q", test.args, got, test.want)
 		}
 	}
+	var uerr *UsageError
+	if err := top.Run(ctx, []string{"c5", "-lo", "3", "-hi", "0"}); !errors.As(err, &uerr) {
+		t.Errorf("Validate: got %v, want a UsageError", err)
+	}
+}
+
+func TestRunKeepsCommandLine(t *testing.T) {
+	defer func(fs *flag.FlagSet) { flag.CommandLine = fs }(flag.CommandLine)
+	flag.CommandLine = flag.NewFlagSet("prog", flag.ContinueOnError)
+
+	n := flag.Int("n", 0, "")
+	var changed []string
+	top := Top(&Command{Name: "prog"})
+	top.Command("show", &runnable{func(ctx context.Context) error {
+		changed = nil
+		for _, name := range []string{"n", "late"} {
+			if FromContext(ctx).Changed(name) {
+				changed = append(changed, name)
+			}
+		}
+		return nil
+	}}, "")
+	ctx := context.Background()
+	if err := top.Run(ctx, []string{"-n", "1", "show"}); err != nil {
+		t.Fatal(err)
+	}
+	// A flag the program defines after a run is parsed by the next one.
+	late := flag.String("late", "", "")
+	for _, test := range []struct {
+		args    []string
+		want    []string
+		n       int
+		late    string
+		argsOut []string
+	}{
+		{[]string{"-late", "x", "show"}, []string{"late"}, 1, "x", []string{"show"}},
+		{[]string{"-n", "2", "show"}, []string{"n"}, 2, "x", []string{"show"}},
+	} {
+		if err := top.Run(ctx, test.args); err != nil {
+			t.Fatal(err)
+		}
+		if top.flags != flag.CommandLine {
+			t.Fatalf("%v: the top command no longer uses flag.CommandLine", test.args)
+		}
+		if !flag.Parsed() {
+			t.Errorf("%v: flag.Parsed() is false", test.args)
+		}
+		if !slices.Equal(changed, test.want) {
+			t.Errorf("%v: changed: got %v, want %v", test.args, changed, test.want)
+		}
+		if *n != test.n || *late != test.late {
+			t.Errorf("%v: got -n=%d -late=%q, want %d, %q", test.args, *n, *late, test.n, test.late)
+		}
+		if got := flag.Args(); !slices.Equal(got, test.argsOut) {
+			t.Errorf("%v: flag.Args() = %v, want %v", test.args, got, test.argsOut)
+		}
+	}
+}
+
+type warner struct{}
+
+func (warner) Run(ctx context.Context) error {
+	Warnf(ctx, "careful")
+	return nil
+}
+
+func TestStrict(t *testing.T) {
+	defer func(f *os.File) { os.Stderr = f }(os.Stderr)
+	os.Stderr = nil
+
+	top := Top(nil)
+	w := top.Command("w", &warner{}, "")
+	w.AddStrictFlag()
+	ctx := context.Background()
+	if err := top.Run(ctx, []string{"w"}); err != nil {
+		t.Errorf("not strict: got %v, want nil", err)
+	}
+	err := top.Run(ctx, []string{"w", "-strict"})
+	if err == nil || !strings.Contains(err.Error(), "1 warning(s)") {
+		t.Errorf("strict: got %v, want warning error", err)
+	}
+}
+
+func TestWarnfConcurrent(t *testing.T) {
+	var stderr bytes.Buffer
+	inv := &invocation{stdout: io.Discard, stderr: &stderr, strict: true}
+	ctx := withInvocation(context.Background(), inv)
+	err := ForEach(ctx, make([]int, 50), 8, func(ctx context.Context, _ int) error {
+		Warnf(ctx, "careful")
+		warnOnce(ctx, "key", "once")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.strictErr(); err == nil || !strings.Contains(err.Error(), "51 warning(s)") {
+		t.Errorf("got %v, want 51 warnings", err)
+	}
+	if got := strings.Count(stderr.String(), "warning: once"); got != 1 {
+		t.Errorf("warnOnce: got %d warnings, want 1", got)
+	}
+}
+
+type jsonInput struct {
+	In struct{ X int } `cli:"stdin=json"`
+}
+
+func (c *jsonInput) Run(context.Context) error {
+	return fmt.Errorf("X=%d", c.In.X)
+}
+
+func TestStdinJSON(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{`{"X": 3}`, "X=3"},
+		{"", "X=0"},
+		{"{", "decoding standard input: unexpected EOF"},
+	} {
+		top := Top(nil)
+		top.Command("j", &jsonInput{}, "")
+		ctx := withInvocation(context.Background(), &invocation{stdin: strings.NewReader(test.in)})
+		err := top.Run(ctx, []string{"j"})
+		if got := fmt.Sprint(err); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+type jsonOutput struct {
+	X int
+}
+
+func (c *jsonOutput) Run(ctx context.Context) error {
+	return WriteJSON(ctx, struct{ X int }{c.X})
+}
+
+func TestPipeline(t *testing.T) {
+	top := Top(&Command{Pipelines: true})
+	top.Command("out", &jsonOutput{}, "")
+	top.Command("in", &jsonInput{}, "")
+	var buf bytes.Buffer
+	ctx := withInvocation(context.Background(), &invocation{stdin: strings.NewReader(""), stdout: &buf})
+	err := top.Run(ctx, []string{"out", "7", "|", "in"})
+	if got, want := fmt.Sprint(err), "X=7"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	err = top.Run(ctx, []string{"out", "7", "|"})
+	if got, want := fmt.Sprint(err), "empty command"; !strings.Contains(got, want) {
+		t.Errorf("got %q, want error containing %q", got, want)
+	}
+}
+
+type formats struct {
+	JSON bool `cli:"flag=json|j, xor=format, JSON output"`
+	YAML bool `cli:"flag=yaml, xor=format, YAML output"`
+	Text bool `cli:"flag=text, text output"`
+
+	Key  string `cli:"flag=key, requires=cert, key file"`
+	Cert string `cli:"flag=cert|c, cert file"`
+}
+
+func (*formats) Run(context.Context) error { return nil }
+
+func TestFlagConstraints(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"f", "-json", "-text"}, ""},
+		{[]string{"f", "-j", "-yaml"}, "flags -json, -yaml are mutually exclusive"},
+		{[]string{"f", "-key", "k", "-c", "c"}, ""},
+		{[]string{"f", "-key", "k"}, "flag -key requires -cert"},
+	} {
+		// Use a new command each time, because a FlagSet remembers which flags were set.
+		top := Top(nil)
+		top.Command("f", &formats{}, "")
+		err := top.Run(context.Background(), test.args)
+		var got string
+		if err != nil {
+			got, _, _ = stringsCut(err.Error(), "\n")
+		}
+		if _, after, found := stringsCut(got, ": "); found {
+			got = after
+		}
+		if got != test.want {
+			t.Errorf("%v: got %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestAppletPath(t *testing.T) {
+	top := &Command{Name: "tool"}
+	initFlags(top)
+	g := top.Register(&Command{Name: "files"})
+	g.Register(&Command{Name: "compare", Struct: &c3{}, Applet: true})
+	g.Register(&Command{Name: "copy", Struct: &c3{}})
+
+	for _, test := range []struct {
+		name string
+		want []string
+	}{
+		{"tool", nil},
+		{"compare", []string{"files", "compare"}},
+		{"copy", nil},
+		{"files", nil},
+	} {
+		got := top.appletPath(test.name)
+		if !cmp.Equal(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+type old struct {
+	Old bool `cli:"flag=old|o, deprecated=use -new, old flag"`
+}
+
+func (*old) Run(context.Context) error { return nil }
+
+func TestDeprecated(t *testing.T) {
+	top := Top(nil)
+	top.Register(&Command{Name: "old", Struct: &old{}, Deprecated: "use new"})
+	var buf bytes.Buffer
+	ctx := withInvocation(context.Background(), &invocation{stderr: &buf})
+	if err := top.Run(ctx, []string{"old", "-old", "-o"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `warning: command "cli.test old" is deprecated: use new
+warning: flag -old is deprecated: use -new
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+type ctxKey struct{}
+
+func TestBeforeRun(t *testing.T) {
+	var got []string
+	run := &runnable{func(ctx context.Context) error {
+		got = append(got, "run "+ctx.Value(ctxKey{}).(string))
+		return nil
+	}}
+	top := Top(nil)
+	g := top.Command("g", nil, "")
+	g.Command("r", run, "")
+	top.BeforeRun(func(ctx context.Context, c *Command) (context.Context, error) {
+		got = append(got, "top "+c.Path())
+		return context.WithValue(ctx, ctxKey{}, "top"), nil
+	})
+	g.BeforeRun(func(ctx context.Context, c *Command) (context.Context, error) {
+		got = append(got, "g after "+ctx.Value(ctxKey{}).(string))
+		if c.Name == "bad" {
+			return ctx, errors.New("denied")
+		}
+		return context.WithValue(ctx, ctxKey{}, "g"), nil
+	})
+	g.Command("bad", run, "")
+
+	if err := top.Run(context.Background(), []string{"g", "r"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"top cli.test g r", "g after top", "run g"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	got = nil
+	if err := top.Run(context.Background(), []string{"g", "bad"}); err == nil || err.Error() != "denied" {
+		t.Errorf("got %v, want denied", err)
+	}
+	if want := []string{"top cli.test g bad", "g after top"}; !cmp.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestUse(t *testing.T) {
+	var got []string
+	mw := func(name string) func(RunFunc) RunFunc {
+		return func(next RunFunc) RunFunc {
+			return func(ctx context.Context) error {
+				got = append(got, name+" before")
+				err := next(ctx)
+				got = append(got, name+" after")
+				return err
+			}
+		}
+	}
+	top := Top(nil)
+	g := top.Command("g", nil, "")
+	g.Command("r", &runnable{func(context.Context) error {
+		got = append(got, "run")
+		return nil
+	}}, "")
+	top.Use(mw("a"))
+	top.Use(mw("b"))
+	g.Use(mw("c"))
+
+	if err := top.Run(context.Background(), []string{"g", "r"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a before", "b before", "c before", "run", "c after", "b after", "a after"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTransposable(t *testing.T) {
+	top := Top(&Command{Transposable: true})
+	students := top.Command("students", nil, "")
+	students.Command("list", &c1{}, "")
+	top.Command("teachers", nil, "").Command("list", &c2{}, "")
+	top.Command("add", nil, "").Command("students", &c4{}, "")
+
+	ctx := context.Background()
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"students", "list", "1"}, "A=1"},
+		{[]string{"list", "students", "2"}, "A=2"},
+		{[]string{"list", "teachers", "true"}, "B=true"},
+		{[]string{"add", "students", "us-east1-b"}, "region=us-east1"}, // real command wins
+		{[]string{"frob", "students"}, `unknown command "frob"`},
+	} {
+		err := top.Run(ctx, test.args)
+		var got string
+		if err != nil {
+			got, _, _ = stringsCut(err.Error(), "\n")
+		}
+		if _, after, found := stringsCut(got, ": "); found {
+			got = after
+		}
+		if got != test.want {
+			t.Errorf("%v: got %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestPrefixMatch(t *testing.T) {
+	top := Top(&Command{PrefixMatch: true})
+	top.Command("status", &c1{}, "")
+	top.Command("start", &c2{}, "")
+	top.Command("stop", nil, "").Command("all", &c4{}, "")
+
+	ctx := context.Background()
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"stat", "1"}, "A=1"},
+		{[]string{"star", "true"}, "B=true"},
+		{[]string{"sto", "a", "us-east1-b"}, "region=us-east1"},
+		{[]string{"st", "1"}, `ambiguous command "st": could be status, start, stop`},
+		{[]string{"x"}, `unknown command "x"`},
+	} {
+		err := top.Run(ctx, test.args)
+		var got string
+		if err != nil {
+			got, _, _ = stringsCut(err.Error(), "\n")
+		}
+		if _, after, found := stringsCut(got, ": "); found {
+			got = after
+		}
+		if got != test.want {
+			t.Errorf("%v: got %q, want %q", test.args, got, test.want)
+		}
+	}
+
+	// A command with positional arguments takes an ambiguous prefix as one.
+	type group struct {
+		runnable
+		Name string `cli:"name"`
+	}
+	g := &group{runnable: runnable{func(context.Context) error { return nil }}}
+	withArgs := Top(&Command{PrefixMatch: true, Struct: g})
+	withArgs.Command("status", &c1{}, "")
+	withArgs.Command("start", &c2{}, "")
+	if err := withArgs.Run(ctx, []string{"st"}); err != nil {
+		t.Fatal(err)
+	}
+	if g.Name != "st" {
+		t.Errorf("got %q, want %q", g.Name, "st")
+	}
+}
+
+func TestDefaultSubCommand(t *testing.T) {
+	top := Top(nil)
+	remote := top.Command("remote", nil, "")
+	remote.Command("add", &c1{}, "")
+	remote.Command("list", &c3{}, "")
+	remote.Default("list")
+
+	ctx := context.Background()
+	if err := top.Run(ctx, []string{"remote"}); err == nil || err.Error() != "c3.Before" {
+		t.Errorf("got %v, want c3.Before", err)
+	}
+	if err := top.Run(ctx, []string{"remote", "add", "1"}); err == nil || err.Error() != "A=1" {
+		t.Errorf("got %v, want A=1", err)
+	}
+
+	remote.Default("nope")
+	if err := top.Run(ctx, []string{"remote"}); err == nil || !strings.Contains(err.Error(), `"nope" does not exist`) {
+		t.Errorf("got %v, want error about missing default", err)
+	}
+
+	run := Top(nil)
+	run.Command("r", &c1{}, "").Default("x")
+	if err := run.Run(ctx, []string{"r", "1"}); err == nil || !strings.Contains(err.Error(), "cannot have a default sub-command") {
+		t.Errorf("got %v, want error about a runnable command", err)
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	top := Top(nil)
+	g := top.Command("g", nil, "")
+	g.Command("r", &c1{}, "run it")
+	var out bytes.Buffer
+	ctx := withInvocation(context.Background(), &invocation{stdout: &out, stderr: io.Discard})
+
+	if err := top.Run(ctx, []string{"help", "g", "r"}); err != nil {
+		t.Fatal(err)
+	}
+	var want strings.Builder
+	g.findSub("r").usage(&want)
+	if out.String() != want.String() {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want.String())
+	}
+	if err := top.Run(ctx, []string{"g", "help", "x"}); err == nil || !strings.Contains(err.Error(), `unknown command "x"`) {
+		t.Errorf("got %v, want unknown command error", err)
+	}
+
+	// A user-defined help command takes precedence.
+	g.Command("help", &c2{}, "")
+	if err := top.Run(ctx, []string{"g", "help", "true"}); err == nil || err.Error() != "B=true" {
+		t.Errorf("got %v, want B=true", err)
+	}
+}
+
+type copier struct {
+	V     bool     `cli:"flag=v, verbose"`
+	N     int      `cli:"flag=n, number of copies"`
+	Paths []string `cli:"paths"`
+}
+
+func (c *copier) Run(context.Context) error {
+	return fmt.Errorf("v=%t n=%d paths=%q", c.V, c.N, c.Paths)
+}
+
+func TestInterleavedFlags(t *testing.T) {
+	for _, test := range []struct {
+		strict bool
+		args   []string
+		want   string
+	}{
+		{false, []string{"src", "dst", "-v"}, `v=true n=0 paths=["src" "dst"]`},
+		{false, []string{"src", "-n", "2", "dst"}, `v=false n=2 paths=["src" "dst"]`},
+		{false, []string{"-v", "src", "--", "-n", "dst"}, `v=true n=0 paths=["src" "-n" "dst"]`},
+		{false, []string{"src", "-3", "-v"}, `v=true n=0 paths=["src" "-3"]`},
+		{true, []string{"src", "dst", "-v"}, `v=false n=0 paths=["src" "dst" "-v"]`},
+	} {
+		top := &Command{Name: "prog"}
+		initFlags(top)
+		cp := top.Command("cp", &copier{}, "")
+		cp.StrictOrder = test.strict
+		err := top.Run(context.Background(), append([]string{"cp"}, test.args...))
+		if got := fmt.Sprint(err); got != test.want {
+			t.Errorf("strict=%t %q:\ngot  %s\nwant %s", test.strict, test.args, got, test.want)
+		}
+	}
+}
+
+type runner struct {
+	V    bool     `cli:"flag=v, verbose"`
+	Prog string   `cli:"program"`
+	Args []string `cli:"raw=, name=ARG, arguments to the program"`
+}
+
+func (r *runner) Run(context.Context) error {
+	return fmt.Errorf("v=%t prog=%s args=%q", r.V, r.Prog, r.Args)
+}
+
+func TestRawArgs(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-v", "./server", "--", "-port", "8080"}, `v=true prog=./server args=["-port" "8080"]`},
+		{[]string{"./server", "-v", "--", "--", "-v"}, `v=true prog=./server args=["--" "-v"]`},
+		{[]string{"./server"}, `v=false prog=./server args=[]`},
+		{[]string{"./server", "x"}, "too many arguments"},
+	} {
+		top := &Command{Name: "prog"}
+		initFlags(top)
+		top.Command("run", &runner{}, "")
+		err := top.Run(context.Background(), append([]string{"run"}, test.args...))
+		got, _, _ := stringsCut(fmt.Sprint(err), "\n")
+		if !strings.Contains(got, test.want) {
+			t.Errorf("%q:\ngot  %s\nwant %s", test.args, got, test.want)
+		}
+	}
+
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	run := top.Command("run", &runner{}, "")
+	if got, want := run.usageHeader(), "prog run [flags] PROG [-- ARG...]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := run.register(&Command{Name: "sub", Struct: &c3{}}); err == nil {
+		t.Error("registered a sub-command of a command with a raw field")
+	}
+}
+
+type remover struct {
+	R     bool     `cli:"flag=r, recursive"`
+	F     bool     `cli:"flag=f, force"`
+	N     int      `cli:"flag=n, number of copies"`
+	O     string   `cli:"flag=o, output"`
+	RF    bool     `cli:"flag=rf, a flag with a two-letter name"`
+	Paths []string `cli:"paths"`
+}
+
+func (r *remover) Run(context.Context) error {
+	return fmt.Errorf("r=%t f=%t n=%d o=%s rf=%t paths=%q", r.R, r.F, r.N, r.O, r.RF, r.Paths)
+}
+
+func TestCombineShortFlags(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-fr", "x"}, `r=true f=true n=0 o= rf=false paths=["x"]`},
+		{[]string{"-rf", "x"}, `r=false f=false n=0 o= rf=true paths=["x"]`},
+		{[]string{"-fn5", "x"}, `r=false f=true n=5 o= rf=false paths=["x"]`},
+		{[]string{"-fn", "5", "x"}, `r=false f=true n=5 o= rf=false paths=["x"]`},
+		{[]string{"-o", "-fr", "x"}, `r=false f=false n=0 o=-fr rf=false paths=["x"]`},
+		{[]string{"x", "-fr"}, `r=true f=true n=0 o= rf=false paths=["x"]`},
+		{[]string{"--", "-fr"}, `r=false f=false n=0 o= rf=false paths=["-fr"]`},
+		{[]string{"-fz"}, "flag provided but not defined: -fz"},
+	} {
+		top := &Command{Name: "prog", CombineShortFlags: true}
+		initFlags(top)
+		rm := top.Command("rm", &remover{}, "")
+		rm.flags.SetOutput(io.Discard)
+		err := top.Run(context.Background(), append([]string{"rm"}, test.args...))
+		got, _, _ := stringsCut(fmt.Sprint(err), "\n")
+		if !strings.Contains(got, test.want) {
+			t.Errorf("%q:\ngot  %s\nwant %s", test.args, got, test.want)
+		}
+	}
 }
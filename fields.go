@@ -0,0 +1,100 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Selecting the fields of results.
+
+// FieldsOptions is a bundle with a "-fields" flag that selects which fields
+// of a command's results to show, as in "-fields id,owner.name". Pass a
+// *FieldsOptions to Command or Register. When the flag is set, WriteJSON
+// writes only the selected fields; commands that render results another way
+// can call Project themselves.
+type FieldsOptions struct {
+	Fields []string `cli:"flag=fields, 'comma-separated fields of the results to show, like id,owner.name'"`
+}
+
+// Project returns the fields of v named by fields, as a value that encodes to
+// JSON. The value v is first converted to JSON, so fields are named by their
+// JSON names; a name with dots selects a field of a nested object, as in
+// "owner.name". If v is an array, each element is projected. If fields is
+// empty, Project returns v unchanged.
+//
+// It is an error for a field to appear in none of the objects of v, which
+// probably means the name is wrong.
+func Project(v interface{}, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+	j, err := jsonValue(v)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	objects := false
+	var paths [][]string
+	for _, f := range fields {
+		paths = append(paths, strings.Split(f, "."))
+	}
+	var project func(j interface{}, paths [][]string, prefix string) interface{}
+	project = func(j interface{}, paths [][]string, prefix string) interface{} {
+		switch j := j.(type) {
+		case []interface{}:
+			r := make([]interface{}, len(j))
+			for i, e := range j {
+				r[i] = project(e, paths, prefix)
+			}
+			return r
+		case map[string]interface{}:
+			objects = true
+			// Group the paths by their first element, in order.
+			var names []string
+			rest := map[string][][]string{}
+			for _, p := range paths {
+				if _, ok := rest[p[0]]; !ok {
+					names = append(names, p[0])
+					rest[p[0]] = nil
+				}
+				if len(p) > 1 {
+					rest[p[0]] = append(rest[p[0]], p[1:])
+				}
+			}
+			r := map[string]interface{}{}
+			for _, name := range names {
+				e, ok := j[name]
+				if !ok {
+					continue
+				}
+				seen[prefix+name] = true
+				if len(rest[name]) == 0 {
+					// The whole field was asked for.
+					r[name] = e
+				} else {
+					r[name] = project(e, rest[name], prefix+name+".")
+				}
+			}
+			return r
+		default:
+			return j
+		}
+	}
+	r := project(j, paths, "")
+	if objects {
+		var missing []string
+		for _, f := range fields {
+			if !seen[f] {
+				missing = append(missing, f)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return nil, fmt.Errorf("no such field: %s", strings.Join(missing, ", "))
+		}
+	}
+	return r, nil
+}
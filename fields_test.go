@@ -0,0 +1,78 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProject(t *testing.T) {
+	issues := []issue{
+		{ID: 1, Status: "open", Owner: &person{Name: "pat"}, Labels: map[string]string{"area": "cli"}},
+		{ID: 2, Status: "closed", Assignee: "me"},
+	}
+	for _, test := range []struct {
+		fields []string
+		want   string
+	}{
+		{[]string{"id"}, `[{"id":1},{"id":2}]`},
+		{[]string{"id", "assignee"}, `[{"id":1},{"assignee":"me","id":2}]`},
+		{[]string{"owner.Name", "labels"}, `[{"labels":{"area":"cli"},"owner":{"Name":"pat"}},{"labels":null,"owner":null}]`},
+	} {
+		got, err := Project(issues, test.fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, string(data)); diff != "" {
+			t.Errorf("%v: mismatch (-want, +got):\n%s", test.fields, diff)
+		}
+	}
+	if _, err := Project(issues, []string{"id", "colour"}); err == nil || err.Error() != "no such field: colour" {
+		t.Errorf("got %v, want error for colour", err)
+	}
+	// No fields: v is unchanged.
+	if got, _ := Project(issues, nil); !cmp.Equal(got, issues) {
+		t.Errorf("got %v", got)
+	}
+}
+
+type issueWriter struct{}
+
+func (*issueWriter) Run(ctx context.Context) error {
+	return WriteJSON(ctx, issue{ID: 3, Status: "open", Owner: &person{Name: "kim"}})
+}
+
+func TestFieldsFlag(t *testing.T) {
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	top.Command("show", &issueWriter{}, "", &FieldsOptions{})
+	var out bytes.Buffer
+	top.Stdout = &out
+	if err := top.Run(context.Background(), []string{"show", "-fields", "status,owner.Name"}); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"status": "open", "owner": map[string]interface{}{"Name": "kim"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	err := top.Run(context.Background(), []string{"show", "-fields", "nope"})
+	var uerr *UsageError
+	if !errors.As(err, &uerr) {
+		t.Errorf("got %v, want a UsageError", err)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Filtering results.
+
+// A Filter is a list of conditions on the fields of a value, all of which
+// must hold for the value to match. It is written as comma-separated
+// conditions of the form key=value or key!=value, as in
+//
+//	status=open,assignee!=me
+//
+// A key can name a field of a nested struct or map with dots, as in
+// "owner.name=pat".
+//
+// A *Filter is a flag.Value, so it can be the type of a flag. Setting the
+// flag more than once adds conditions. For a flag of a command, the first
+// use on a command line replaces the conditions of the default and of
+// earlier runs. See FilterOptions for a bundle with a
+// standard "-filter" flag.
+type Filter []Condition
+
+// A Condition is a single comparison of a Filter.
+type Condition struct {
+	Key   string
+	Op    string // "=" or "!="
+	Value string
+}
+
+func (c Condition) String() string {
+	return c.Key + c.Op + c.Value
+}
+
+// ParseFilter parses a filter expression.
+func ParseFilter(s string) (Filter, error) {
+	var f Filter
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		i := strings.IndexByte(term, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("bad filter condition %q: want key=value or key!=value", term)
+		}
+		c := Condition{Key: term[:i], Op: "=", Value: term[i+1:]}
+		if strings.HasSuffix(c.Key, "!") {
+			c.Key, c.Op = c.Key[:len(c.Key)-1], "!="
+		}
+		c.Key = strings.TrimSpace(c.Key)
+		if c.Key == "" {
+			return nil, fmt.Errorf("bad filter condition %q: missing key", term)
+		}
+		f = append(f, c)
+	}
+	return f, nil
+}
+
+// String implements flag.Value.
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	var terms []string
+	for _, c := range *f {
+		terms = append(terms, c.String())
+	}
+	return strings.Join(terms, ",")
+}
+
+// Set implements flag.Value. It adds the conditions of s to f.
+func (f *Filter) Set(s string) error {
+	g, err := ParseFilter(s)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, g...)
+	return nil
+}
+
+// filterFlag is the flag.Value of a command's Filter flag. The first use of
+// the flag in a run replaces the Filter; later ones add to it.
+type filterFlag struct {
+	*Filter
+	cmd  *Command
+	name string
+}
+
+// Set implements flag.Value.
+func (v *filterFlag) Set(s string) error {
+	// The flag set doesn't record this use until Set returns, so Changed
+	// reports earlier ones.
+	if v.cmd.Changed(v.name) {
+		return v.Filter.Set(s)
+	}
+	g, err := ParseFilter(s)
+	if err != nil {
+		return err
+	}
+	*v.Filter = g
+	return nil
+}
+
+// Match reports whether v satisfies all the conditions of f. The value v can
+// be a struct, a map with string keys, or a pointer to one of those. A key
+// names a struct field by its JSON name, if it has one, or by its name
+// ignoring case. Values are compared with their fmt.Sprint form.
+//
+// It is an error for a key to name a field that a struct doesn't have. A key
+// that is missing from a map matches no value: "key=x" is false and "key!=x"
+// is true.
+func (f Filter) Match(v interface{}) (bool, error) {
+	for _, c := range f {
+		got, ok, err := lookupKey(reflect.ValueOf(v), c.Key)
+		if err != nil {
+			return false, err
+		}
+		eq := ok && fmt.Sprint(got.Interface()) == c.Value
+		if eq != (c.Op == "=") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// lookupKey returns the value of the dotted key in v. It returns false if v
+// is a map without the key.
+func lookupKey(v reflect.Value, key string) (reflect.Value, bool, error) {
+	for _, name := range strings.Split(key, ".") {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return v, false, nil
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return v, false, fmt.Errorf("filter key %q: %s does not have string keys", key, v.Type())
+			}
+			v = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return v, false, nil
+			}
+		case reflect.Struct:
+			f, ok := structFieldByKey(v.Type(), name)
+			if !ok {
+				return v, false, fmt.Errorf("filter key %q: %s has no field %q", key, v.Type(), name)
+			}
+			v = v.FieldByIndex(f.Index)
+		default:
+			return v, false, fmt.Errorf("filter key %q: cannot look up %q in %s", key, name, v.Type())
+		}
+	}
+	return v, true, nil
+}
+
+// structFieldByKey returns the exported field of t with the JSON name key,
+// or else the one whose name is key ignoring case.
+func structFieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	fields := reflect.VisibleFields(t)
+	for _, f := range fields {
+		if !f.IsExported() {
+			continue
+		}
+		if name, _, _ := stringsCut(f.Tag.Get("json"), ","); name == key {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if f.IsExported() && strings.EqualFold(f.Name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// FilterOptions is a bundle with a "-filter" flag for commands that list
+// results. Pass a *FilterOptions to Command or Register, and check each
+// result with Match.
+type FilterOptions struct {
+	Filter Filter `cli:"flag=filter, 'show only results that match all these comma-separated conditions, like status=open,assignee!=me'"`
+}
+
+// Match reports whether v satisfies the filter. See Filter.Match.
+func (o *FilterOptions) Match(v interface{}) (bool, error) {
+	return o.Filter.Match(v)
+}
@@ -0,0 +1,137 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseFilter(t *testing.T) {
+	got, err := ParseFilter("status=open, assignee!=me,title=a=b,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Filter{
+		{"status", "=", "open"},
+		{"assignee", "!=", "me"},
+		{"title", "=", "a=b"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	for _, bad := range []string{"status", "=open", "!=x"} {
+		if _, err := ParseFilter(bad); err == nil {
+			t.Errorf("%q: got nil error", bad)
+		}
+	}
+}
+
+type issue struct {
+	ID       int               `json:"id"`
+	Status   string            `json:"status"`
+	Assignee string            `json:"assignee,omitempty"`
+	Owner    *person           `json:"owner"`
+	Labels   map[string]string `json:"labels"`
+}
+
+type person struct {
+	Name string
+}
+
+func TestFilterMatch(t *testing.T) {
+	is := &issue{
+		ID:       7,
+		Status:   "open",
+		Assignee: "me",
+		Owner:    &person{Name: "pat"},
+		Labels:   map[string]string{"area": "cli"},
+	}
+	for _, test := range []struct {
+		filter string
+		want   bool
+	}{
+		{"", true},
+		{"status=open", true},
+		{"status=closed", false},
+		{"status=open,assignee!=me", false},
+		{"status=open,assignee!=you", true},
+		{"id=7", true},
+		{"ID=7", true},
+		{"owner.name=pat", true},
+		{"labels.area=cli", true},
+		{"labels.size=big", false},
+		{"labels.size!=big", true},
+	} {
+		f, err := ParseFilter(test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := f.Match(is)
+		if err != nil {
+			t.Fatalf("%q: %v", test.filter, err)
+		}
+		if got != test.want {
+			t.Errorf("%q: got %t, want %t", test.filter, got, test.want)
+		}
+	}
+	f, _ := ParseFilter("color=red")
+	if _, err := f.Match(is); err == nil {
+		t.Error("unknown field: got nil error")
+	}
+	// A map of values.
+	f, _ = ParseFilter("status=open")
+	if got, err := f.Match(map[string]interface{}{"status": "open"}); err != nil || !got {
+		t.Errorf("map: got %t, %v", got, err)
+	}
+}
+
+type issueLister struct {
+	opts *FilterOptions
+}
+
+func (l *issueLister) Run(ctx context.Context) error {
+	for _, is := range []issue{{ID: 1, Status: "open"}, {ID: 2, Status: "closed", Assignee: "me"}, {ID: 3, Status: "open", Assignee: "me"}} {
+		ok, err := l.opts.Match(is)
+		if err != nil {
+			return err
+		}
+		if ok {
+			fmt.Fprintln(Stdout(ctx), is.ID)
+		}
+	}
+	return nil
+}
+
+func TestFilterFlag(t *testing.T) {
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	l := &issueLister{opts: &FilterOptions{}}
+	top.Command("list", l, "", l.opts)
+	var out bytes.Buffer
+	top.Stdout = &out
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "1 2 3"},
+		{[]string{"-filter", "status=open"}, "1 3"},
+		{[]string{"-filter", "status=open,assignee=me"}, "3"},
+		{[]string{"-filter", "status=open", "-filter", "assignee!=me"}, "1"},
+		// The first use in a run replaces the conditions of earlier runs.
+		{[]string{"-filter", "status=open"}, "1 3"},
+	} {
+		out.Reset()
+		if err := top.Run(context.Background(), append([]string{"list"}, test.args...)); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(strings.Fields(out.String()), " "); got != test.want {
+			t.Errorf("%v: got %q, want %q", test.args, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ForEach calls fn on each item, running at most parallelism calls at a time.
+// If parallelism is not positive, runtime.NumCPU() is used.
+//
+// ForEach is meant for commands that take a list of arguments, like files or
+// hosts, and process them independently. By default, a failure on one item
+// does not stop the others. If the running command, or one of the commands
+// above it, has FailFast set, then ForEach stops at the first failure: it
+// starts no more calls, and cancels the context passed to those in progress.
+//
+// If the command's standard error is a terminal, ForEach shows its progress
+// there on a single line, like "3/10 done, 1 failed", and clears the line
+// when it returns.
+//
+// If any calls fail, ForEach returns an *ItemErrors. If ctx is done, no more
+// calls are started and ctx.Err() is included in the returned error.
+func ForEach[T any](ctx context.Context, items []T, parallelism int, fn func(context.Context, T) error) error {
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+	failFast := false
+	if inv := invocationFrom(ctx); inv != nil && inv.cmd != nil {
+		failFast = inv.cmd.failFast()
+	}
+	progress := newProgressLine(ctx, len(items))
+	defer progress.clear()
+	fctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make([]error, len(items)) // in order of items
+		sem  = make(chan struct{}, parallelism)
+		ok   int // number of successful calls
+		bad  int // number of failed calls
+	)
+	for i, item := range items {
+		if fctx.Err() != nil {
+			break
+		}
+		select {
+		case <-fctx.Done():
+		case sem <- struct{}{}:
+		}
+		if fctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, item T) {
+			defer func() { <-sem; wg.Done() }()
+			err := fn(fctx, item)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				ok++
+			} else {
+				errs[i] = err
+				bad++
+				if failFast {
+					cancel()
+				}
+			}
+			progress.show(ok, bad)
+		}(i, item)
+	}
+	wg.Wait()
+
+	ie := &ItemErrors{Total: len(items), Succeeded: ok}
+	for _, err := range errs {
+		if err != nil {
+			ie.Errs = append(ie.Errs, err)
+		}
+	}
+	var err error
+	if len(ie.Errs) > 0 {
+		err = ie
+	}
+	if ctx.Err() != nil {
+		return errors.Join(err, ctx.Err())
+	}
+	return err
+}
+
+// ItemErrors describes the failures of a ForEach call.
+// If some items succeeded, Command.Main returns exit code 3 for it,
+// to distinguish partial failure from complete failure.
+type ItemErrors struct {
+	Errs      []error // one for each failed item, in order of items
+	Total     int     // number of items
+	Succeeded int     // number of items that succeeded
+}
+
+// Error implements the error interface.
+func (e *ItemErrors) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d failed:", len(e.Errs), e.Total)
+	for _, err := range e.Errs {
+		fmt.Fprintf(&b, "\n\t%v", err)
+	}
+	return b.String()
+}
+
+// Unwrap supports errors.Is and errors.As.
+func (e *ItemErrors) Unwrap() []error {
+	return e.Errs
+}
+
+// partial reports whether some, but not all, items failed.
+func (e *ItemErrors) partial() bool {
+	return len(e.Errs) > 0 && e.Succeeded > 0
+}
+
+// A progressLine shows the progress of ForEach on the command's standard
+// error, if it is a terminal.
+type progressLine struct {
+	inv   *invocation // nil if progress isn't shown
+	total int
+	shown bool // whether the line has been written
+}
+
+// progressTerminal reports whether progress can be shown on w; replaced in
+// tests.
+var progressTerminal = isTerminal
+
+func newProgressLine(ctx context.Context, total int) *progressLine {
+	p := &progressLine{total: total}
+	if inv := invocationOrDefault(ctx); progressTerminal(inv.stderr) {
+		p.inv = inv
+	}
+	return p
+}
+
+// show rewrites the line with the numbers of items that succeeded and
+// failed. Calls must not overlap.
+func (p *progressLine) show(ok, failed int) {
+	if p.inv == nil {
+		return
+	}
+	line := fmt.Sprintf("%d/%d done", ok+failed, p.total)
+	if failed > 0 {
+		line += fmt.Sprintf(", %d failed", failed)
+	}
+	p.inv.mu.Lock()
+	defer p.inv.mu.Unlock()
+	fmt.Fprint(p.inv.stderr, "\r"+line+"\x1b[K")
+	p.shown = true
+}
+
+// clear erases the line, if it was written.
+func (p *progressLine) clear() {
+	if p.inv == nil || !p.shown {
+		return
+	}
+	p.inv.mu.Lock()
+	defer p.inv.mu.Unlock()
+	fmt.Fprint(p.inv.stderr, "\r\x1b[K")
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestForEach(t *testing.T) {
+	ctx := context.Background()
+	var active, maxActive int32
+	err := ForEach(ctx, []int{1, 2, 3, 4, 5, 6}, 2, func(_ context.Context, i int) error {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			m := atomic.LoadInt32(&maxActive)
+			if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+				break
+			}
+		}
+		if i%2 == 0 {
+			return fmt.Errorf("even %d", i)
+		}
+		return nil
+	})
+	if got, want := fmt.Sprint(err), "3 of 6 failed:\n\teven 2\n\teven 4\n\teven 6"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if maxActive > 2 {
+		t.Errorf("%d calls active at once, want at most 2", maxActive)
+	}
+
+	// Fail fast.
+	ffctx := withInvocation(ctx, &invocation{cmd: &Command{FailFast: true}})
+	var calls int32
+	err = ForEach(ffctx, []int{1, 2, 3}, 1, func(context.Context, int) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("fail")
+	})
+	if calls != 1 {
+		t.Errorf("fail fast: got %d calls, want 1", calls)
+	}
+	var ie *ItemErrors
+	if !errors.As(err, &ie) || len(ie.Errs) != 1 || ie.Total != 3 || ie.partial() {
+		t.Errorf("fail fast: got %#v", err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = ForEach(cctx, []int{1}, 1, func(context.Context, int) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestForEachProgress(t *testing.T) {
+	defer func(f func(interface{}) bool) { progressTerminal = f }(progressTerminal)
+	run := func() string {
+		var stderr bytes.Buffer
+		ctx := withInvocation(context.Background(), &invocation{stderr: &stderr})
+		ForEach(ctx, []int{1, 2, 3}, 1, func(_ context.Context, i int) error {
+			if i == 2 {
+				return errors.New("fail")
+			}
+			return nil
+		})
+		return stderr.String()
+	}
+	if got := run(); got != "" {
+		t.Errorf("not a terminal: got %q, want nothing", got)
+	}
+	progressTerminal = func(interface{}) bool { return true }
+	want := "\r1/3 done\x1b[K\r2/3 done, 1 failed\x1b[K\r3/3 done, 1 failed\x1b[K\r\x1b[K"
+	if got := run(); got != want {
+		t.Errorf("terminal:\ngot  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Showing long help messages with a pager.
+
+// writeHelp writes the usage message for c to w, as asked for with -h or the
+// help command. If all is true, it lists all of c's sub-commands, despite
+// HelpDepth and HelpBreadth. If PageHelp is set and the message is taller
+// than the terminal that w writes to, it is shown with a pager.
+func (c *Command) writeHelp(w io.Writer, all bool) {
+	st := c.helpStyle(w)
+	st.all = all
+	f, ok := w.(*os.File)
+	if !c.pageHelp() || !ok || !isTerminal(f) {
+		c.writeUsage(w, st)
+		return
+	}
+	var b bytes.Buffer
+	c.writeUsage(&b, st)
+	_, rows := terminalSize(f)
+	pager := pagerCommand()
+	if len(pager) == 0 || !needsPager(b.String(), rows) {
+		w.Write(b.Bytes())
+		return
+	}
+	if err := runPager(pager, b.String(), f); err != nil {
+		w.Write(b.Bytes())
+	}
+}
+
+func (c *Command) pageHelp() bool {
+	for ; c != nil; c = c.super {
+		if c.PageHelp {
+			return true
+		}
+	}
+	return false
+}
+
+// needsPager reports whether text has more lines than a terminal with the
+// given number of rows can show at once.
+func needsPager(text string, rows int) bool {
+	return rows > 0 && strings.Count(text, "\n") >= rows
+}
+
+// pagerCommand returns the command line of the pager to use: the value of
+// the PAGER environment variable, or "less" if it is unset. It returns nil
+// if PAGER is set to "cat" or the empty string, which turn paging off, as
+// with git.
+func pagerCommand() []string {
+	p, ok := os.LookupEnv("PAGER")
+	if !ok {
+		p = "less"
+	}
+	args := strings.Fields(p)
+	if len(args) == 0 || args[0] == "cat" {
+		return nil
+	}
+	return args
+}
+
+// runPager runs the pager command with text as its input and f as its
+// output. It returns an error only if the pager could not be started.
+func runPager(pager []string, text string, f *os.File) error {
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = f
+	cmd.Stderr = os.Stderr
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		// Pass colors through, and quit at the end, as git does.
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	cmd.Wait()
+	return nil
+}
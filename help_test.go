@@ -0,0 +1,75 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestNeedsPager(t *testing.T) {
+	for _, test := range []struct {
+		text string
+		rows int
+		want bool
+	}{
+		{"a\nb\n", 0, false},
+		{"a\nb\n", 3, false},
+		{"a\nb\nc\n", 3, true},
+		{"a\nb\nc\nd\n", 3, true},
+	} {
+		if got := needsPager(test.text, test.rows); got != test.want {
+			t.Errorf("needsPager(%q, %d) = %t, want %t", test.text, test.rows, got, test.want)
+		}
+	}
+}
+
+func TestPagerCommand(t *testing.T) {
+	for _, test := range []struct {
+		env  string
+		want []string
+	}{
+		{"more", []string{"more"}},
+		{"less -R", []string{"less", "-R"}},
+		{"", nil},
+		{"cat", nil},
+	} {
+		t.Setenv("PAGER", test.env)
+		if got := pagerCommand(); !slices.Equal(got, test.want) {
+			t.Errorf("PAGER=%q: got %q, want %q", test.env, got, test.want)
+		}
+	}
+	os.Unsetenv("PAGER")
+	if got, want := pagerCommand(), []string{"less"}; !slices.Equal(got, want) {
+		t.Errorf("PAGER unset: got %q, want %q", got, want)
+	}
+}
+
+func TestRunPager(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("no cat command")
+	}
+	path := filepath.Join(t.TempDir(), "out")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runPager([]string{"cat"}, "Usage:\nprog\n", f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "Usage:\nprog\n" {
+		t.Errorf("got %q", got)
+	}
+
+	if err := runPager([]string{"no-such-pager-command"}, "x", f); err == nil {
+		t.Error("missing pager: got nil, want error")
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Serving commands over HTTP.
+
+// Handler returns an http.Handler that runs the commands of the tree rooted
+// at top. The request's URL path is the path to the command, as in
+// "/students/list", and the request must be a POST whose body is a JSON object
+// holding the command's flags and arguments, keyed by name. For example,
+//
+//	{"v": true, "name": "Pat"}
+//
+// sets the flag -v and the argument NAME. Argument names are matched without
+// regard to case. A list value sets a slice or map flag, or supplies the values
+// for the last argument if it is a slice.
+//
+// The response is a JSON object with the command's standard output and
+// standard error, and the error message if it failed:
+//
+//	{"stdout": "...", "stderr": "...", "error": "..."}
+//
+// Usage errors get status 400, and other errors get status 500. A body larger
+// than 1 MiB gets status 413.
+//
+// Each request runs on new copies of the commands' structs and bundles, as if
+// Reentrant were set on top, so the values of one request can't leak into
+// another. The values in the request body are passed to the command exactly:
+// response files, pipelines and flags in argument values aren't recognized.
+// Requests run concurrently, unless the copies would share some flag or
+// argument, as they do those added to a flag set directly or with a
+// ParamBuilder; then the handler runs one command at a time.
+//
+// To serve the commands under a prefix, use http.StripPrefix:
+//
+//	mux.Handle("/cmd/", http.StripPrefix("/cmd", cli.Handler(top)))
+func Handler(top *Command) http.Handler {
+	return &handler{top: top, isolated: top.isolated()}
+}
+
+// maxRequestBody is the largest request body that the handler reads.
+const maxRequestBody = 1 << 20
+
+type handler struct {
+	top      *Command
+	isolated bool       // whether commands can run concurrently
+	mu       sync.Mutex // held while a command runs, unless isolated
+}
+
+// A handlerResponse is the body of the response sent by the handler.
+type handlerResponse struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cmd, path := h.top, []string{}
+	for _, name := range strings.Split(strings.Trim(r.URL.Path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		cmd = cmd.findSub(name)
+		if cmd == nil {
+			http.NotFound(w, r)
+			return
+		}
+		path = append(path, name)
+	}
+	var params map[string]interface{}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.UseNumber()
+	if err := dec.Decode(&params); err != nil {
+		status := http.StatusBadRequest
+		var merr *http.MaxBytesError
+		if errors.As(err, &merr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, fmt.Sprintf("decoding request body: %v", err), status)
+		return
+	}
+	args, err := cmd.paramsToArgs(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var stdout, stderr bytes.Buffer
+	inv := &invocation{
+		stdin:  strings.NewReader(""),
+		stdout: &stdout,
+		stderr: &stderr,
+		fresh:  true,
+		exact:  true,
+	}
+	if !h.isolated {
+		h.mu.Lock()
+	}
+	err = h.top.Run(withInvocation(r.Context(), inv), append(path, args...))
+	if !h.isolated {
+		h.mu.Unlock()
+	}
+
+	resp := handlerResponse{Stdout: stdout.String(), Stderr: stderr.String()}
+	status := http.StatusOK
+	if err != nil {
+		resp.Error = err.Error()
+		var uerr *UsageError
+		if errors.As(err, &uerr) {
+			status = http.StatusBadRequest
+		} else {
+			status = http.StatusInternalServerError
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// paramsToArgs converts a map from flag and argument names to values into
+// a command line for c. The positional arguments follow "--", so they are
+// never taken for flags.
+func (c *Command) paramsToArgs(params map[string]interface{}) ([]string, error) {
+	var flags []string
+	positional := make([][]string, len(c.formals))
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		val := params[name]
+		if c.flags.Lookup(name) != nil {
+			sep := ""
+			if spec := c.flagSpec(name); spec != nil {
+				sep = spec.sep
+			}
+			s, err := paramString(val, sep)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			flags = append(flags, fmt.Sprintf("-%s=%s", name, s))
+			continue
+		}
+		i := c.formalIndex(name)
+		if i < 0 {
+			return nil, fmt.Errorf("%s has no flag or argument named %q", c.Name, name)
+		}
+		list, ok := val.([]interface{})
+		if !ok || !c.formals[i].Variadic {
+			list = []interface{}{val}
+		}
+		for _, v := range list {
+			s, err := paramString(v, "")
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			positional[i] = append(positional[i], s)
+		}
+	}
+	args := append(flags, "--")
+	for i, p := range positional {
+		if p == nil && i+1 < len(positional) && positional[i+1] != nil {
+			return nil, fmt.Errorf("argument %s is needed before %s", c.formals[i].Name, c.formals[i+1].Name)
+		}
+		args = append(args, p...)
+	}
+	return args, nil
+}
+
+// formalIndex returns the index of the formal named name, ignoring case,
+// or -1 if there is none.
+func (c *Command) formalIndex(name string) int {
+	for i, f := range c.formals {
+		if strings.EqualFold(f.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// paramString converts a value decoded from JSON to a command-line string.
+// Lists become values separated by sep, and objects become key=value pairs
+// separated by sep; sep is a comma if it is empty. It is an error for the
+// value to be or contain null.
+func paramString(v interface{}, sep string) (string, error) {
+	if sep == "" {
+		sep = ","
+	}
+	switch v := v.(type) {
+	case nil:
+		return "", errors.New("null is not a valid value")
+	case []interface{}:
+		var ss []string
+		for _, e := range v {
+			s, err := paramString(e, "")
+			if err != nil {
+				return "", err
+			}
+			ss = append(ss, s)
+		}
+		return strings.Join(ss, sep), nil
+	case map[string]interface{}:
+		var ss []string
+		for k, e := range v {
+			s, err := paramString(e, "")
+			if err != nil {
+				return "", err
+			}
+			ss = append(ss, k+"="+s)
+		}
+		sort.Strings(ss)
+		return strings.Join(ss, sep), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type greet struct {
+	Loud  bool `cli:"flag=loud, shout"`
+	Name  string
+	Names []string
+}
+
+func (g *greet) Run(ctx context.Context) error {
+	return WriteJSON(ctx, map[string]interface{}{"loud": g.Loud, "name": g.Name, "names": g.Names})
+}
+
+func TestHandler(t *testing.T) {
+	top := Top(&Command{ResponseFiles: true, Pipelines: true})
+	top.Command("hello", nil, "").Command("greet", &greet{}, "")
+	srv := httptest.NewServer(Handler(top))
+	defer srv.Close()
+
+	for _, test := range []struct {
+		path, body string
+		wantStatus int
+		wantOut    string
+	}{
+		{
+			"/hello/greet", `{"loud": true, "name": "Pat", "NAMES": ["a", "b"]}`,
+			http.StatusOK, `{"loud":true,"name":"Pat","names":["a","b"]}`,
+		},
+		// Nothing is left over from the last request.
+		{"/hello/greet", `{"name": "Kim"}`, http.StatusOK, `{"loud":false,"name":"Kim","names":[]}`},
+		// Values are taken exactly.
+		{
+			"/hello/greet", `{"name": "-loud", "NAMES": ["@file", "|", "--"]}`,
+			http.StatusOK, `{"loud":false,"name":"-loud","names":["@file","|","--"]}`,
+		},
+		{"/hello/greet", `{"name": null}`, http.StatusBadRequest, ""},
+		{"/hello/greet", `{"name": "Pat", "NAMES": ["a", null]}`, http.StatusBadRequest, ""},
+		{"/hello/greet", `{}`, http.StatusBadRequest, ""},
+		{"/hello/greet", `{"NAMES": ["a"]}`, http.StatusBadRequest, ""},
+		{"/hello/greet", `{"x": 1}`, http.StatusBadRequest, ""},
+		{"/hello/nope", `{}`, http.StatusNotFound, ""},
+		{
+			"/hello/greet", `{"name": "` + strings.Repeat("x", maxRequestBody) + `"}`,
+			http.StatusRequestEntityTooLarge, "",
+		},
+	} {
+		res, err := http.Post(srv.URL+test.path, "application/json", strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != test.wantStatus {
+			t.Errorf("%s %.40s: got status %d, want %d", test.path, test.body, res.StatusCode, test.wantStatus)
+			continue
+		}
+		if test.wantOut == "" {
+			continue
+		}
+		var resp handlerResponse
+		if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Join(strings.Fields(resp.Stdout), "")
+		if got != test.wantOut {
+			t.Errorf("%s %s: got %s, want %s", test.path, test.body, got, test.wantOut)
+		}
+	}
+}
+
+type labeler struct {
+	Tags   []string          `cli:"flag=tag, sep=';', tags"`
+	Labels map[string]string `cli:"flag=label, sep=';', labels"`
+}
+
+func (g *labeler) Run(ctx context.Context) error {
+	return WriteJSON(ctx, map[string]interface{}{"tags": g.Tags, "labels": g.Labels})
+}
+
+func TestHandlerSep(t *testing.T) {
+	top := initFlags(&Command{Name: "prog"})
+	top.Command("tag", &labeler{}, "")
+	rec := httptest.NewRecorder()
+	body := `{"tag": ["a,b", "c"], "label": {"k": "x,y", "j": "z"}}`
+	Handler(top).ServeHTTP(rec, httptest.NewRequest("POST", "/tag", strings.NewReader(body)))
+	var resp handlerResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"labels":{"j":"z","k":"x,y"},"tags":["a,b","c"]}`
+	if got := strings.Join(strings.Fields(resp.Stdout), ""); got != want {
+		t.Errorf("got %s (error %q), want %s", got, resp.Error, want)
+	}
+}
+
+func TestHandlerConcurrent(t *testing.T) {
+	top := initFlags(&Command{Name: "prog"})
+	top.Command("greet", &greet{}, "")
+	h := Handler(top)
+	if !h.(*handler).isolated {
+		t.Fatal("handler is not isolated")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"loud": %t, "name": "n%d"}`, i%2 == 0, i)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("POST", "/greet", strings.NewReader(body)))
+			var resp handlerResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Error(err)
+				return
+			}
+			want := fmt.Sprintf(`{"loud":%t,"name":"n%d","names":[]}`, i%2 == 0, i)
+			if got := strings.Join(strings.Fields(resp.Stdout), ""); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
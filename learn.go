@@ -0,0 +1,104 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// A guided tour of a program's commands.
+
+// AddLearnCommand registers a sub-command of c named "learn" that walks the
+// user through the commands of c's tree that have Examples, in order. For
+// each example it shows the command's usage, what the example does and its
+// command line, then waits for the user to press Enter before showing the
+// example's output. Entering "q" ends the tour.
+//
+// If the example's command or one above it has the flag of AddDryRunFlag,
+// the example is run in dry-run mode, as if the flag were set, and its
+// output and errors are shown. Such a command should check DryRun, or use
+// WriteFileAtomic, before making changes. The examples of other commands are
+// not run, since they may have effects that a tutorial shouldn't cause; their
+// output is taken from the documentation, which RunExamples can keep
+// accurate.
+func (c *Command) AddLearnCommand() *Command {
+	return c.Command("learn", &learnCmd{top: c}, "take a guided tour of the commands")
+}
+
+type learnCmd struct {
+	top *Command
+}
+
+func (l *learnCmd) Run(ctx context.Context) error {
+	var steps []tourStep
+	l.top.tourSteps(&steps)
+	w := Stdout(ctx)
+	if len(steps) == 0 {
+		fmt.Fprintln(w, "There are no examples to show.")
+		return nil
+	}
+	in := bufio.NewScanner(Stdin(ctx))
+	for i, s := range steps {
+		fmt.Fprintf(w, "[%d/%d] %s", i+1, len(steps), s.cmd.Path())
+		if s.cmd.Usage != "" {
+			fmt.Fprintf(w, ": %s", s.cmd.Usage)
+		}
+		fmt.Fprintln(w)
+		if s.ex.Doc != "" {
+			fmt.Fprintf(w, "\n%s:\n", s.ex.Doc)
+		}
+		fmt.Fprintf(w, "\n  $ %s\n\n", strings.Join(append([]string{s.cmd.Path()}, s.ex.Args...), " "))
+		fmt.Fprint(w, "Press Enter to see the output, or q to quit. ")
+		if !in.Scan() || strings.TrimSpace(in.Text()) == "q" {
+			fmt.Fprintln(w)
+			return in.Err()
+		}
+		out := s.ex.Output
+		if s.cmd.hasDryRunFlag() {
+			out = s.cmd.dryRunExample(ctx, s.ex)
+		}
+		if out := strings.TrimSpace(out); out != "" {
+			fmt.Fprintf(w, "\n%s\n", out)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "That's the tour. Use -h on any command for more.")
+	return nil
+}
+
+// dryRunExample runs ex in dry-run mode and returns its standard output and
+// error, followed by the error it returned, if any.
+func (c *Command) dryRunExample(ctx context.Context, ex Example) string {
+	var out bytes.Buffer
+	inv := &invocation{
+		stdin:  strings.NewReader(ex.Stdin),
+		stdout: &out,
+		stderr: &out,
+		dryRun: true,
+		fresh:  true,
+	}
+	top, args := c.exampleArgs(ex)
+	if err := top.Run(withInvocation(ctx, inv), args); err != nil {
+		fmt.Fprintf(&out, "error: %v\n", err)
+	}
+	return out.String()
+}
+
+type tourStep struct {
+	cmd *Command
+	ex  Example
+}
+
+// tourSteps appends a step for each example of c and its sub-commands.
+func (c *Command) tourSteps(steps *[]tourStep) {
+	for _, ex := range c.Examples {
+		*steps = append(*steps, tourStep{c, ex})
+	}
+	for _, s := range c.subs {
+		s.tourSteps(steps)
+	}
+}
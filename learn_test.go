@@ -0,0 +1,88 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLearn(t *testing.T) {
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	hello := top.Command("hello", &greeter{}, "say hello")
+	hello.Examples = []Example{
+		{Doc: "Greet someone", Args: []string{"Pat"}, Output: "hello, Pat\n"},
+		{Args: []string{"-shout", "Al"}, Output: "HELLO, AL"},
+	}
+	top.AddLearnCommand()
+
+	run := func(input string) string {
+		t.Helper()
+		var out bytes.Buffer
+		inv := &invocation{stdin: strings.NewReader(input), stdout: &out}
+		if err := top.Run(withInvocation(context.Background(), inv), []string{"learn"}); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	got := run("\n\n")
+	want := `[1/2] prog hello: say hello
+
+Greet someone:
+
+  $ prog hello Pat
+
+Press Enter to see the output, or q to quit. 
+hello, Pat
+
+[2/2] prog hello: say hello
+
+  $ prog hello -shout Al
+
+Press Enter to see the output, or q to quit. 
+HELLO, AL
+
+That's the tour. Use -h on any command for more.
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	got = run("q\n")
+	if strings.Contains(got, "[2/2]") || strings.Contains(got, "hello, Pat") {
+		t.Errorf("tour did not stop after q:\n%s", got)
+	}
+}
+
+func TestLearnDryRun(t *testing.T) {
+	top := initFlags(&Command{Name: "prog"})
+	top.AddDryRunFlag()
+	file := filepath.Join(t.TempDir(), "out.txt")
+	save := top.Command("save", &saver{}, "save a file")
+	save.Examples = []Example{{Args: []string{file, "saved"}, Output: "documented"}}
+	top.AddLearnCommand()
+
+	var out bytes.Buffer
+	inv := &invocation{stdin: strings.NewReader("\n"), stdout: &out}
+	if err := top.Run(withInvocation(context.Background(), inv), []string{"learn"}); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	if !strings.Contains(got, "dry run: would write 5 bytes to "+file) {
+		t.Errorf("example was not run in dry-run mode:\n%s", got)
+	}
+	if strings.Contains(got, "documented") {
+		t.Errorf("documented output shown instead of the example's:\n%s", got)
+	}
+	if _, err := os.Stat(file); err == nil {
+		t.Error("dry run wrote the file")
+	}
+}
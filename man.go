@@ -0,0 +1,135 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Generating man pages.
+
+// GenManPages writes a man page for top and for each of its sub-commands into
+// dir, which must exist. Each page is named for the command's path, with
+// hyphens separating the names, and is in section 1: "prog-sub.1".
+func GenManPages(top *Command, dir string) error {
+	return writeSpecFiles(top.Spec(), nil, dir, manPageName, writeManPage)
+}
+
+func manPageName(path []string) string {
+	return strings.Join(path, "-") + ".1"
+}
+
+// writeManPage writes the man page for the command described by s, whose
+// path from the top-level command is path.
+func writeManPage(w io.Writer, s *Spec, path []string) {
+	name := strings.Join(path, " ")
+	fmt.Fprintf(w, ".TH \"%s\" 1\n", roffEscape(strings.ToUpper(strings.Join(path, "-"))))
+
+	fmt.Fprintln(w, ".SH NAME")
+	if s.Usage != "" {
+		fmt.Fprintf(w, "%s \\- %s\n", roffEscape(name), roffEscape(s.Usage))
+	} else {
+		fmt.Fprintln(w, roffEscape(name))
+	}
+
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	fmt.Fprintf(w, ".B %s\n", roffEscape(name))
+	syn := s.synopsis("\\fIcommand\\fR", func(name string) string {
+		return "\\fI" + roffEscape(name) + "\\fR"
+	})
+	if len(syn) > 0 {
+		fmt.Fprintln(w, strings.Join(syn, " "))
+	}
+
+	if s.Long != "" {
+		fmt.Fprintln(w, ".SH DESCRIPTION")
+		for i, para := range strings.Split(strings.TrimSpace(s.Long), "\n\n") {
+			if i > 0 {
+				fmt.Fprintln(w, ".PP")
+			}
+			fmt.Fprintln(w, roffEscape(strings.TrimSpace(para)))
+		}
+	}
+
+	if s.Deprecated != "" {
+		fmt.Fprintln(w, ".SH DEPRECATED")
+		fmt.Fprintln(w, roffEscape(s.Deprecated))
+	}
+
+	if len(s.Args) > 0 {
+		fmt.Fprintln(w, ".SH ARGUMENTS")
+		for _, a := range s.Args {
+			fmt.Fprintln(w, ".TP")
+			fmt.Fprintf(w, ".I %s\n", roffEscape(a.Name))
+			fmt.Fprintln(w, roffEscape(a.Usage))
+		}
+	}
+
+	if len(s.Flags) > 0 {
+		fmt.Fprintln(w, ".SH OPTIONS")
+		for _, f := range s.Flags {
+			names := []string{"\\-" + roffEscape(f.Name)}
+			for _, a := range f.Aliases {
+				names = append(names, "\\-"+roffEscape(a))
+			}
+			fmt.Fprintln(w, ".TP")
+			fmt.Fprintf(w, ".B %s\n", strings.Join(names, ", "))
+			doc := f.Usage
+			if f.Default != "" && !strings.Contains(doc, "(default ") {
+				doc += " (default " + f.Default + ")"
+			}
+			fmt.Fprintln(w, roffEscape(doc))
+		}
+	}
+
+	if len(s.Commands) > 0 {
+		fmt.Fprintln(w, ".SH COMMANDS")
+		for _, sub := range s.Commands {
+			fmt.Fprintln(w, ".TP")
+			fmt.Fprintf(w, ".B %s\n", roffEscape(sub.Name))
+			fmt.Fprintln(w, roffEscape(sub.Usage))
+		}
+	}
+
+	var see []string
+	if len(path) > 1 {
+		see = append(see, manRef(path[:len(path)-1]))
+	}
+	for _, sub := range s.Commands {
+		see = append(see, manRef(append(path[:len(path):len(path)], sub.Name)))
+	}
+	if len(see) > 0 {
+		fmt.Fprintln(w, ".SH SEE ALSO")
+		for i, r := range see {
+			if i < len(see)-1 {
+				r += ","
+			}
+			fmt.Fprintln(w, r)
+		}
+	}
+}
+
+// manRef returns a reference to the man page for the command with path.
+func manRef(path []string) string {
+	return fmt.Sprintf(".BR %s (1)", roffEscape(strings.Join(path, "-")))
+}
+
+// roffEscape escapes s for use as text in a roff document.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	var b strings.Builder
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		// A line starting with a period or quote would be a request.
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			b.WriteString(`\&`)
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
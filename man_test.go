@@ -0,0 +1,59 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type manCmd struct {
+	Verbose bool     `cli:"flag=v|verbose, show more"`
+	Env     string   `cli:"flag=, oneof=dev|prod, environment"`
+	Files   []string `cli:"opt=, .files to process"`
+}
+
+func (*manCmd) Run(ctx context.Context) error { return nil }
+
+func TestGenManPages(t *testing.T) {
+	top := initFlags(&Command{Name: "prog", Usage: "do things"})
+	top.Command("run", &manCmd{Env: "dev"}, "run a job")
+	dir := t.TempDir()
+	if err := GenManPages(top, dir); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "prog-run.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `.TH "PROG\-RUN" 1
+.SH NAME
+prog run \- run a job
+.SH SYNOPSIS
+.B prog run
+[flags] [\fIFILES\fR...]
+.SH ARGUMENTS
+.TP
+.I FILES
+\&.files to process
+.SH OPTIONS
+.TP
+.B \-v, \-verbose
+show more
+.TP
+.B \-env
+environment; one of dev, prod (default dev)
+.SH SEE ALSO
+.BR prog (1)
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "prog.1")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Generating Markdown documentation.
+
+// GenMarkdownTree writes a Markdown file for top and for each of its
+// sub-commands into dir, which must exist. Each file is named for the
+// command's path, with hyphens separating the names: "prog-sub.md". Files
+// link to the files of their parent and child commands.
+func GenMarkdownTree(top *Command, dir string) error {
+	return writeSpecFiles(top.Spec(), nil, dir, markdownFileName, writeMarkdown)
+}
+
+func markdownFileName(path []string) string {
+	return strings.Join(path, "-") + ".md"
+}
+
+// writeMarkdown writes the Markdown documentation for the command described by
+// s, whose path from the top-level command is path.
+func writeMarkdown(w io.Writer, s *Spec, path []string) {
+	name := strings.Join(path, " ")
+	fmt.Fprintf(w, "# %s\n\n", name)
+	if s.Usage != "" {
+		fmt.Fprintf(w, "%s\n\n", s.Usage)
+	}
+	if s.Deprecated != "" {
+		fmt.Fprintf(w, "**Deprecated:** %s\n\n", s.Deprecated)
+	}
+	if s.Long != "" {
+		fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(s.Long))
+	}
+
+	syn := append([]string{name}, s.synopsis("<command>", func(name string) string { return name })...)
+	fmt.Fprintf(w, "## Usage\n\n```\n%s\n```\n", strings.Join(syn, " "))
+
+	if len(s.Args) > 0 {
+		fmt.Fprint(w, "\n## Arguments\n\n| Argument | Required | Description |\n|---|---|---|\n")
+		for _, a := range s.Args {
+			fmt.Fprintf(w, "| `%s` | %s | %s |\n", a.Name, argRequirement(*a), markdownCell(a.Usage))
+		}
+	}
+
+	if len(s.Flags) > 0 {
+		fmt.Fprint(w, "\n## Flags\n\n| Flag | Description |\n|---|---|\n")
+		for _, f := range s.Flags {
+			names := []string{"`-" + f.Name + "`"}
+			for _, a := range f.Aliases {
+				names = append(names, "`-"+a+"`")
+			}
+			fmt.Fprintf(w, "| %s | %s |\n", strings.Join(names, ", "), markdownCell(f.Usage))
+		}
+	}
+
+	if len(s.Commands) > 0 {
+		fmt.Fprint(w, "\n## Commands\n\n| Command | Description |\n|---|---|\n")
+		for _, sub := range s.Commands {
+			file := markdownFileName(append(path[:len(path):len(path)], sub.Name))
+			fmt.Fprintf(w, "| [%s](%s) | %s |\n", sub.Name, file, markdownCell(sub.Usage))
+		}
+	}
+
+	if len(path) > 1 {
+		parent := path[:len(path)-1]
+		fmt.Fprintf(w, "\n## See also\n\n- [%s](%s)\n", strings.Join(parent, " "), markdownFileName(parent))
+	}
+}
+
+// markdownCell escapes s for use in a Markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
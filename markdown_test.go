@@ -0,0 +1,41 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenMarkdownTree(t *testing.T) {
+	top := initFlags(&Command{Name: "prog", Usage: "do things"})
+	top.Command("run", &manCmd{Env: "dev"}, "run a job")
+	dir := t.TempDir()
+	if err := GenMarkdownTree(top, dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		file, want string
+	}{
+		{"prog.md", "# prog\n\ndo things\n\n## Usage\n\n```\nprog <command>\n```\n\n" +
+			"## Commands\n\n| Command | Description |\n|---|---|\n| [run](prog-run.md) | run a job |\n"},
+		{"prog-run.md", "# prog run\n\nrun a job\n\n## Usage\n\n```\nprog run [flags] [FILES...]\n```\n\n" +
+			"## Arguments\n\n| Argument | Required | Description |\n|---|---|---|\n" +
+			"| `FILES` | optional, any number | .files to process |\n\n" +
+			"## Flags\n\n| Flag | Description |\n|---|---|\n" +
+			"| `-v`, `-verbose` | show more |\n" +
+			"| `-env` | environment; one of dev, prod (default dev) |\n\n" +
+			"## See also\n\n- [prog](prog.md)\n"},
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, test.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, string(got)); diff != "" {
+			t.Errorf("%s: mismatch (-want, +got):\n%s", test.file, diff)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Migrating programs that use the flag package.
+
+// GenFromFlagSet writes a Go program that defines with this package the
+// flags registered on fs: a struct with a tagged field for each flag, a Run
+// method to fill in, and a main function that runs it. It is meant to start
+// the conversion of a program written with the standard flag package. Call it
+// from the program after its flags are registered, as in
+//
+//	cli.GenFromFlagSet(os.Stdout, flag.CommandLine)
+//
+// The flag package doesn't know about positional arguments, so they must be
+// added to the struct by hand. Flags of types that the program defines become
+// fields of those types; flags defined with flag.Func and similar functions
+// become string fields, marked with a TODO comment.
+func GenFromFlagSet(w io.Writer, fs *flag.FlagSet) error {
+	var (
+		fields   bytes.Buffer                   // the struct's fields
+		defaults bytes.Buffer                   // the struct literal's fields
+		names    = map[string]bool{"Run": true} // the method
+		useTime  bool
+	)
+	fs.VisitAll(func(f *flag.Flag) {
+		name := fieldName(f.Name)
+		for i := 2; names[name]; i++ {
+			name = fmt.Sprintf("%s%d", fieldName(f.Name), i)
+		}
+		names[name] = true
+
+		typ, def, ok := goFieldType(f)
+		if !ok {
+			fmt.Fprintf(&fields, "\t// TODO: -%s was defined with flag.Func or similar; give it a type.\n", f.Name)
+		}
+		if typ == "time.Duration" {
+			useTime = true
+		}
+		fmt.Fprintf(&fields, "\t%s %s %s\n", name, typ, structTag(f))
+		if def != "" {
+			fmt.Fprintf(&defaults, "\t\t%s: %s,\n", name, def)
+		}
+	})
+
+	prog := filepath.Base(fs.Name())
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated from the flags of %s by cli.GenFromFlagSet.\n\n", prog)
+	fmt.Fprintln(&b, "package main\n\nimport (\n\t\"context\"\n\t\"os\"")
+	if useTime {
+		fmt.Fprintln(&b, "\t\"time\"")
+	}
+	fmt.Fprintln(&b, "\n\t\"github.com/jba/cli\"\n)")
+	fmt.Fprintf(&b, "\ntype command struct {\n%s}\n", fields.Bytes())
+	fmt.Fprintln(&b, "\nfunc (c *command) Run(ctx context.Context) error {")
+	fmt.Fprintln(&b, "\t// TODO: do what the program did, using the fields of c in place of the flag variables.")
+	fmt.Fprintln(&b, "\treturn nil\n}")
+	fmt.Fprintln(&b, "\nfunc main() {\n\ttop := cli.Top(&cli.Command{")
+	fmt.Fprintf(&b, "\t\tName: %q,\n", prog)
+	fmt.Fprintf(&b, "\t\tStruct: &command{\n%s\t\t},\n", defaults.Bytes())
+	fmt.Fprintln(&b, "\t})\n\tos.Exit(top.Main(context.Background()))\n}")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// fieldName returns an exported Go identifier for the flag named name, like
+// "DryRun" for "dry-run".
+func fieldName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		rs := []rune(part)
+		b.WriteRune(unicode.ToUpper(rs[0]))
+		b.WriteString(string(rs[1:]))
+	}
+	s := b.String()
+	if s == "" || !unicode.IsLetter([]rune(s)[0]) {
+		s = "F" + s
+	}
+	return s
+}
+
+// goFieldType returns the Go type of a field for f, and a Go expression
+// for its default value if that is not the zero value. It reports false if it
+// cannot tell the type. The default comes from f.DefValue, since f's value may
+// already have been set from the command line.
+func goFieldType(f *flag.Flag) (typ, def string, ok bool) {
+	if g, ok := f.Value.(flag.Getter); ok {
+		switch x := g.Get().(type) {
+		case bool:
+			if f.DefValue == "true" {
+				def = "true"
+			}
+			return "bool", def, true
+		case string:
+			if f.DefValue != "" {
+				def = strconv.Quote(f.DefValue)
+			}
+			return "string", def, true
+		case time.Duration:
+			if d, err := time.ParseDuration(f.DefValue); err == nil && d != 0 {
+				def = durationExpr(d)
+			}
+			return "time.Duration", def, true
+		case int, int64, uint, uint64:
+			if f.DefValue != "0" {
+				def = f.DefValue
+			}
+			return reflect.TypeOf(x).String(), def, true
+		case float64:
+			if v, err := strconv.ParseFloat(f.DefValue, 64); err == nil && v != 0 {
+				def = strconv.FormatFloat(v, 'g', -1, 64)
+			}
+			return "float64", def, true
+		}
+	}
+	t := reflect.TypeOf(f.Value)
+	if t.Kind() == reflect.Pointer && t.Elem().PkgPath() != "flag" {
+		// A type defined by the program. The field has the type that
+		// f.Value points to, so that *field implements flag.Value.
+		return strings.TrimPrefix(t.Elem().String(), "main."), "", true
+	}
+	return "string", "", false
+}
+
+// durationExpr returns a Go expression for d in the largest unit that
+// divides it.
+func durationExpr(d time.Duration) string {
+	for _, u := range []struct {
+		d    time.Duration
+		name string
+	}{
+		{time.Hour, "Hour"},
+		{time.Minute, "Minute"},
+		{time.Second, "Second"},
+		{time.Millisecond, "Millisecond"},
+		{time.Microsecond, "Microsecond"},
+	} {
+		if d%u.d == 0 {
+			if d == u.d {
+				return "time." + u.name
+			}
+			return fmt.Sprintf("%d * time.%s", d/u.d, u.name)
+		}
+	}
+	return fmt.Sprintf("%d", int64(d))
+}
+
+// structTag returns the struct tag, including its quotes, for a field that
+// defines f.
+func structTag(f *flag.Flag) string {
+	tag := "flag=" + f.Name
+	if u := f.Usage; u != "" {
+		if keyRegexp.MatchString(u) || strings.HasPrefix(u, "'") || strings.TrimSpace(u) != u {
+			u = "'" + strings.ReplaceAll(u, "'", "''") + "'"
+		}
+		tag += ", " + u
+	}
+	if strings.Contains(tag, "`") {
+		// A backquoted usage names the flag's value. Use the bare form of
+		// the tag, which can be written with double quotes.
+		return strconv.Quote(tag)
+	}
+	return "`cli:" + strconv.Quote(tag) + "`"
+}
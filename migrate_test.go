@@ -0,0 +1,97 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type levelFlag int
+
+func (l *levelFlag) String() string     { return "" }
+func (l *levelFlag) Set(s string) error { return nil }
+
+func TestGenFromFlagSet(t *testing.T) {
+	fs := flag.NewFlagSet("/usr/bin/serve", flag.ContinueOnError)
+	fs.Int("port", 8080, "port to listen on")
+	fs.Bool("v", false, "verbose output")
+	fs.String("in", "", "read the input from `file`")
+	fs.String("dry-run", "", "name=value pairs, comma-separated")
+	fs.Duration("timeout", 90*time.Second, "")
+	fs.Float64("rate", 0.5, " how fast ")
+	fs.Var(new(levelFlag), "level", "log level")
+	fs.Func("header", "add a header", func(string) error { return nil })
+	fs.Bool("run", true, "run the server")
+	// The defaults, not the values from the command line, are generated.
+	if err := fs.Parse([]string{"-port", "9090", "-v", "-in", "x", "-rate", "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := GenFromFlagSet(&b, fs); err != nil {
+		t.Fatal(err)
+	}
+	want := "// Code generated from the flags of serve by cli.GenFromFlagSet.\n" + `
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/jba/cli"
+)
+
+type command struct {
+	DryRun string ` + "`" + `cli:"flag=dry-run, 'name=value pairs, comma-separated'"` + "`" + `
+	// TODO: -header was defined with flag.Func or similar; give it a type.
+	Header  string        ` + "`" + `cli:"flag=header, add a header"` + "`" + `
+	In      string        "flag=in, read the input from ` + "`file`" + `"
+	Level   cli.levelFlag ` + "`" + `cli:"flag=level, log level"` + "`" + `
+	Port    int           ` + "`" + `cli:"flag=port, port to listen on"` + "`" + `
+	Rate    float64       ` + "`" + `cli:"flag=rate, ' how fast '"` + "`" + `
+	Run2    bool          ` + "`" + `cli:"flag=run, run the server"` + "`" + `
+	Timeout time.Duration ` + "`" + `cli:"flag=timeout"` + "`" + `
+	V       bool          ` + "`" + `cli:"flag=v, verbose output"` + "`" + `
+}
+
+func (c *command) Run(ctx context.Context) error {
+	// TODO: do what the program did, using the fields of c in place of the flag variables.
+	return nil
+}
+
+func main() {
+	top := cli.Top(&cli.Command{
+		Name: "serve",
+		Struct: &command{
+			Port:    8080,
+			Rate:    0.5,
+			Run2:    true,
+			Timeout: 90 * time.Second,
+		},
+	})
+	os.Exit(top.Main(context.Background()))
+}
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	for _, test := range []struct{ in, want string }{
+		{"v", "V"},
+		{"dry-run", "DryRun"},
+		{"db.host", "DbHost"},
+		{"2fa", "F2fa"},
+	} {
+		if got := fieldName(test.in); got != test.want {
+			t.Errorf("fieldName(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
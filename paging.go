@@ -0,0 +1,125 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+)
+
+// Paging through results.
+
+// PageOptions is a bundle of flags for commands that list results which a
+// server returns a page at a time. Pass a *PageOptions to Command or Register,
+// with the defaults for the command in its fields:
+//
+//	top.Command("list", &list{}, "list issues", &cli.PageOptions{Limit: 30})
+//
+// and fetch the results with a Pager or Paginate.
+type PageOptions struct {
+	Limit    int  `cli:"flag=limit, minval=0, xor=limit, maximum number of results, or 0 for no limit"`
+	PageSize int  `cli:"flag=page-size, minval=0, number of results to fetch per request"`
+	All      bool `cli:"flag=all, xor=limit, fetch all results, however many"`
+}
+
+// A PageFunc fetches a page of results. The token is empty for the first
+// page, and otherwise is the one returned with the previous page. It returns
+// the results and the token for the next page, which is empty if there are no
+// more. A pageSize of zero means the server's default.
+type PageFunc[T any] func(ctx context.Context, pageSize int, token string) (items []T, next string, err error)
+
+// A Pager iterates over results fetched by a PageFunc, stopping at the limit
+// set by PageOptions. Use it like a bufio.Scanner:
+//
+//	p := cli.NewPager(ctx, opts, fetch)
+//	for p.Next() {
+//	  fmt.Fprintln(cli.Stdout(ctx), p.Item())
+//	}
+//	if err := p.Err(); err != nil {
+//	  return err
+//	}
+//
+// If results remain when a Pager reaches the limit, it writes a notice to
+// standard error saying so, leaving standard output to the results.
+type Pager[T any] struct {
+	ctx   context.Context
+	opts  PageOptions
+	fetch PageFunc[T]
+
+	items   []T    // the current page
+	token   string // for the next page
+	started bool   // whether the first page was fetched
+	n       int    // number of items returned by Item
+	item    T
+	err     error
+}
+
+// NewPager returns a Pager for the results fetched by fetch. If opts is nil,
+// there is no limit.
+func NewPager[T any](ctx context.Context, opts *PageOptions, fetch PageFunc[T]) *Pager[T] {
+	p := &Pager[T]{ctx: ctx, fetch: fetch}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.All {
+		p.opts.Limit = 0
+	}
+	return p
+}
+
+// Next advances to the next result, fetching a page if necessary. It returns
+// false when there are no more results, the limit is reached, or there is an
+// error.
+func (p *Pager[T]) Next() bool {
+	if p.err != nil {
+		return false
+	}
+	limit := p.opts.Limit
+	for len(p.items) == 0 {
+		if p.started && p.token == "" {
+			return false
+		}
+		if limit > 0 && p.n >= limit {
+			p.notice()
+			return false
+		}
+		size := p.opts.PageSize
+		if limit > 0 && (size == 0 || size > limit-p.n) {
+			size = limit - p.n
+		}
+		p.items, p.token, p.err = p.fetch(p.ctx, size, p.token)
+		p.started = true
+		if p.err != nil {
+			return false
+		}
+	}
+	if limit > 0 && p.n >= limit {
+		p.notice()
+		return false
+	}
+	p.item, p.items = p.items[0], p.items[1:]
+	p.n++
+	return true
+}
+
+func (p *Pager[T]) notice() {
+	fmt.Fprintf(Stderr(p.ctx), "Showing the first %d results. Use -all to see them all, or -limit to see more.\n", p.n)
+}
+
+// Item returns the current result.
+func (p *Pager[T]) Item() T { return p.item }
+
+// Err returns the error, if any, from fetching results.
+func (p *Pager[T]) Err() error { return p.err }
+
+// Paginate returns the results of fetch, up to the limit set by opts.
+// It is for commands that need all the results before writing any, as with
+// WriteJSON.
+func Paginate[T any](ctx context.Context, opts *PageOptions, fetch PageFunc[T]) ([]T, error) {
+	var items []T
+	p := NewPager(ctx, opts, fetch)
+	for p.Next() {
+		items = append(items, p.Item())
+	}
+	return items, p.Err()
+}
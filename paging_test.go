@@ -0,0 +1,100 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type lister struct {
+	opts *PageOptions
+
+	got   []int
+	sizes []int
+}
+
+func (l *lister) Run(ctx context.Context) error {
+	l.got, l.sizes = nil, nil
+	// Serve the numbers 0 to 24, 10 at a time by default.
+	fetch := func(_ context.Context, size int, token string) ([]int, string, error) {
+		l.sizes = append(l.sizes, size)
+		if size == 0 {
+			size = 10
+		}
+		start := 0
+		if token != "" {
+			start, _ = strconv.Atoi(token)
+		}
+		var items []int
+		for i := start; i < start+size && i < 25; i++ {
+			items = append(items, i)
+		}
+		next := ""
+		if start+size < 25 {
+			next = strconv.Itoa(start + size)
+		}
+		return items, next, nil
+	}
+	var err error
+	l.got, err = Paginate(ctx, l.opts, fetch)
+	return err
+}
+
+func TestPaging(t *testing.T) {
+	for _, test := range []struct {
+		args      []string
+		wantN     int
+		wantSizes []int
+		notice    bool
+	}{
+		{nil, 15, []int{10, 5}, true},
+		{[]string{"-limit", "25"}, 25, []int{10, 10, 5}, false},
+		{[]string{"-all"}, 25, []int{10, 10, 10}, false},
+		{[]string{"-limit", "0", "-page-size", "20"}, 25, []int{20, 20}, false},
+		{[]string{"-limit", "3"}, 3, []int{3}, true},
+	} {
+		top := &Command{Name: "prog"}
+		initFlags(top)
+		l := &lister{opts: &PageOptions{Limit: 15, PageSize: 10}}
+		top.Command("list", l, "", l.opts)
+		var stderr bytes.Buffer
+		ctx := withInvocation(context.Background(), &invocation{stderr: &stderr})
+		if err := top.Run(ctx, append([]string{"list"}, test.args...)); err != nil {
+			t.Fatal(err)
+		}
+		if len(l.got) != test.wantN {
+			t.Errorf("%v: got %d results, want %d", test.args, len(l.got), test.wantN)
+		}
+		for i, n := range l.got {
+			if n != i {
+				t.Errorf("%v: result %d is %d", test.args, i, n)
+				break
+			}
+		}
+		if !slices.Equal(l.sizes, test.wantSizes) {
+			t.Errorf("%v: page sizes %v, want %v", test.args, l.sizes, test.wantSizes)
+		}
+		if got := strings.Contains(stderr.String(), "Showing the first"); got != test.notice {
+			t.Errorf("%v: notice = %t, want %t", test.args, got, test.notice)
+		}
+	}
+}
+
+func TestPagingFlagErrors(t *testing.T) {
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	top.Command("list", &lister{}, "", &PageOptions{})
+	for _, args := range [][]string{
+		{"list", "-limit", "5", "-all"},
+		{"list", "-page-size", "-1"},
+	} {
+		if err := top.Run(context.Background(), args); err == nil {
+			t.Errorf("%v: got nil error", args)
+		}
+	}
+}
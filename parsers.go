@@ -3,8 +3,15 @@
 package cli
 
 import (
+	"cmp"
+	"encoding"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -16,21 +23,49 @@ import (
 type parseFunc func(string) (interface{}, error)
 
 // buildParser constructs a parser for type t, or for the list of choices.
-func buildParser(t reflect.Type, choices []string, isFlag bool) (parseFunc, error) {
+// If norm is not nil, it is applied to each string value before it is
+// converted or checked against choices. The elements of slice flags and the
+// pairs of maps are separated by sep, or by commas if sep is empty.
+func buildParser(t reflect.Type, choices []string, norm normalizer, isFlag bool, sep string) (parseFunc, error) {
+	if sep == "" {
+		sep = ","
+	}
+	if hasParseMethod(t) {
+		// Some TextUnmarshalers, like net.IP, are slices.
+		return parserForElem(t, choices, norm)
+	}
+	if t.Kind() == reflect.Map {
+		return parserForMap(t, choices, norm, sep)
+	}
 	if t.Kind() != reflect.Slice {
-		return parserForType(t, choices)
+		return parserForElem(t, choices, norm)
 	} else if isFlag {
-		return parserForSlice(t, choices, ",")
+		return parserForSlice(t, choices, norm, sep)
 	} else {
-		return parserForType(t.Elem(), choices)
+		return parserForElem(t.Elem(), choices, norm)
 	}
 }
 
+// parserForElem is like parserForType, but applies norm, if not nil, to the
+// string first.
+func parserForElem(t reflect.Type, choices []string, norm normalizer) (parseFunc, error) {
+	p, err := parserForType(t, choices)
+	if err != nil || norm == nil {
+		return p, err
+	}
+	if t.Kind() != reflect.String || hasParseMethod(t) {
+		return nil, fmt.Errorf("normalize is only for strings, not %s", t)
+	}
+	return func(s string) (interface{}, error) {
+		return p(norm(s))
+	}, nil
+}
+
 // parserForSlice returns a parser for a string representing a slice of values.
 // t is the slice type.
 // sep separates elements in the string.
-func parserForSlice(t reflect.Type, choices []string, sep string) (parseFunc, error) {
-	elp, err := parserForType(t.Elem(), choices)
+func parserForSlice(t reflect.Type, choices []string, norm normalizer, sep string) (parseFunc, error) {
+	elp, err := parserForElem(t.Elem(), choices, norm)
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +84,64 @@ func parserForSlice(t reflect.Type, choices []string, sep string) (parseFunc, er
 	}, nil
 }
 
-var durationType = reflect.TypeOf(time.Duration(0))
+// parserForMap returns a parser for a string representing a map.
+// t is the map type.
+// sep separates key=value pairs in the string.
+// If choices is non-nil, map values must be one of them.
+// If norm is non-nil, it applies to map values.
+func parserForMap(t reflect.Type, choices []string, norm normalizer, sep string) (parseFunc, error) {
+	kp, err := parserForType(t.Key(), nil)
+	if err != nil {
+		return nil, err
+	}
+	vp, err := parserForElem(t.Elem(), choices, norm)
+	if err != nil {
+		return nil, err
+	}
+	return func(s string) (interface{}, error) {
+		m := reflect.MakeMap(t)
+		for _, p := range strings.Split(s, sep) {
+			p = strings.TrimSpace(p)
+			ks, vs, found := stringsCut(p, "=")
+			if !found {
+				return nil, fmt.Errorf("%q: missing '='", p)
+			}
+			k, err := kp(strings.TrimSpace(ks))
+			if err != nil {
+				return nil, fmt.Errorf("%q: key: %v", p, err)
+			}
+			v, err := vp(strings.TrimSpace(vs))
+			if err != nil {
+				return nil, fmt.Errorf("%q: value: %v", p, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
+		}
+		return m.Interface(), nil
+	}, nil
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	flagValueType       = reflect.TypeOf((*flag.Value)(nil)).Elem()
+)
+
+// hasParseMethod reports whether values of t are parsed by one of their
+// methods, rather than according to their kind.
+func hasParseMethod(t reflect.Type) bool {
+	return isFlagValue(t) || isTextUnmarshaler(t)
+}
+
+// isFlagValue reports whether a pointer to t implements flag.Value.
+func isFlagValue(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(flagValueType)
+}
+
+// isTextUnmarshaler reports whether t or a pointer to t implements
+// encoding.TextUnmarshaler.
+func isTextUnmarshaler(t reflect.Type) bool {
+	return t.Implements(textUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
 
 // parserForType returns a parser for scalar types.
 func parserForType(t reflect.Type, choices []string) (parseFunc, error) {
@@ -64,6 +156,12 @@ func parserForType(t reflect.Type, choices []string) (parseFunc, error) {
 			return time.ParseDuration(s)
 		}, nil
 	}
+	if isFlagValue(t) {
+		return parserForFlagValue(t), nil
+	}
+	if isTextUnmarshaler(t) {
+		return parserForTextUnmarshaler(t), nil
+	}
 
 	convert := func(v interface{}) interface{} {
 		return reflect.ValueOf(v).Convert(t).Interface()
@@ -111,6 +209,276 @@ func parserForType(t reflect.Type, choices []string) (parseFunc, error) {
 	}
 }
 
+// parserForFlagValue returns a parser that uses the Set method of *t.
+func parserForFlagValue(t reflect.Type) parseFunc {
+	return func(s string) (interface{}, error) {
+		p := reflect.New(t)
+		if err := p.Interface().(flag.Value).Set(s); err != nil {
+			return nil, err
+		}
+		return p.Elem().Interface(), nil
+	}
+}
+
+// parserForTextUnmarshaler returns a parser that uses the UnmarshalText method
+// of t or *t.
+func parserForTextUnmarshaler(t reflect.Type) parseFunc {
+	return func(s string) (interface{}, error) {
+		var p reflect.Value // pointer to a new value
+		if t.Kind() == reflect.Ptr && t.Implements(textUnmarshalerType) {
+			p = reflect.New(t.Elem())
+		} else {
+			p = reflect.New(t)
+		}
+		if err := p.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return nil, err
+		}
+		if t.Kind() == reflect.Ptr {
+			return p.Interface(), nil
+		}
+		return p.Elem().Interface(), nil
+	}
+}
+
+// A normalizer converts a string to a canonical form.
+type normalizer func(string) string
+
+// normalizers are the values of the "normalize" tag key.
+var normalizers = map[string]normalizer{
+	"trim":  strings.TrimSpace,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"path":  filepath.Clean,
+}
+
+// buildNormalizer returns a normalizer that applies each of the "|"-separated
+// normalizers named in spec, in order.
+func buildNormalizer(spec string) (normalizer, error) {
+	var ns []normalizer
+	for _, name := range strings.Split(spec, "|") {
+		name = strings.TrimSpace(name)
+		n, ok := normalizers[name]
+		if !ok {
+			return nil, fmt.Errorf("normalize: unknown normalizer %q", name)
+		}
+		ns = append(ns, n)
+	}
+	return func(s string) string {
+		for _, n := range ns {
+			s = n(s)
+		}
+		return s
+	}, nil
+}
+
+// rangeParser wraps p so that it checks that its result, or each element of its
+// result if it is a slice, lies within a range. The bounds minval and maxval
+// are strings to be parsed as values of t's element type; either may be empty,
+// meaning no bound. rangeParser also returns a description of the range.
+func rangeParser(p parseFunc, t reflect.Type, minval, maxval string) (parseFunc, string, error) {
+	et := t
+	if t.Kind() == reflect.Slice && !hasParseMethod(t) {
+		et = t.Elem()
+	}
+	if hasParseMethod(et) {
+		return nil, "", fmt.Errorf("minval and maxval are not supported for %s", t)
+	}
+	switch et.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+	default:
+		return nil, "", fmt.Errorf("minval and maxval are only for numbers and durations, not %s", t)
+	}
+	ep, err := parserForType(et, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	bound := func(s string) (reflect.Value, error) {
+		if s == "" {
+			return reflect.Value{}, nil
+		}
+		v, err := ep(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(v), nil
+	}
+	min, err := bound(minval)
+	if err != nil {
+		return nil, "", fmt.Errorf("minval: %v", err)
+	}
+	max, err := bound(maxval)
+	if err != nil {
+		return nil, "", fmt.Errorf("maxval: %v", err)
+	}
+	var desc string
+	switch {
+	case minval != "" && maxval != "":
+		if compareNumbers(min, max) > 0 {
+			return nil, "", errors.New("minval is greater than maxval")
+		}
+		desc = fmt.Sprintf("between %s and %s", minval, maxval)
+	case minval != "":
+		desc = "at least " + minval
+	default:
+		desc = "at most " + maxval
+	}
+
+	check := func(v reflect.Value) error {
+		if (min.IsValid() && compareNumbers(v, min) < 0) || (max.IsValid() && compareNumbers(v, max) > 0) {
+			return errors.New("must be " + desc)
+		}
+		return nil
+	}
+	return func(s string) (interface{}, error) {
+		x, err := p(s)
+		if err != nil {
+			return nil, err
+		}
+		v := reflect.ValueOf(x)
+		if v.Kind() == reflect.Slice && et != t {
+			for i := 0; i < v.Len(); i++ {
+				if err := check(v.Index(i)); err != nil {
+					return nil, err
+				}
+			}
+		} else if err := check(v); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}, desc, nil
+}
+
+// matchParser wraps p so that it checks that its result, or each element of its
+// result if it is a slice, matches re. If msg is not empty, it is the error
+// message for a value that doesn't match.
+func matchParser(p parseFunc, t reflect.Type, re *regexp.Regexp, msg string) (parseFunc, error) {
+	et := t
+	if t.Kind() == reflect.Slice && !hasParseMethod(t) {
+		et = t.Elem()
+	}
+	if et.Kind() != reflect.String || hasParseMethod(et) {
+		return nil, fmt.Errorf("match is only for strings, not %s", t)
+	}
+	if msg == "" {
+		msg = fmt.Sprintf("must match %s", re)
+	}
+	check := func(v reflect.Value) error {
+		if !re.MatchString(v.String()) {
+			return errors.New(msg)
+		}
+		return nil
+	}
+	return func(s string) (interface{}, error) {
+		x, err := p(s)
+		if err != nil {
+			return nil, err
+		}
+		v := reflect.ValueOf(x)
+		if et != t {
+			for i := 0; i < v.Len(); i++ {
+				if err := check(v.Index(i)); err != nil {
+					return nil, err
+				}
+			}
+		} else if err := check(v); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}, nil
+}
+
+// pathParser wraps p so that it checks that its result, or each element of its
+// result if it is a slice, is a file path of the kind described by pathType:
+// "existingfile", "existingdir", or "glob:" followed by a pattern that the
+// path's last element must match. pathParser also returns a description of
+// the kind.
+func pathParser(p parseFunc, t reflect.Type, pathType string) (parseFunc, string, error) {
+	et := t
+	if t.Kind() == reflect.Slice && !hasParseMethod(t) {
+		et = t.Elem()
+	}
+	if et.Kind() != reflect.String || hasParseMethod(et) {
+		return nil, "", fmt.Errorf("type is only for strings, not %s", t)
+	}
+	var (
+		desc  string
+		check func(string) error
+	)
+	switch {
+	case pathType == "existingfile":
+		desc = "an existing file"
+		check = func(path string) error {
+			fi, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() {
+				return fmt.Errorf("%s is a directory", path)
+			}
+			return nil
+		}
+	case pathType == "existingdir":
+		desc = "an existing directory"
+		check = func(path string) error {
+			fi, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() {
+				return fmt.Errorf("%s is not a directory", path)
+			}
+			return nil
+		}
+	case strings.HasPrefix(pathType, "glob:"):
+		pattern := strings.TrimPrefix(pathType, "glob:")
+		if _, err := filepath.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, "", fmt.Errorf("type: bad glob pattern %q", pattern)
+		}
+		desc = "a file matching " + pattern
+		check = func(path string) error {
+			if ok, _ := filepath.Match(pattern, filepath.Base(path)); !ok {
+				return fmt.Errorf("%s does not match %s", path, pattern)
+			}
+			return nil
+		}
+	default:
+		return nil, "", fmt.Errorf(`type: got %q, want "existingfile", "existingdir" or "glob:PATTERN"`, pathType)
+	}
+	return func(s string) (interface{}, error) {
+		x, err := p(s)
+		if err != nil {
+			return nil, err
+		}
+		v := reflect.ValueOf(x)
+		if v.Kind() == reflect.Slice && et != t {
+			for i := 0; i < v.Len(); i++ {
+				if err := check(v.Index(i).String()); err != nil {
+					return nil, err
+				}
+			}
+		} else if err := check(v.String()); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}, desc, nil
+}
+
+// compareNumbers compares two numeric values of the same kind.
+func compareNumbers(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cmp.Compare(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float())
+	default:
+		panic(fmt.Sprintf("compareNumbers: bad kind %s", a.Kind()))
+	}
+}
+
 func parserForOneof(choices []string) parseFunc {
 	return func(s string) (interface{}, error) {
 		if err := checkOneof(s, choices); err != nil {
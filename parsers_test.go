@@ -3,8 +3,11 @@
 package cli
 
 import (
+	"math/big"
+	"net"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -63,6 +66,32 @@ func TestParsers(t *testing.T) {
 			input:  "1 , -2,3",
 			want:   []int{1, -2, 3},
 		},
+		{
+			name:   "map flag",
+			tval:   map[string]int(nil),
+			isFlag: true,
+			input:  "a=1, b = 2",
+			want:   map[string]int{"a": 1, "b": 2},
+		},
+		{
+			name:  "map arg",
+			tval:  map[string]string(nil),
+			input: "k=v=w",
+			want:  map[string]string{"k": "v=w"},
+		},
+		{
+			name:  "TextUnmarshaler slice",
+			tval:  net.IP(nil),
+			input: "1.2.3.4",
+			want:  net.ParseIP("1.2.3.4"),
+		},
+		{
+			name:   "TextUnmarshaler in slice flag",
+			tval:   []time.Time(nil),
+			isFlag: true,
+			input:  "2021-01-01T00:00:00Z",
+			want:   []time.Time{time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
 		{
 			name:    "oneof",
 			tval:    "",
@@ -72,7 +101,7 @@ func TestParsers(t *testing.T) {
 		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
-			parser, err := buildParser(reflect.TypeOf(test.tval), test.choices, test.isFlag)
+			parser, err := buildParser(reflect.TypeOf(test.tval), test.choices, nil, test.isFlag, "")
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -86,3 +115,57 @@ func TestParsers(t *testing.T) {
 		})
 	}
 }
+
+func TestTextUnmarshalerPointer(t *testing.T) {
+	parser, err := buildParser(reflect.TypeOf((*big.Int)(nil)), nil, nil, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parser("12345678901234567890")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := got.(*big.Int).String(); g != "12345678901234567890" {
+		t.Errorf("got %s", g)
+	}
+}
+
+func TestRangeParser(t *testing.T) {
+	for _, test := range []struct {
+		tval           interface{}
+		isFlag         bool
+		minval, maxval string
+		input          string
+		wantErr        string
+	}{
+		{tval: 0, minval: "1", maxval: "65535", input: "80"},
+		{tval: 0, minval: "1", maxval: "65535", input: "0", wantErr: "must be between 1 and 65535"},
+		{tval: uint8(0), maxval: "10", input: "11", wantErr: "must be at most 10"},
+		{tval: 0.0, minval: "0.5", input: "0.25", wantErr: "must be at least 0.5"},
+		{tval: time.Duration(0), minval: "1s", input: "500ms", wantErr: "must be at least 1s"},
+		{tval: []int(nil), isFlag: true, maxval: "3", input: "1,2,4", wantErr: "must be at most 3"},
+		{tval: []int(nil), maxval: "3", input: "3"},
+	} {
+		typ := reflect.TypeOf(test.tval)
+		p, err := buildParser(typ, nil, nil, test.isFlag, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, _, err = rangeParser(p, typ, test.minval, test.maxval)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = p(test.input)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.wantErr {
+			t.Errorf("%T %q: got error %q, want %q", test.tval, test.input, got, test.wantErr)
+		}
+	}
+
+	if _, _, err := rangeParser(nil, reflect.TypeOf(""), "a", ""); err == nil {
+		t.Error("string: got nil, want error")
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Prompting for values interactively.
+
+// Select asks the user to choose a value for field from its choices. Field
+// must be a pointer to a field of the running command's struct that is a flag
+// or argument with a "oneof" tag key. Select lists the choices, numbered, on
+// standard error and reads a number or a choice from standard input, asking
+// again until the answer is valid. The answer is checked and stored exactly as
+// it would be if it were given on the command line.
+//
+// A Run method can call Select when a value is missing. Select returns an
+// error if the command is not Interactive, so that scripts fail rather than
+// hang.
+func Select(ctx context.Context, field interface{}) error {
+	return prompt(ctx, field, promptSelect)
+}
+
+// MultiSelect is like Select, but for a slice field. The user chooses any
+// number of the choices, separated by commas or spaces. An empty answer leaves
+// the field unchanged.
+func MultiSelect(ctx context.Context, field interface{}) error {
+	return prompt(ctx, field, promptMulti)
+}
+
+// Input asks the user for a value for field, which must be a pointer to a
+// field of the running command's struct that is a flag or argument. The answer
+// is checked and stored as it would be if it were given on the command line;
+// if it isn't valid, Input asks again. Like Select, Input requires the
+// command to be Interactive.
+func Input(ctx context.Context, field interface{}) error {
+	return prompt(ctx, field, promptInput)
+}
+
+type promptMode int
+
+const (
+	promptSelect promptMode = iota
+	promptMulti
+	promptInput
+)
+
+// A promptParam is a flag or argument that a prompt can set.
+type promptParam struct {
+	label   string
+	usage   string
+	choices []string
+	multi   bool                 // field is a slice
+	set     func(s string) error // parse, check and store
+}
+
+func prompt(ctx context.Context, field interface{}, mode promptMode) error {
+	inv := invocationOrDefault(ctx)
+	p, err := findPromptParam(inv.cmd, field)
+	if err != nil {
+		return err
+	}
+	switch mode {
+	case promptSelect, promptMulti:
+		if p.choices == nil {
+			return fmt.Errorf("%s has no choices", p.label)
+		}
+		if p.multi != (mode == promptMulti) {
+			if p.multi {
+				return fmt.Errorf("%s is a slice; use MultiSelect", p.label)
+			}
+			return fmt.Errorf("%s is not a slice; use Select", p.label)
+		}
+	}
+	if !Interactive(ctx) {
+		return fmt.Errorf("%s: cannot prompt because the command is not interactive", p.label)
+	}
+	return runPrompt(inv.stdin, inv.stderr, p, mode)
+}
+
+// runPrompt asks for a value for p until it gets a valid one.
+func runPrompt(in io.Reader, out io.Writer, p *promptParam, mode promptMode) error {
+	fmt.Fprint(out, p.label)
+	if p.usage != "" {
+		fmt.Fprintf(out, " (%s)", p.usage)
+	}
+	fmt.Fprintln(out, ":")
+	if mode != promptInput {
+		for i, c := range p.choices {
+			fmt.Fprintf(out, "  %d) %s\n", i+1, c)
+		}
+	}
+	r := bufio.NewReader(in)
+	for {
+		switch mode {
+		case promptSelect:
+			fmt.Fprintf(out, "Choose 1-%d: ", len(p.choices))
+		case promptMulti:
+			fmt.Fprintf(out, "Choose any of 1-%d, separated by commas: ", len(p.choices))
+		default:
+			fmt.Fprint(out, "> ")
+		}
+		line, err := r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return fmt.Errorf("%s: no answer", p.label)
+			}
+			return err
+		}
+		answer := strings.TrimSpace(line)
+		if mode == promptMulti && answer == "" {
+			return nil
+		}
+		if mode != promptInput {
+			answer, err = resolveChoices(answer, p.choices, mode == promptMulti)
+		}
+		if err == nil {
+			err = p.set(answer)
+		}
+		if err == nil {
+			return nil
+		}
+		fmt.Fprintf(out, "invalid: %v\n", err)
+	}
+}
+
+// resolveChoices converts an answer to a selection prompt into the form of a
+// command-line value. Each part of the answer can be a choice or its number.
+func resolveChoices(answer string, choices []string, multi bool) (string, error) {
+	parts := []string{answer}
+	if multi {
+		parts = strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	for i, part := range parts {
+		if n, err := strconv.Atoi(part); err == nil {
+			if n < 1 || n > len(choices) {
+				return "", fmt.Errorf("%d is not between 1 and %d", n, len(choices))
+			}
+			parts[i] = choices[n-1]
+		}
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// findPromptParam returns the flag or argument of c whose field ptr points to.
+func findPromptParam(c *Command, ptr interface{}) (*promptParam, error) {
+	pv := reflect.ValueOf(ptr)
+	if pv.Kind() != reflect.Ptr || pv.IsNil() {
+		return nil, fmt.Errorf("%T is not a non-nil pointer", ptr)
+	}
+	if c == nil {
+		return nil, errors.New("no command is running")
+	}
+	same := func(field reflect.Value) bool {
+		return field.IsValid() && field.CanAddr() && field.Addr().Pointer() == pv.Pointer() && field.Type() == pv.Elem().Type()
+	}
+	for _, f := range c.formals {
+		if same(f.field) {
+			f := f
+			return &promptParam{
+				label:   f.Name,
+				usage:   f.Usage,
+				choices: f.Choices,
+				multi:   f.Variadic,
+				set: func(s string) error {
+					if !f.Variadic {
+						v, err := f.parser(s)
+						if err != nil {
+							return err
+						}
+						f.field.Set(reflect.ValueOf(v))
+						return nil
+					}
+					slice := reflect.MakeSlice(f.field.Type(), 0, 1)
+					for _, part := range strings.Split(s, ",") {
+						v, err := f.parser(part)
+						if err != nil {
+							return err
+						}
+						slice = reflect.Append(slice, reflect.ValueOf(v))
+					}
+					f.field.Set(slice)
+					return nil
+				},
+			}, nil
+		}
+	}
+	for _, fs := range c.flagSpecs {
+		if same(fs.value) {
+			fl := c.flags.Lookup(fs.Name)
+			return &promptParam{
+				label:   "-" + fs.Name,
+				usage:   fl.Usage,
+				choices: fs.Choices,
+				multi:   fs.value.Kind() == reflect.Slice && !hasParseMethod(fs.value.Type()),
+				set:     fl.Value.Set,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("%T does not point to a flag or argument of %s", ptr, c.Path())
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type deployer struct {
+	Env     string   `cli:"flag=env, oneof=dev|staging|prod, environment"`
+	Regions []string `cli:"flag=regions, oneof=us|eu|asia, regions"`
+	Count   int      `cli:"flag=count, minval=1, number of replicas"`
+	Service string   `cli:"oneof=api|web, the service"`
+
+	err error
+}
+
+func (d *deployer) Run(ctx context.Context) error {
+	d.err = Select(ctx, &d.Env)
+	return nil
+}
+
+func TestPrompt(t *testing.T) {
+	d := &deployer{}
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	cmd := top.Command("deploy", d, "")
+
+	ask := func(ptr interface{}, mode promptMode, input string) string {
+		t.Helper()
+		p, err := findPromptParam(cmd, ptr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		if err := runPrompt(strings.NewReader(input), &out, p, mode); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	out := ask(&d.Env, promptSelect, "7\nqa\n3\n")
+	if d.Env != "prod" {
+		t.Errorf("Env: got %q, want %q", d.Env, "prod")
+	}
+	want := `-env (environment; one of dev, staging, prod):
+  1) dev
+  2) staging
+  3) prod
+Choose 1-3: invalid: 7 is not between 1 and 3
+Choose 1-3: invalid: must be one of: dev, staging, prod
+Choose 1-3: `
+	if diff := cmp.Diff(want, out); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	ask(&d.Service, promptSelect, "web\n")
+	if d.Service != "web" {
+		t.Errorf("Service: got %q, want %q", d.Service, "web")
+	}
+
+	ask(&d.Regions, promptMulti, "1, asia\n")
+	if want := []string{"us", "asia"}; !cmp.Equal(d.Regions, want) {
+		t.Errorf("Regions: got %q, want %q", d.Regions, want)
+	}
+
+	out = ask(&d.Count, promptInput, "0\n4\n")
+	if d.Count != 4 {
+		t.Errorf("Count: got %d, want 4", d.Count)
+	}
+	if !strings.Contains(out, "invalid: ") {
+		t.Errorf("no complaint about 0:\n%s", out)
+	}
+
+	// Errors.
+	p, err := findPromptParam(cmd, &d.err)
+	if err == nil {
+		t.Errorf("found %+v for a field that is not a parameter", p)
+	}
+	// Standard input is not a terminal.
+	ctx := withInvocation(context.Background(), &invocation{stdin: strings.NewReader("1\n")})
+	if err := top.Run(ctx, []string{"deploy", "api"}); err != nil {
+		t.Fatal(err)
+	}
+	if d.err == nil || !strings.Contains(d.err.Error(), "not interactive") {
+		t.Errorf("got %v, want error about interactivity", d.err)
+	}
+}
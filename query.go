@@ -0,0 +1,279 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Querying JSON results.
+
+// A Query extracts values from JSON data. It supports a small subset of the
+// language of the jq program, so users can get at parts of a command's
+// results on systems without jq:
+//
+//	.            the whole value
+//	.name        a field of an object; also .["name"]
+//	.[2]         an element of an array; negative indexes count from the end
+//	.[]          each element of an array or value of an object
+//	length       the length of an array, object or string
+//	keys         the sorted keys of an object, or the indexes of an array
+//	a | b        the results of b applied to each result of a
+//
+// Path elements can be chained, as in ".items[].owner.name". Indexing null
+// yields null, as in jq.
+//
+// A *Query is a flag.Value, so it can be the type of a flag. See QueryOptions
+// for a bundle with a standard "-query" flag.
+type Query struct {
+	src   string
+	pipes [][]queryStep // each element of a pipeline is a path
+}
+
+// A queryStep is one step of a path: a field name, an index, an iteration or
+// a function.
+type queryStep struct {
+	kind  byte // '.' field, '[' index, 'i' iterate, 'f' function
+	name  string
+	index int
+}
+
+// ParseQuery parses a query expression.
+func ParseQuery(s string) (*Query, error) {
+	q := &Query{src: s}
+	for _, part := range strings.Split(s, "|") {
+		path, err := parseQueryPath(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("query %q: %w", s, err)
+		}
+		q.pipes = append(q.pipes, path)
+	}
+	return q, nil
+}
+
+func parseQueryPath(s string) ([]queryStep, error) {
+	switch s {
+	case "":
+		return nil, fmt.Errorf("empty expression")
+	case "length", "keys":
+		return []queryStep{{kind: 'f', name: s}}, nil
+	}
+	if s[0] != '.' {
+		return nil, fmt.Errorf("expression %q does not start with '.'", s)
+	}
+	var steps []queryStep
+	for len(s) > 0 {
+		switch {
+		case s[0] == '.' && len(s) > 1 && isQueryIdentByte(s[1]):
+			i := 1
+			for i < len(s) && isQueryIdentByte(s[i]) {
+				i++
+			}
+			steps = append(steps, queryStep{kind: '.', name: s[1:i]})
+			s = s[i:]
+		case s[0] == '.':
+			s = s[1:]
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']'")
+			}
+			inside := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case inside == "":
+				steps = append(steps, queryStep{kind: 'i'})
+			case inside[0] == '"':
+				name, err := strconv.Unquote(inside)
+				if err != nil {
+					return nil, fmt.Errorf("bad field name %s", inside)
+				}
+				steps = append(steps, queryStep{kind: '.', name: name})
+			default:
+				n, err := strconv.Atoi(inside)
+				if err != nil {
+					return nil, fmt.Errorf("bad index %q", inside)
+				}
+				steps = append(steps, queryStep{kind: '[', index: n})
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q", s)
+		}
+	}
+	return steps, nil
+}
+
+func isQueryIdentByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// String implements flag.Value.
+func (q *Query) String() string {
+	if q == nil {
+		return ""
+	}
+	return q.src
+}
+
+// Set implements flag.Value.
+func (q *Query) Set(s string) error {
+	p, err := ParseQuery(s)
+	if err != nil {
+		return err
+	}
+	*q = *p
+	return nil
+}
+
+// IsZero reports whether q has no expression.
+func (q *Query) IsZero() bool {
+	return q == nil || q.pipes == nil
+}
+
+// Eval applies q to v and returns the results. The value v is first
+// converted to JSON, so fields are named by their JSON names.
+func (q *Query) Eval(v interface{}) ([]interface{}, error) {
+	j, err := jsonValue(v)
+	if err != nil {
+		return nil, err
+	}
+	vals := []interface{}{j}
+	for _, path := range q.pipes {
+		for _, st := range path {
+			var next []interface{}
+			for _, v := range vals {
+				r, err := st.eval(v)
+				if err != nil {
+					return nil, fmt.Errorf("query %q: %w", q.src, err)
+				}
+				next = append(next, r...)
+			}
+			vals = next
+		}
+	}
+	return vals, nil
+}
+
+func (st queryStep) eval(v interface{}) ([]interface{}, error) {
+	switch st.kind {
+	case '.':
+		switch v := v.(type) {
+		case nil:
+			return []interface{}{nil}, nil
+		case map[string]interface{}:
+			return []interface{}{v[st.name]}, nil
+		}
+		return nil, fmt.Errorf("cannot get field %q of %s", st.name, jsonKind(v))
+	case '[':
+		switch v := v.(type) {
+		case nil:
+			return []interface{}{nil}, nil
+		case []interface{}:
+			i := st.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i < 0 || i >= len(v) {
+				return []interface{}{nil}, nil
+			}
+			return []interface{}{v[i]}, nil
+		}
+		return nil, fmt.Errorf("cannot index %s with %d", jsonKind(v), st.index)
+	case 'i':
+		switch v := v.(type) {
+		case []interface{}:
+			return v, nil
+		case map[string]interface{}:
+			var r []interface{}
+			for _, k := range sortedKeys(v) {
+				r = append(r, v[k])
+			}
+			return r, nil
+		}
+		return nil, fmt.Errorf("cannot iterate over %s", jsonKind(v))
+	case 'f':
+		switch st.name {
+		case "length":
+			switch v := v.(type) {
+			case nil:
+				return []interface{}{0.0}, nil
+			case []interface{}:
+				return []interface{}{float64(len(v))}, nil
+			case map[string]interface{}:
+				return []interface{}{float64(len(v))}, nil
+			case string:
+				return []interface{}{float64(utf8.RuneCountInString(v))}, nil
+			}
+		case "keys":
+			switch v := v.(type) {
+			case []interface{}:
+				r := make([]interface{}, len(v))
+				for i := range v {
+					r[i] = float64(i)
+				}
+				return []interface{}{r}, nil
+			case map[string]interface{}:
+				var r []interface{}
+				for _, k := range sortedKeys(v) {
+					r = append(r, k)
+				}
+				return []interface{}{r}, nil
+			}
+		}
+		return nil, fmt.Errorf("%s has no %s", jsonKind(v), st.name)
+	}
+	panic("bad query step")
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonKind returns the name of the JSON type of v, for error messages.
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case []interface{}:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// jsonValue converts v to the form that encoding/json decodes JSON into,
+// with maps for objects and slices for arrays.
+func jsonValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var j interface{}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// QueryOptions is a bundle with a "-query" flag for commands that write their
+// results with WriteJSON. When the flag is set, WriteJSON writes the results
+// of the query, one JSON value per line, instead of the whole value.
+type QueryOptions struct {
+	Query Query `cli:"flag=query, 'extract values from the JSON output with a jq-like expression, like .items[].name'"`
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "a", "tags": []string{"x", "y"}},
+			map[string]interface{}{"name": "b", "owner": map[string]string{"name": "pat"}},
+		},
+		"total":     2,
+		"odd key":   true,
+		"something": nil,
+	}
+	for _, test := range []struct {
+		query string
+		want  string // results as JSON, separated by spaces
+	}{
+		{".", `{"items":[{"name":"a","tags":["x","y"]},{"name":"b","owner":{"name":"pat"}}],"odd key":true,"something":null,"total":2}`},
+		{".total", `2`},
+		{".items[0].name", `"a"`},
+		{".items[-1].name", `"b"`},
+		{".items[5]", `null`},
+		{".items[].name", `"a" "b"`},
+		{".items[].owner.name", `null "pat"`},
+		{".items[0].tags[]", `"x" "y"`},
+		{`.["odd key"]`, `true`},
+		{".something.deeper", `null`},
+		{".items | length", `2`},
+		{".items[0] | keys", `["name","tags"]`},
+		{".items[] | .name | length", `1 1`},
+		{"keys", `["items","odd key","something","total"]`},
+	} {
+		q, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("%q: %v", test.query, err)
+		}
+		vals, err := q.Eval(data)
+		if err != nil {
+			t.Fatalf("%q: %v", test.query, err)
+		}
+		var got []string
+		for _, v := range vals {
+			b, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, string(b))
+		}
+		if g := strings.Join(got, " "); g != test.want {
+			t.Errorf("%q: got %s, want %s", test.query, g, test.want)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	for _, s := range []string{"", "items", ".items[", ".items[x]", ".a | ", `.["a]`} {
+		if _, err := ParseQuery(s); err == nil {
+			t.Errorf("%q: got nil error", s)
+		}
+	}
+	for _, s := range []string{".total.x", ".total[0]", ".total[]", ".total | keys"} {
+		q, err := ParseQuery(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.Eval(map[string]int{"total": 2}); err == nil {
+			t.Errorf("%q: got nil error from Eval", s)
+		}
+	}
+}
+
+func TestQueryFlag(t *testing.T) {
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	top.Command("show", &issueWriter{}, "", &QueryOptions{})
+	stdout, stderr, code := top.ExecuteCapture(context.Background(), "show", "-query", ".owner.Name")
+	if code != 0 || strings.TrimSpace(stdout) != `"kim"` {
+		t.Errorf("got %q, %q, %d", stdout, stderr, code)
+	}
+	_, stderr, code = top.ExecuteCapture(context.Background(), "show", "-query", "owner")
+	if code != 2 || !strings.Contains(stderr, "does not start with '.'") {
+		t.Errorf("bad query: got %q, %d", stderr, code)
+	}
+}
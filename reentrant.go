@@ -0,0 +1,230 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+)
+
+// Running commands on fresh copies of their structs.
+
+// reentrant reports whether c or a command above it has Reentrant set.
+func (c *Command) reentrant() bool {
+	for ; c != nil; c = c.super {
+		if c.Reentrant {
+			return true
+		}
+	}
+	return false
+}
+
+// instantiate returns an instance of c for a single run in inv, as made by
+// newInstance. The instance's super is the instance of c.super in inv, if
+// any.
+func (c *Command) instantiate(inv *invocation) (*Command, error) {
+	inst, _, err := c.newInstance(inv.instances[c.super])
+	if err != nil {
+		return nil, err
+	}
+	if inv.instances == nil {
+		inv.instances = map[*Command]*Command{}
+	}
+	inv.instances[c] = inst
+	return inst, nil
+}
+
+// newInstance returns a copy of c for a single run, with a new flag set and
+// new copies of c.Struct and c's bundles that its flags and arguments are
+// bound to. The flags that the package adds, like -strict and -dry-run, get
+// new values too. Other flags and arguments, like those bound with a
+// ParamBuilder or added to the flag set directly, are shared with c;
+// newInstance returns their names. If super is not nil, it is the instance's
+// super.
+func (c *Command) newInstance(super *Command) (_ *Command, shared []string, _ error) {
+	inst := *c
+	inst.origin = c
+	if super != nil {
+		inst.super = super
+	}
+	initFlags(&inst)
+	inst.flagSpecs, inst.formals, inst.raw, inst.stdinField = nil, nil, nil, reflect.Value{}
+	inst.bundles = nil
+
+	// Copy the struct and the bundles, and process the copies.
+	var str reflect.Value // the copy of the Struct, if it is a pointer to a struct
+	if v := reflect.ValueOf(c.Struct); v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+		str = copyStruct(v)
+		inst.Struct = str.Interface()
+	}
+	var bundles []reflect.Value
+	for i, b := range c.bundles {
+		link := c.bundleLinks[i]
+		if link.err != nil {
+			return nil, nil, fmt.Errorf("command %q: %v", c.Name, link.err)
+		}
+		var nb reflect.Value
+		switch {
+		case link.index == nil:
+			nb = copyStruct(reflect.ValueOf(b))
+		case link.ptr:
+			nb = copyStruct(reflect.ValueOf(b))
+			str.Elem().FieldByIndex(link.index).Set(nb)
+		default:
+			nb = str.Elem().FieldByIndex(link.index).Addr()
+		}
+		bundles = append(bundles, nb)
+	}
+	if str.IsValid() {
+		if err := inst.processStruct(str.Elem(), ""); err != nil {
+			return nil, nil, fmt.Errorf("command %q, %v", c.Name, err)
+		}
+	}
+	for _, nb := range bundles {
+		if err := inst.processStruct(nb.Elem(), ""); err != nil {
+			return nil, nil, fmt.Errorf("command %q, bundle %T, %v", c.Name, nb.Interface(), err)
+		}
+		inst.bundles = append(inst.bundles, nb.Interface())
+	}
+
+	// Keep the order of c's flags and arguments, including those that aren't
+	// in the copies.
+	specs := map[string]*FlagSpec{}
+	for _, s := range inst.flagSpecs {
+		specs[s.Name] = s
+	}
+	inst.flagSpecs = nil
+	for _, s := range c.flagSpecs {
+		if n := specs[s.Name]; n != nil {
+			s = n
+		}
+		inst.flagSpecs = append(inst.flagSpecs, s)
+	}
+	formals := map[string]*formal{}
+	for _, f := range inst.formals {
+		formals[f.Name] = f
+	}
+	inst.formals = nil
+	for _, f := range c.formals {
+		if n := formals[f.Name]; n != nil {
+			f = n
+		} else {
+			shared = append(shared, f.Name)
+		}
+		inst.formals = append(inst.formals, f)
+	}
+	if inst.raw == nil && c.raw != nil {
+		inst.raw = c.raw
+		shared = append(shared, c.raw.Name)
+	}
+	if !inst.stdinField.IsValid() {
+		inst.stdinField = c.stdinField
+	}
+
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if inst.flags.Lookup(f.Name) != nil {
+			return
+		}
+		switch {
+		case stores(f.Value, &c.strict):
+			inst.flags.BoolVar(&inst.strict, f.Name, false, f.Usage)
+		case stores(f.Value, &c.showVersion):
+			inst.flags.BoolVar(&inst.showVersion, f.Name, false, f.Usage)
+		case c.noInput != nil && stores(f.Value, c.noInput):
+			inst.noInput = new(bool)
+			inst.flags.BoolVar(inst.noInput, f.Name, false, f.Usage)
+		case c.copyFlag != nil && stores(f.Value, c.copyFlag):
+			inst.copyFlag = new(bool)
+			inst.flags.BoolVar(inst.copyFlag, f.Name, false, f.Usage)
+		case c.dryRun != nil && stores(f.Value, c.dryRun):
+			inst.dryRun = new(bool)
+			inst.flags.BoolVar(inst.dryRun, f.Name, false, f.Usage)
+		case c.configFlag != nil && stores(f.Value, &c.configFlag.path):
+			inst.configFlag = &configFlag{}
+			inst.flags.StringVar(&inst.configFlag.path, f.Name, "", f.Usage)
+		default:
+			inst.flags.Var(f.Value, f.Name, f.Usage)
+			inst.flags.Lookup(f.Name).DefValue = f.DefValue
+			shared = append(shared, "-"+f.Name)
+		}
+	})
+	return &inst, shared, nil
+}
+
+// isolated reports whether instances of c and the commands below it share no
+// flags or arguments with them, so that runs on instances can't affect each
+// other.
+func (c *Command) isolated() bool {
+	if _, shared, err := c.newInstance(nil); err != nil || len(shared) > 0 {
+		return false
+	}
+	for _, s := range c.subs {
+		if !s.isolated() {
+			return false
+		}
+	}
+	return true
+}
+
+// stores reports whether the flag value v stores its value at p, as the
+// values made by methods like FlagSet.BoolVar do.
+func stores(v flag.Value, p interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.Pointer() == reflect.ValueOf(p).Pointer()
+}
+
+// copyStruct returns a pointer to a copy of the struct that p points to,
+// made with cloneValue.
+func copyStruct(p reflect.Value) reflect.Value {
+	n := reflect.New(p.Elem().Type())
+	n.Elem().Set(cloneValue(p.Elem()))
+	return n
+}
+
+// A bundleLink records where a command's Struct holds one of its bundles, so
+// that newInstance can make the copy of the Struct hold the copy of the
+// bundle.
+type bundleLink struct {
+	index []int // of the field in the Struct; nil if the Struct doesn't hold the bundle
+	ptr   bool  // the field points to the bundle, rather than being it
+	err   error // the field can't be set, because it or a struct holding it is unexported
+}
+
+// linkBundle returns the link from str, a command's Struct, to its bundle b.
+// It looks for b, or a pointer to it, in the fields of the struct that str
+// points to and the structs nested in them.
+func linkBundle(str, b reflect.Value) bundleLink {
+	if str.Kind() != reflect.Ptr || str.Elem().Kind() != reflect.Struct {
+		return bundleLink{}
+	}
+	var link bundleLink
+	var find func(v reflect.Value, index []int, exported bool) bool
+	find = func(v reflect.Value, index []int, exported bool) bool {
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			f := v.Field(i)
+			fi := append(index[:len(index):len(index)], i)
+			switch {
+			case f.Type() == b.Type() && f.Pointer() == b.Pointer():
+				link = bundleLink{index: fi, ptr: true}
+			case f.Type() == b.Type().Elem() && f.Addr().Pointer() == b.Pointer():
+				link = bundleLink{index: fi}
+			case f.Kind() == reflect.Struct:
+				if find(f, fi, exported && sf.IsExported()) {
+					return true
+				}
+				continue
+			default:
+				continue
+			}
+			if !exported || !sf.IsExported() {
+				link.err = fmt.Errorf("bundle %T is held in unexported field %s, so it can't be copied for a run; export the field and tag it `cli:\"-\"`", b.Interface(), sf.Name)
+			}
+			return true
+		}
+		return false
+	}
+	find(str.Elem(), nil, true)
+	return link
+}
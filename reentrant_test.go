@@ -0,0 +1,203 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type greeting struct {
+	Loud   bool              `cli:"flag=loud, shout"`
+	Extras map[string]string `cli:"flag=extra, extra words"`
+	Names  []string          `cli:"name=NAME, opt=, who to greet"`
+	prefix string            // set at registration
+}
+
+func (g *greeting) Run(ctx context.Context) error {
+	s := fmt.Sprintf("%s %s %v", g.prefix, strings.Join(g.Names, ","), g.Extras)
+	if g.Loud {
+		s = strings.ToUpper(s)
+	}
+	fmt.Fprintln(Stdout(ctx), s)
+	return nil
+}
+
+type cautious struct{}
+
+func (*cautious) Run(ctx context.Context) error {
+	Warnf(ctx, "careful")
+	return nil
+}
+
+func TestReentrant(t *testing.T) {
+	top := &Command{Name: "prog", Reentrant: true}
+	initFlags(top)
+	top.AddStrictFlag()
+	g := &greeting{prefix: "hi", Extras: map[string]string{"a": "1"}}
+	top.Command("greet", g, "")
+	top.Command("warn", &cautious{}, "")
+
+	run := func(args ...string) string {
+		t.Helper()
+		var out strings.Builder
+		ctx := withInvocation(context.Background(), &invocation{stdout: &out, stderr: &out})
+		if err := top.Run(ctx, args); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return strings.TrimSpace(out.String())
+	}
+
+	if got, want := run("greet", "-loud", "-extra", "b=2", "pat", "kim"), "HI PAT,KIM MAP[B:2]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := run("greet"), "hi  map[a:1]"; got != want {
+		t.Errorf("second run: got %q, want %q", got, want)
+	}
+	// The registered struct is untouched.
+	if g.Loud || g.Names != nil || len(g.Extras) != 1 {
+		t.Errorf("registered struct changed: %+v", g)
+	}
+
+	// Global flags still work.
+	err := top.Run(context.Background(), []string{"-strict", "warn"})
+	if err == nil || !strings.Contains(err.Error(), "treated as errors") {
+		t.Errorf("-strict: got %v", err)
+	}
+
+	// Concurrent runs don't interfere.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out strings.Builder
+			ctx := withInvocation(context.Background(), &invocation{stdout: &out, stderr: &out})
+			name := fmt.Sprint("n", i)
+			if err := top.Run(ctx, []string{"greet", name}); err != nil {
+				t.Error(err)
+				return
+			}
+			if got, want := strings.TrimSpace(out.String()), "hi "+name+" map[a:1]"; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestReentrantLoad(t *testing.T) {
+	var got []string
+	actions := map[string]Action{
+		"show": func(ctx context.Context, values map[string]interface{}) error {
+			got = append(got, fmt.Sprint(values["n"]))
+			return nil
+		},
+	}
+	top := &Command{Name: "prog", Reentrant: true}
+	initFlags(top)
+	defs := `[{"name": "show", "action": "show", "fields": [{"name": "n", "type": "int", "flag": true}]}]`
+	if err := top.Load(strings.NewReader(defs), actions); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"show", "-n", "3"}, {"show"}} {
+		if err := top.Run(context.Background(), args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g, want := strings.Join(got, " "), "3 0"; g != want {
+		t.Errorf("got %q, want %q", g, want)
+	}
+}
+
+type filteredList struct {
+	Opts *FilterOptions `cli:"-"`
+}
+
+func (l *filteredList) Run(ctx context.Context) error {
+	fmt.Fprintln(Stdout(ctx), l.Opts.Filter.String(), DryRun(ctx))
+	return nil
+}
+
+// A pagedList holds its bundle in a field, rather than pointing to it.
+type pagedList struct {
+	Page PageOptions `cli:"-"`
+}
+
+func (l *pagedList) Run(ctx context.Context) error {
+	fmt.Fprintln(Stdout(ctx), l.Page.Limit)
+	return nil
+}
+
+type hiddenBundle struct {
+	opts *FilterOptions
+}
+
+func (*hiddenBundle) Run(context.Context) error { return nil }
+
+func TestReentrantBundles(t *testing.T) {
+	top := &Command{Name: "prog", Reentrant: true}
+	initFlags(top)
+	top.AddDryRunFlag()
+	l := &filteredList{Opts: &FilterOptions{}}
+	top.Command("list", l, "", l.Opts)
+	if !top.isolated() {
+		t.Error("top is not isolated")
+	}
+
+	// Concurrent runs get their own bundles and global flags.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out strings.Builder
+			ctx := withInvocation(context.Background(), &invocation{stdout: &out, stderr: &out})
+			args := []string{"list", "-filter", fmt.Sprint("n=", i)}
+			if i%2 == 0 {
+				args = append([]string{"-dry-run"}, args...)
+			}
+			if err := top.Run(ctx, args); err != nil {
+				t.Error(err)
+				return
+			}
+			if got, want := strings.TrimSpace(out.String()), fmt.Sprint("n=", i, " ", i%2 == 0); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(l.Opts.Filter) != 0 || *top.dryRun {
+		t.Errorf("registered values changed: %v, %t", l.Opts.Filter, *top.dryRun)
+	}
+
+	// A flag added to the flag set directly is shared.
+	top.flags.Bool("quiet", false, "")
+	if top.isolated() {
+		t.Error("top with a shared flag is isolated")
+	}
+
+	// A bundle stored in the Struct is the one in the copy.
+	p := &pagedList{}
+	top.Command("pages", p, "", &p.Page)
+	var out strings.Builder
+	ctx := withInvocation(context.Background(), &invocation{stdout: &out, stderr: &out})
+	if err := top.Run(ctx, []string{"pages", "-limit", "7"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "7" || p.Page.Limit != 0 {
+		t.Errorf("got %q and registered limit %d, want 7 and 0", got, p.Page.Limit)
+	}
+
+	// A bundle in an unexported field can't be copied.
+	h := &hiddenBundle{opts: &FilterOptions{}}
+	top.Command("hidden", h, "", h.opts)
+	if err := top.Run(ctx, []string{"hidden"}); err == nil || !strings.Contains(err.Error(), "unexported field opts") {
+		t.Errorf("unexported: got %v, want error about the field", err)
+	}
+}
@@ -3,6 +3,7 @@
 package cli
 
 import (
+	"encoding"
 	"errors"
 	"flag"
 	"fmt"
@@ -27,20 +28,20 @@ func Top(c *Command) *Command {
 	}
 	c.flags = flag.CommandLine
 	flag.Usage = func() {
-		c.usage(c.flags.Output(), true)
+		c.usage(c.flags.Output())
 	}
 	c.processFields()
 	return c
 }
 
 // Command constructs a Command with the Name, Struct and Usage fields populated,
-// then calls Register.
-func (c *Command) Command(name string, str interface{}, usage string) *Command {
+// then calls Register with it and bundles.
+func (c *Command) Command(name string, str interface{}, usage string, bundles ...interface{}) *Command {
 	return c.Register(&Command{
 		Name:   name,
 		Struct: str,
 		Usage:  usage,
-	})
+	}, bundles...)
 }
 
 // Register registers a sub-command of the receiver Command.
@@ -49,37 +50,73 @@ func (c *Command) Command(name string, str interface{}, usage string) *Command {
 // group of commands, not a command proper. In that case, it cannot have any
 // positional arguments (though it may have flags), and it must have
 // sub-commands.
-func (c *Command) Register(sub *Command) *Command {
-	if err := c.register(sub); err != nil {
+//
+// Each of bundles, if any, must be a pointer to a struct. Its fields define
+// additional flags and arguments of sub, after those of sub.Struct, just as if
+// they were fields of sub.Struct. Bundles let a set of options, like those for
+// authentication or output formatting, be shared by some commands without
+// embedding a struct in each.
+func (c *Command) Register(sub *Command, bundles ...interface{}) *Command {
+	if err := c.register(sub, bundles...); err != nil {
 		panic(err)
 	}
 	return sub
 }
 
-func (c *Command) register(sub *Command) error {
+func (c *Command) register(sub *Command, bundles ...interface{}) error {
 	if sub.Name == "" {
 		return fmt.Errorf("sub-command of %s has no name", c.Name)
 	}
 	initFlags(sub)
-	if c.findSub(sub.Name) != nil {
+	// A sub-command that the package added, like SetVersion's "version", gives
+	// way to one the program registers.
+	old := c.findSub(sub.Name)
+	if old != nil && !old.implicit {
 		return fmt.Errorf("duplicate sub-command: %q", sub.Name)
 	}
-	if err := sub.processFields(); err != nil {
+	// Set super first, so the sub-command's tags can refer to choices defined
+	// above it.
+	sub.super = c
+	if err := sub.processFields(bundles...); err != nil {
+		sub.super = nil
 		return err
 	}
-	if _, ok := sub.Struct.(Runnable); !ok && len(c.formals) > 0 {
+	if _, ok := sub.runnable(); !ok && len(c.formals) > 0 {
+		sub.super = nil
 		return fmt.Errorf("sub-command %s of %s has positional arguments but is not runnable",
 			sub.Name, c.Name)
 	}
+	if c.raw != nil {
+		sub.super = nil
+		return fmt.Errorf("%s has a 'raw' field, so it cannot have sub-commands", c.Name)
+	}
+	if old != nil {
+		for i, s := range c.subs {
+			if s == old {
+				c.subs[i] = sub
+				return nil
+			}
+		}
+	}
 	c.subs = append(c.subs, sub)
-	sub.super = c
 	return nil
 }
 
+// AddStrictFlag adds a boolean flag named "strict" to c.
+// When it is set, warnings issued with Warnf cause the invocation to fail
+// after the command has run, even if the command succeeds.
+// It is typically called on the top-level command, so that the flag applies
+// to all commands. It is meant for CI pipelines and other places where drift
+// should be caught early.
+func (c *Command) AddStrictFlag() {
+	c.flags.BoolVar(&c.strict, "strict", false, "treat warnings as errors")
+}
+
 func initFlags(c *Command) *Command {
 	c.flags = flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	c.parsed = nil
 	c.flags.Usage = func() {
-		c.usage(c.flags.Output(), true)
+		c.usage(c.flags.Output())
 	}
 	return c
 }
@@ -93,15 +130,98 @@ func (c *Command) findSub(name string) *Command {
 	return nil
 }
 
-func (c *Command) processFields() error {
-	if c.Struct == nil {
+// matchSub returns the sub-command of c whose name has the given prefix, if
+// prefix matching is enabled. It returns an error if more than one name has
+// the prefix, and nil, nil if none does. If c has positional arguments, an
+// ambiguous prefix may be one of them, so matchSub returns nil, nil for it
+// instead of an error.
+func (c *Command) matchSub(prefix string) (*Command, error) {
+	if !c.prefixMatch() || prefix == "" {
+		return nil, nil
+	}
+	var matches []*Command
+	for _, s := range c.subs {
+		if strings.HasPrefix(s.Name, prefix) {
+			matches = append(matches, s)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return matches[0], nil
+	default:
+		if len(c.formals) > 0 {
+			return nil, nil
+		}
+		var names []string
+		for _, m := range matches {
+			names = append(names, m.Name)
+		}
+		return nil, fmt.Errorf("ambiguous command %q: could be %s", prefix, strings.Join(names, ", "))
+	}
+}
+
+// processFields defines flags and arguments for the fields of c.Struct, then
+// for those of each of bundles.
+func (c *Command) processFields(bundles ...interface{}) error {
+	if c.Struct == nil && len(bundles) == 0 {
 		return nil
 	}
-	v := reflect.ValueOf(c.Struct)
-	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
-		return fmt.Errorf("%s.Struct: %T is not a pointer to a struct", c.Name, c.Struct)
+	if c.Struct != nil {
+		v := reflect.ValueOf(c.Struct)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("%s.Struct: %T is not a pointer to a struct", c.Name, c.Struct)
+		}
+		if err := c.processStruct(v.Elem(), ""); err != nil {
+			return fmt.Errorf("command %q, %v", c.Name, err)
+		}
+	}
+	for _, b := range bundles {
+		v := reflect.ValueOf(b)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("%s: option bundle %T is not a pointer to a struct", c.Name, b)
+		}
+		if err := c.processStruct(v.Elem(), ""); err != nil {
+			return fmt.Errorf("command %q, bundle %T, %v", c.Name, b, err)
+		}
+		c.bundles = append(c.bundles, b)
+		c.bundleLinks = append(c.bundleLinks, linkBundle(reflect.ValueOf(c.Struct), v))
+	}
+	return c.checkFields()
+}
+
+// addBundle adds the flags of b, a pointer to a struct, to c, and makes b one
+// of c's bundles. It is for the bundles that this package provides, like
+// TimeoutOptions, so it panics on error.
+func (c *Command) addBundle(b interface{}) {
+	if err := c.processStruct(reflect.ValueOf(b).Elem(), ""); err != nil {
+		panic(err)
+	}
+	c.bundles = append(c.bundles, b)
+	c.bundleLinks = append(c.bundleLinks, bundleLink{})
+}
+
+// checkFields checks constraints that involve more than one flag or argument.
+func (c *Command) checkFields() error {
+	for i, f := range c.formals {
+		if f.Variadic && i != len(c.formals)-1 {
+			return fmt.Errorf("%q is a slice but not the last arg", f.Name)
+		}
+	}
+	for _, f := range c.flagSpecs {
+		for _, r := range f.Requires {
+			if c.flags.Lookup(r) == nil {
+				return fmt.Errorf("command %q: flag -%s requires unknown flag -%s", c.Name, f.Name, r)
+			}
+		}
 	}
-	v = v.Elem()
+	return nil
+}
+
+// processStruct processes the fields of the struct v.
+// The names of flags are prefixed with prefix.
+func (c *Command) processStruct(v reflect.Value, prefix string) error {
 	t := v.Type()
 	for i := 0; i < t.NumField(); i++ {
 		f := t.Field(i)
@@ -111,25 +231,38 @@ func (c *Command) processFields() error {
 			// for convenience.
 			tag = string(f.Tag)
 		}
-		if err := c.parseTag(tag, f, v.Field(i)); err != nil {
-			return fmt.Errorf("command %q, field %q: %v", c.Name, f.Name, err)
-		}
-	}
-	for i, f := range c.formals {
-		if f.min >= 0 && i != len(c.formals)-1 {
-			return fmt.Errorf("%q is a slice but not the last arg", f.name)
+		if err := c.parseTag(tag, f, v.Field(i), prefix); err != nil {
+			return fmt.Errorf("field %q: %v", f.Name, err)
 		}
 	}
 	return nil
 }
 
 var validKeys = map[string]bool{
-	"flag":  true,
-	"name":  true,
-	"min":   true,
-	"oneof": true,
-	"doc":   true,
-	"opt":   true,
+	"flag":       true,
+	"name":       true,
+	"min":        true,
+	"oneof":      true,
+	"doc":        true,
+	"opt":        true,
+	"stdin":      true,
+	"prefix":     true,
+	"xor":        true,
+	"requires":   true,
+	"count":      true,
+	"deprecated": true,
+	"minval":     true,
+	"maxval":     true,
+	"match":      true,
+	"matchmsg":   true,
+	"normalize":  true,
+	"type":       true,
+	"raw":        true,
+	"sep":        true,
+	"accumulate": true,
+	"env":        true,
+	"config":     true,
+	"secret":     true,
 }
 
 // A tag representing an argument is most simply
@@ -140,14 +273,28 @@ var validKeys = map[string]bool{
 // - oneof=a|b|c, which which validate that the arg is one of those strings.
 // A full example:
 //   Env `cli:"name=env, oneof=dev|prod, development environment"`
-func (c *Command) parseTag(tag string, sf reflect.StructField, field reflect.Value) error {
+//
+// If the field is a flag, its name is prefixed with prefix.
+func (c *Command) parseTag(tag string, sf reflect.StructField, field reflect.Value, prefix string) error {
+	if tag == "-" {
+		return nil
+	}
 	if tag != "" && !sf.IsExported() {
 		return errors.New("cli tag on unexported field")
 	}
 	if !sf.IsExported() {
 		return nil
 	}
-	tagMap := tagToMap(tag)
+	tagMap, err := tagToMap(tag)
+	if err != nil {
+		return err
+	}
+	return c.processTagMap(tagMap, sf, field, prefix)
+}
+
+// processTagMap defines a flag or argument for field, as described by
+// tagMap.
+func (c *Command) processTagMap(tagMap map[string]string, sf reflect.StructField, field reflect.Value, prefix string) error {
 	for k := range tagMap {
 		if k == "" {
 			return errors.New("empty key")
@@ -163,9 +310,27 @@ func (c *Command) parseTag(tag string, sf reflect.StructField, field reflect.Val
 	if _, isOpt := tagMap["opt"]; isOpt && isFlag {
 		return errors.New("either 'flag' or 'opt', but not both")
 	}
+	if p, ok := tagMap["prefix"]; ok {
+		// A struct of flags.
+		for k := range tagMap {
+			if k != "prefix" && k != "doc" {
+				return fmt.Errorf("'prefix' cannot be combined with %q", k)
+			}
+		}
+		if field.Kind() != reflect.Struct {
+			return errors.New("'prefix' requires a struct field")
+		}
+		return c.processStruct(field, prefix+p)
+	}
+	if format, ok := tagMap["stdin"]; ok {
+		return c.setStdinField(format, tagMap, field)
+	}
+	if v, ok := tagMap["raw"]; ok {
+		return c.setRawField(v, tagMap, sf, field)
+	}
 
 	// Check and prepare oneof.
-	choices, err := prepareOneof(tagMap)
+	choices, err := c.prepareOneof(tagMap)
 	if err != nil {
 		return err
 	}
@@ -173,43 +338,293 @@ func (c *Command) parseTag(tag string, sf reflect.StructField, field reflect.Val
 	if choices != nil {
 		usage += "; one of " + strings.Join(choices, ", ")
 	}
-	parser, err := buildParser(field.Type(), choices, isFlag)
+	var requires []string
+	if r, ok := tagMap["requires"]; ok {
+		if !isFlag {
+			return errors.New("'requires' is only for flags")
+		}
+		for _, n := range strings.Split(r, "|") {
+			n = strings.TrimLeft(strings.TrimSpace(n), "-")
+			if n == "" {
+				return errors.New("requires: empty flag name")
+			}
+			requires = append(requires, n)
+		}
+		usage += " (requires -" + strings.Join(requires, ", -") + ")"
+	}
+	deprecated, ok := tagMap["deprecated"]
+	if ok {
+		if !isFlag {
+			return errors.New("'deprecated' is only for flags")
+		}
+		if deprecated == "" {
+			return errors.New("deprecated: empty message")
+		}
+		usage += " (deprecated: " + deprecated + ")"
+	}
+	env, hasEnv := tagMap["env"]
+	configKey, hasConfig := tagMap["config"]
+	if (hasEnv || hasConfig) && !isFlag {
+		return errors.New("'env' and 'config' are only for flags")
+	}
+	if hasEnv {
+		if env == "" {
+			return errors.New("env: empty variable name")
+		}
+		usage += " (env $" + env + ")"
+	}
+	if hasConfig && configKey == "" {
+		return errors.New("config: empty key")
+	}
+	if _, ok := tagMap["count"]; ok && (hasEnv || hasConfig) {
+		return errors.New("'env' and 'config' are not supported for counts")
+	}
+	var norm normalizer
+	if spec, ok := tagMap["normalize"]; ok {
+		norm, err = buildNormalizer(spec)
+		if err != nil {
+			return err
+		}
+	}
+	sep, hasSep := tagMap["sep"]
+	if hasSep {
+		if sep == "" {
+			return errors.New("sep: empty separator")
+		}
+		isList := field.Kind() == reflect.Map || (isFlag && field.Kind() == reflect.Slice)
+		if !isList || hasParseMethod(field.Type()) {
+			return errors.New("'sep' is only for slice flags and maps")
+		}
+	}
+	accumulate := false
+	if v, ok := tagMap["accumulate"]; ok {
+		if v != "" {
+			return errors.New(`"accumulate" should not have a value`)
+		}
+		if !isFlag || field.Kind() != reflect.Slice || hasParseMethod(field.Type()) {
+			return errors.New("'accumulate' is only for slice flags")
+		}
+		accumulate = true
+	}
+	secret := false
+	if v, ok := tagMap["secret"]; ok {
+		if v != "" {
+			return errors.New(`"secret" should not have a value`)
+		}
+		if !isFlag {
+			return errors.New("'secret' is only for flags")
+		}
+		if _, ok := tagMap["count"]; ok {
+			return errors.New("'secret' is not supported for counts")
+		}
+		if choices != nil {
+			return errors.New("oneof not allowed for a secret")
+		}
+		secret = true
+	}
+	parser, err := buildParser(field.Type(), choices, norm, isFlag, sep)
 	if err != nil {
 		return err
 	}
+	minval, hasMin := tagMap["minval"]
+	maxval, hasMax := tagMap["maxval"]
+	if hasMin || hasMax {
+		if _, ok := tagMap["count"]; ok {
+			return errors.New("minval and maxval are not supported for counts")
+		}
+		var desc string
+		parser, desc, err = rangeParser(parser, field.Type(), minval, maxval)
+		if err != nil {
+			return err
+		}
+		usage += "; " + desc
+	}
+	pathType, hasPathType := tagMap["type"]
+	if hasPathType {
+		var desc string
+		parser, desc, err = pathParser(parser, field.Type(), pathType)
+		if err != nil {
+			return err
+		}
+		usage += "; " + desc
+	}
+	if expr, ok := tagMap["match"]; ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("match: %v", err)
+		}
+		parser, err = matchParser(parser, field.Type(), re, tagMap["matchmsg"])
+		if err != nil {
+			return err
+		}
+	} else if _, ok := tagMap["matchmsg"]; ok {
+		return errors.New("'matchmsg' without 'match'")
+	}
 	if fname, ok := tagMap["flag"]; ok {
 		// flag
-		if fname == "" {
-			fname = strings.ToLower(sf.Name)
+		// The first name is the flag's name; any others are aliases.
+		names := strings.Split(fname, "|")
+		for i, n := range names {
+			names[i] = strings.TrimLeft(strings.TrimSpace(n), "-")
 		}
-		if fname[0] == '-' {
-			fname = fname[1:]
+		if names[0] == "" {
+			names[0] = strings.ToLower(sf.Name)
+		}
+		for i, n := range names {
+			if n == "" {
+				return errors.New("empty flag alias")
+			}
+			names[i] = prefix + n
+		}
+		fname = names[0]
+		var aliases []string
+		if len(names) > 1 {
+			aliases = names[1:]
+		}
+		if err := c.checkFlagName(fname); err != nil {
+			return err
+		}
+		def := ""
+		if !field.IsZero() {
+			def = formatDefault(field, choices != nil)
+			if secret {
+				def = hiddenValue
+			}
 		}
-		if field.Kind() == reflect.Bool {
+		if v, ok := tagMap["count"]; ok {
+			if v != "" {
+				return errors.New(`"count" should not have a value`)
+			}
+			switch field.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			default:
+				return errors.New("'count' requires an integer field")
+			}
+			if choices != nil {
+				return errors.New("oneof not allowed for a count")
+			}
+			c.flags.Var(&countValue{field: field, def: field.Int(), cmd: c, name: fname}, fname, usage)
+		} else if isFlagValue(field.Type()) {
+			if choices != nil {
+				return errors.New("oneof not allowed for a flag.Value")
+			}
+			v := field.Addr().Interface().(flag.Value)
+			if f, ok := v.(*Filter); ok {
+				v = &filterFlag{f, c, fname}
+			}
+			c.flags.Var(v, fname, usage)
+		} else if field.Kind() == reflect.Bool {
 			ptr := field.Addr().Convert(reflect.PtrTo(reflect.TypeOf(true))).Interface().(*bool)
 			c.flags.BoolVar(ptr, fname, *ptr, usage)
 		} else {
-			if field.Kind() == reflect.Slice {
-				usage = usage + "comma-separated list of " + usage
+			if field.Kind() == reflect.Slice && !hasParseMethod(field.Type()) {
+				sepDesc := "comma"
+				if hasSep {
+					sepDesc = strconv.Quote(sep)
+				}
+				usage = sepDesc + "-separated list of " + usage
+				if accumulate {
+					usage += "; can be repeated"
+				}
+			}
+			if field.Kind() == reflect.Map {
+				sepDesc := "comma"
+				if hasSep {
+					sepDesc = strconv.Quote(sep)
+				}
+				usage += "; " + sepDesc + "-separated key=value pairs; can be repeated"
 			}
-			if !field.IsZero() {
-				usage += fmt.Sprintf(" (default %s)", formatDefault(field, choices != nil))
+			if def != "" {
+				usage += " (default " + def + ")"
 			}
 			if choices != nil && field.Kind() != reflect.Slice {
-				c.flags.Var(&oneof{choices: choices}, fname, usage)
+				c.flags.Var(&oneof{choices: choices, field: field, parser: parser}, fname, usage)
+				// The default is already in the usage string.
+				c.flags.Lookup(fname).DefValue = ""
 			} else {
 				c.flags.Func(fname, usage, func(s string) error {
 					val, err := parser(s)
 					if err != nil {
 						return err
 					}
+					// The flag set doesn't record this occurrence until
+					// Func returns, so Changed reports earlier ones.
+					if accumulate && c.Changed(fname) {
+						// Append to the values of earlier occurrences,
+						// but replace the default.
+						field.Set(reflect.AppendSlice(field, reflect.ValueOf(val)))
+						return nil
+					}
+					if field.Kind() == reflect.Map && c.Changed(fname) {
+						// Add to the map of earlier occurrences. The first
+						// occurrence replaced the default or configured map,
+						// so the struct doesn't share this one.
+						iter := reflect.ValueOf(val).MapRange()
+						for iter.Next() {
+							field.SetMapIndex(iter.Key(), iter.Value())
+						}
+						return nil
+					}
 					field.Set(reflect.ValueOf(val))
 					return nil
 				})
 			}
 		}
+		spec := &FlagSpec{
+			Name:       fname,
+			Aliases:    aliases,
+			Usage:      usage,
+			Type:       field.Type().String(),
+			Choices:    choices,
+			Requires:   requires,
+			Deprecated: deprecated,
+			PathType:   pathType,
+			Env:        env,
+			ConfigKey:  configKey,
+			Secret:     secret,
+			field:      sf.Name,
+			value:      field,
+			sep:        sep,
+		}
+		if _, ok := tagMap["count"]; ok {
+			spec.Count = true
+		} else {
+			spec.Default = def
+		}
+		if g, ok := tagMap["xor"]; ok {
+			if g == "" {
+				return errors.New("xor: empty group name")
+			}
+			spec.Exclusive = g
+		}
+		if secret {
+			f := c.flags.Lookup(fname)
+			f.Value = &secretValue{Value: f.Value, name: fname}
+			// A DefValue of "" shows no default; flags defined with Func
+			// already have one, and their default is in the usage.
+			if f.DefValue != "" {
+				f.DefValue = def
+			}
+		}
+		for _, a := range aliases {
+			if err := c.checkFlagName(a); err != nil {
+				return err
+			}
+			c.flags.Var(c.flags.Lookup(fname).Value, a, usage)
+			c.flags.Lookup(a).DefValue = c.flags.Lookup(fname).DefValue
+		}
+		c.flagSpecs = append(c.flagSpecs, spec)
 	} else {
 		// positional arg
+		if _, ok := tagMap["xor"]; ok {
+			return errors.New("'xor' is only for flags")
+		}
+		if _, ok := tagMap["count"]; ok {
+			return errors.New("'count' is only for flags")
+		}
+		if prefix != "" {
+			return errors.New("positional argument in a struct with a prefix")
+		}
 		name := tagMap["name"]
 		if name == "" {
 			name = strings.ToUpper(sf.Name)
@@ -219,16 +634,21 @@ func (c *Command) parseTag(tag string, sf reflect.StructField, field reflect.Val
 			return errors.New(`"opt" should not have a value`)
 		}
 		f := &formal{
-			name:   name,
-			field:  field,
-			usage:  usage,
-			min:    -1,
-			opt:    opt,
-			parser: parser,
+			ArgSpec: ArgSpec{
+				Name:     name,
+				Usage:    usage,
+				Type:     field.Type().String(),
+				Optional: opt,
+				Choices:  choices,
+				PathType: pathType,
+			},
+			field:     field,
+			fieldName: sf.Name,
+			parser:    parser,
 		}
 		minTag, hasMinTag := tagMap["min"]
-		if sf.Type.Kind() == reflect.Slice {
-			f.min = 0
+		if sf.Type.Kind() == reflect.Slice && !hasParseMethod(sf.Type) {
+			f.Variadic = true
 			if hasMinTag {
 				min, err := strconv.Atoi(minTag)
 				if err != nil {
@@ -237,29 +657,147 @@ func (c *Command) parseTag(tag string, sf reflect.StructField, field reflect.Val
 				if min < 0 {
 					return errors.New("min cannot be negative")
 				}
-				f.min = min
+				f.Min = min
 			}
 		} else if hasMinTag {
 			return errors.New("min is only for slice args")
 		}
+		f.flagsBefore = len(c.flagSpecs)
 		c.formals = append(c.formals, f)
 	}
 	return nil
 }
 
+// checkFlagName returns an error if name is the same as the name of an
+// existing flag of c, ignoring case. The flag package would panic on an exact
+// duplicate, and names differing only in case are confusing.
+func (c *Command) checkFlagName(name string) error {
+	var err error
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if err != nil || !strings.EqualFold(f.Name, name) {
+			return
+		}
+		if fs := c.flagSpec(f.Name); fs != nil {
+			err = fmt.Errorf("flag -%s conflicts with flag -%s of field %q", name, f.Name, fs.field)
+		} else {
+			err = fmt.Errorf("flag -%s conflicts with existing flag -%s", name, f.Name)
+		}
+	})
+	return err
+}
+
+// setStdinField records field as the one to be populated from standard input.
+func (c *Command) setStdinField(format string, tagMap map[string]string, field reflect.Value) error {
+	if format != "json" {
+		return fmt.Errorf("stdin: unknown format %q", format)
+	}
+	for k := range tagMap {
+		if k != "stdin" && k != "doc" {
+			return fmt.Errorf("'stdin' cannot be combined with %q", k)
+		}
+	}
+	if c.stdinField.IsValid() {
+		return errors.New("more than one 'stdin' field")
+	}
+	c.stdinField = field
+	return nil
+}
+
+func (c *Command) setRawField(v string, tagMap map[string]string, sf reflect.StructField, field reflect.Value) error {
+	if v != "" {
+		return errors.New(`"raw" should not have a value`)
+	}
+	for k := range tagMap {
+		if k != "raw" && k != "name" && k != "doc" {
+			return fmt.Errorf("'raw' cannot be combined with %q", k)
+		}
+	}
+	if field.Type() != reflect.TypeOf([]string(nil)) {
+		return errors.New("'raw' requires a []string field")
+	}
+	if c.raw != nil {
+		return errors.New("more than one 'raw' field")
+	}
+	if len(c.subs) > 0 {
+		return errors.New("'raw' field in a command with sub-commands")
+	}
+	name := tagMap["name"]
+	if name == "" {
+		name = strings.ToUpper(sf.Name)
+	}
+	c.raw = &formal{
+		ArgSpec: ArgSpec{
+			Name:     name,
+			Usage:    tagMap["doc"],
+			Type:     field.Type().String(),
+			Optional: true,
+			Variadic: true,
+		},
+		field:     field,
+		fieldName: sf.Name,
+	}
+	return nil
+}
+
 var keyRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]+=`)
 
-func tagToMap(tag string) map[string]string {
+// bareKeys are the keys that take no value, so they can be written without
+// the "=".
+var bareKeys = map[string]bool{
+	"count":      true,
+	"accumulate": true,
+	"secret":     true,
+	"raw":        true,
+}
+
+// tagToMap parses a tag into a map from keys to values. Text at the end of the
+// tag that doesn't start with a key is the value of "doc". A key in bareKeys
+// can appear without "=", as in "flag=token, secret, API token".
+//
+// A value, or the final doc, can be enclosed in single quotes so that it can
+// contain commas, leading or trailing space, or text that looks like a key.
+// Within quotes, two single quotes stand for one.
+func tagToMap(tag string) (map[string]string, error) {
 	m := map[string]string{}
 	tag = strings.TrimSpace(tag)
 	for len(tag) > 0 {
+		if tag[0] == '\'' {
+			doc, rest, err := unquoteTagValue(tag)
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(rest) != "" {
+				return nil, fmt.Errorf("unexpected text after quoted doc: %q", rest)
+			}
+			m["doc"] = doc
+			break
+		}
 		loc := keyRegexp.FindStringIndex(tag)
 		if loc == nil {
+			word, rest, _ := stringsCut(tag, ",")
+			if word = strings.TrimSpace(word); bareKeys[word] {
+				m[word] = ""
+				tag = strings.TrimSpace(rest)
+				continue
+			}
 			m["doc"] = tag
 			break
 		}
 		key := tag[:loc[1]-1]
-		tag = tag[loc[1]:]
+		tag = strings.TrimSpace(tag[loc[1]:])
+		if strings.HasPrefix(tag, "'") {
+			value, rest, err := unquoteTagValue(tag)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			rest = strings.TrimSpace(rest)
+			if rest != "" && rest[0] != ',' {
+				return nil, fmt.Errorf("%s: unexpected text after quoted value: %q", key, rest)
+			}
+			m[key] = value
+			tag = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+			continue
+		}
 		before, after, found := stringsCut(tag, ",")
 		var value string
 		if !found {
@@ -271,10 +809,53 @@ func tagToMap(tag string) map[string]string {
 		}
 		m[key] = strings.TrimSpace(value)
 	}
-	return m
+	return m, nil
+}
+
+// unquoteTagValue parses the single-quoted string at the start of s, and
+// returns its contents and the rest of s.
+func unquoteTagValue(s string) (value, rest string, err error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != '\'' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '\'' {
+			b.WriteByte('\'')
+			i++
+			continue
+		}
+		return b.String(), s[i+1:], nil
+	}
+	return "", "", errors.New("unterminated quoted value")
+}
+
+// DefineChoices defines a named set of choices that the struct tags of c and
+// its sub-commands can refer to with "oneof=@name", by adding it to c.Choices.
+// It must be called before those commands are registered.
+func (c *Command) DefineChoices(name string, choices ...string) {
+	if len(choices) == 0 {
+		panic(fmt.Sprintf("DefineChoices(%q): no choices", name))
+	}
+	if c.Choices == nil {
+		c.Choices = map[string][]string{}
+	}
+	c.Choices[name] = choices
+}
+
+// lookupChoices returns the choices defined with name on c or a command above
+// it, or nil if there are none.
+func (c *Command) lookupChoices(name string) []string {
+	for ; c != nil; c = c.super {
+		if cs, ok := c.Choices[name]; ok {
+			return cs
+		}
+	}
+	return nil
 }
 
-func prepareOneof(tagMap map[string]string) ([]string, error) {
+func (c *Command) prepareOneof(tagMap map[string]string) ([]string, error) {
 	oneof, ok := tagMap["oneof"]
 	if !ok {
 		return nil, nil
@@ -282,6 +863,13 @@ func prepareOneof(tagMap map[string]string) ([]string, error) {
 	if strings.TrimSpace(oneof) == "" {
 		return nil, errors.New("oneof value cannot be empty")
 	}
+	if name := strings.TrimPrefix(oneof, "@"); name != oneof {
+		choices := c.lookupChoices(name)
+		if choices == nil {
+			return nil, fmt.Errorf("oneof: no choices named %q", name)
+		}
+		return choices, nil
+	}
 	choices := strings.Split(oneof, "|")
 	for i := range choices {
 		choices[i] = strings.TrimSpace(choices[i])
@@ -289,30 +877,46 @@ func prepareOneof(tagMap map[string]string) ([]string, error) {
 	return choices, nil
 }
 
+// formatDefault returns the default value v of a flag as it appears in help
+// and in the FlagSpec. A type with a MarshalText method, like net.IP, is
+// written with it.
 func formatDefault(v reflect.Value, isOneof bool) string {
 	if v.Kind() == reflect.String && !isOneof {
 		return strconv.Quote(v.String())
 	}
-	return v.String()
+	if v.CanAddr() {
+		v = v.Addr() // so a MarshalText method with a pointer receiver is found
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if b, err := m.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(reflect.Indirect(v).Interface())
 }
 
 // oneof implements flag.Value and github.com/posener/complete/v2.Predictor.
 type oneof struct {
 	choices []string
-	value   string
+	field   reflect.Value // a string
+	parser  parseFunc     // checks the value against choices
 }
 
 // String implements flag.Value
 func (f *oneof) String() string {
-	return f.value
+	if !f.field.IsValid() {
+		return ""
+	}
+	return f.field.String()
 }
 
 // Set implements flag.Value
 func (f *oneof) Set(s string) error {
-	if err := checkOneof(s, f.choices); err != nil {
+	v, err := f.parser(s)
+	if err != nil {
 		return err
 	}
-	f.value = s
+	f.field.Set(reflect.ValueOf(v))
 	return nil
 }
 
@@ -330,3 +934,47 @@ func (f *oneof) Predict(string) []string {
 	// Ignore prefix; returned values are filtered by it anyway.
 	return f.choices
 }
+
+// countValue implements flag.Value for a flag that counts the number of times
+// it appears, like -v -v -v.
+type countValue struct {
+	field reflect.Value // an integer
+	def   int64         // value of field at registration
+	cmd   *Command
+	name  string
+}
+
+// IsBoolFlag makes the flag package treat a count like a boolean flag,
+// so it takes no value.
+func (c *countValue) IsBoolFlag() bool { return true }
+
+// String implements flag.Value.
+func (c *countValue) String() string {
+	if !c.field.IsValid() {
+		return "0"
+	}
+	return strconv.FormatInt(c.field.Int(), 10)
+}
+
+// Set implements flag.Value.
+// A flag without a value, which the flag package passes as "true",
+// increments the count. An explicit value, as in -v=3, sets it.
+func (c *countValue) Set(s string) error {
+	if s == "true" {
+		// The first use in a run counts from the default, not from the
+		// count of an earlier run. The flag set doesn't record this use
+		// until Set returns, so Changed reports earlier ones.
+		n := c.def
+		if c.cmd.Changed(c.name) {
+			n = c.field.Int()
+		}
+		c.field.SetInt(n + 1)
+		return nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	c.field.SetInt(n)
+	return nil
+}
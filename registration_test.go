@@ -3,7 +3,14 @@
 package cli
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -28,12 +35,66 @@ func TestTagToMap(t *testing.T) {
 			"oneof=a|b",
 			map[string]string{"oneof": "a|b"},
 		},
+		{
+			"doc='one, two', flag=f",
+			map[string]string{"doc": "one, two", "flag": "f"},
+		},
+		{
+			"oneof='a,b|c' , name=n",
+			map[string]string{"oneof": "a,b|c", "name": "n"},
+		},
+		{
+			"flag=f, matchmsg='can''t be empty'",
+			map[string]string{"flag": "f", "matchmsg": "can't be empty"},
+		},
+		{
+			"flag=f, ' key=value pairs, separated by commas'",
+			map[string]string{"flag": "f", "doc": " key=value pairs, separated by commas"},
+		},
+		{
+			"flag=f, the flag's doc",
+			map[string]string{"flag": "f", "doc": "the flag's doc"},
+		},
+		{
+			"flag=token, secret, the token",
+			map[string]string{"flag": "token", "secret": "", "doc": "the token"},
+		},
+		{
+			"flag=v, count , accumulate,raw, verbosity",
+			map[string]string{"flag": "v", "count": "", "accumulate": "", "raw": "", "doc": "verbosity"},
+		},
+		{
+			"secret",
+			map[string]string{"secret": ""},
+		},
+		{
+			"flag=f, secrets, counted",
+			map[string]string{"flag": "f", "doc": "secrets, counted"},
+		},
+		{
+			"doc=''",
+			map[string]string{"doc": ""},
+		},
 	} {
-		got := tagToMap(test.tag)
+		got, err := tagToMap(test.tag)
+		if err != nil {
+			t.Errorf("%q: %v", test.tag, err)
+			continue
+		}
 		if !cmp.Equal(got, test.want) {
 			t.Errorf("%q:\ngot  %+v\nwant %+v", test.tag, got, test.want)
 		}
 	}
+
+	for _, tag := range []string{
+		"doc='unterminated",
+		"doc='a' b, flag=f",
+		"flag=f, 'doc' more",
+	} {
+		if got, err := tagToMap(tag); err == nil {
+			t.Errorf("%q: got %v, want error", tag, got)
+		}
+	}
 }
 
 func TestParseTagArgs(t *testing.T) {
@@ -70,18 +131,18 @@ func TestParseTagArgs(t *testing.T) {
 		},
 	} {
 		c := initFlags(&Command{})
-		err := c.parseTag(test.tag, sf, f)
+		err := c.parseTag(test.tag, sf, f, "")
 		if err != nil {
 			if !test.wantErr {
 				t.Errorf("%q: unwanted error: <%v>", test.tag, err)
 			}
 		} else {
 			got := c.formals[0]
-			if got.name != test.wantName {
-				t.Errorf("%q, name: got %q, want %q", test.tag, got.name, test.wantName)
+			if got.Name != test.wantName {
+				t.Errorf("%q, name: got %q, want %q", test.tag, got.Name, test.wantName)
 			}
-			if got.usage != test.wantDoc {
-				t.Errorf("%q, doc: got %q, want %q", test.tag, got.usage, test.wantDoc)
+			if got.Usage != test.wantDoc {
+				t.Errorf("%q, doc: got %q, want %q", test.tag, got.Usage, test.wantDoc)
 			}
 			gotp, err := got.parser("ga")
 			if err != nil {
@@ -120,9 +181,13 @@ func TestParseTagFlags(t *testing.T) {
 			wantName: "f",
 			wantDoc:  "something; one of a, b, c",
 		},
+		{
+			tag:     "flag=, deprecated=, old",
+			wantErr: true,
+		},
 	} {
 		c := initFlags(&Command{})
-		err := c.parseTag(test.tag, sf, f)
+		err := c.parseTag(test.tag, sf, f, "")
 		if err != nil {
 			if !test.wantErr {
 				t.Errorf("%q: unwanted error: <%v>", test.tag, err)
@@ -171,6 +236,81 @@ func TestProcessFieldsErrors(t *testing.T) {
 	}
 	check(&t3{}, "last")
 
+	// flags differing only by case
+	type t5 struct {
+		A bool `cli:"flag=Goo"`
+		B bool `cli:"flag=goo"`
+	}
+	checkFlags := func(s interface{}, want string) {
+		t.Helper()
+		got := initFlags(&Command{Struct: s}).processFields()
+		if got == nil || !strings.Contains(got.Error(), want) {
+			t.Errorf("got %v, want error containing %q", got, want)
+		}
+	}
+	checkFlags(&t5{}, `field "B": flag -goo conflicts with flag -Goo of field "A"`)
+
+	// flags differing only by leading dashes
+	type t6 struct {
+		A bool `cli:"flag=x|--goo"`
+		B bool `cli:"flag=-goo"`
+	}
+	checkFlags(&t6{}, `flag -goo conflicts with flag -goo of field "A"`)
+
+	// completion comes from "type"
+	type t7 struct {
+		A string `cli:"complete=files"`
+	}
+	check(&t7{}, `invalid key: "complete"`)
+
+	// bad path types
+	type t9 struct {
+		A string `cli:"type=file"`
+	}
+	check(&t9{}, `type: got "file"`)
+	type t10 struct {
+		A string `cli:"type=glob:["`
+	}
+	check(&t10{}, "bad glob pattern")
+	type t11 struct {
+		A int `cli:"flag=a, type=existingfile"`
+	}
+	checkFlags(&t11{}, "type is only for strings")
+
+	// bad raw fields
+	type t12 struct {
+		A []int `cli:"raw="`
+	}
+	check(&t12{}, "requires a []string field")
+	type t13 struct {
+		A []string `cli:"raw=, flag=a"`
+	}
+	check(&t13{}, "'raw' cannot be combined")
+
+	// bad sep and accumulate
+	type t14 struct {
+		A []string `cli:"sep=;"`
+	}
+	check(&t14{}, "'sep' is only for slice flags and maps")
+	type t15 struct {
+		A string `cli:"flag=a, accumulate="`
+	}
+	checkFlags(&t15{}, "'accumulate' is only for slice flags")
+
+	// bad env and config
+	type t16 struct {
+		A string `cli:"env=A"`
+	}
+	check(&t16{}, "'env' and 'config' are only for flags")
+	type t17 struct {
+		A string `cli:"flag=a, config="`
+	}
+	checkFlags(&t17{}, "config: empty key")
+	type t18 struct {
+		A int `cli:"flag=a, count=, env=A"`
+	}
+	checkFlags(&t18{}, "not supported for counts")
+
 	// both args and sub-commands
 	type t4 struct {
 		A int
@@ -193,12 +333,12 @@ func TestBindFormals(t *testing.T) {
 	parser := func(s string) (interface{}, error) { return s, nil }
 
 	form := func(p *string, opt bool) *formal {
-		return &formal{min: -1, opt: opt, parser: parser, field: reflect.ValueOf(p).Elem()}
+		return &formal{ArgSpec: ArgSpec{Optional: opt}, parser: parser, field: reflect.ValueOf(p).Elem()}
 	}
 	req := func(p *string) *formal { return form(p, false) }
 	opt := func(p *string) *formal { return form(p, true) }
 	rest := func(min int, p *[]string) *formal {
-		return &formal{name: "r", min: min, parser: parser, field: reflect.ValueOf(p).Elem()}
+		return &formal{ArgSpec: ArgSpec{Name: "r", Variadic: true, Min: min}, parser: parser, field: reflect.ValueOf(p).Elem()}
 	}
 
 	for _, test := range []struct {
@@ -340,3 +480,475 @@ func TestFlagUsage(t *testing.T) {
 		t.Errorf("got %q, want %q", got, want)
 	}
 }
+
+func TestMapFlag(t *testing.T) {
+	type s struct {
+		Labels map[string]string `cli:"flag=label, labels"`
+	}
+	v := &s{}
+	cmd := initFlags(&Command{Struct: v})
+	if err := cmd.processFields(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.flags.Parse([]string{"-label", "a=1,b=2", "-label", "c=3"}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "1", "b": "2", "c": "3"}
+	if !cmp.Equal(v.Labels, want) {
+		t.Errorf("got %v, want %v", v.Labels, want)
+	}
+	if got, want := cmd.flags.Lookup("label").Usage, "; comma-separated key=value pairs; can be repeated"; !strings.HasSuffix(got, want) {
+		t.Errorf("usage: got %q, want suffix %q", got, want)
+	}
+
+	// The first use on the command line replaces a default map, which is
+	// not changed, and a value set from configuration.
+	def := map[string]string{"x": "0"}
+	v = &s{Labels: def}
+	cmd = initFlags(&Command{Struct: v})
+	if err := cmd.processFields(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.flags.Lookup("label").Value.Set("y=9"); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"y": "9"}; !cmp.Equal(v.Labels, want) {
+		t.Errorf("configured: got %v, want %v", v.Labels, want)
+	}
+	if err := cmd.flags.Parse([]string{"-label", "a=1", "-label", "b=2"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"x": "0"}; !cmp.Equal(def, want) {
+		t.Errorf("default: got %v, want %v", def, want)
+	}
+	if want := map[string]string{"a": "1", "b": "2"}; !cmp.Equal(v.Labels, want) {
+		t.Errorf("got %v, want %v", v.Labels, want)
+	}
+}
+
+func TestSepAndAccumulate(t *testing.T) {
+	type s struct {
+		Queries []string          `cli:"flag=q, sep=;, queries"`
+		Tags    []string          `cli:"flag=tag, accumulate=, tags"`
+		Hosts   []string          `cli:"flag=host, sep=' ', accumulate=, hosts"`
+		Env     map[string]string `cli:"flag=env, sep=;, variables"`
+	}
+	v := &s{Tags: []string{"default"}}
+	cmd := initFlags(&Command{Struct: v})
+	if err := cmd.processFields(); err != nil {
+		t.Fatal(err)
+	}
+	err := cmd.flags.Parse([]string{
+		"-q", "a,b;c",
+		"-tag", "x,y", "-tag", "z",
+		"-host", "h1 h2", "-host", "h3",
+		"-env", "A=1,2;B=3",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &s{
+		Queries: []string{"a,b", "c"},
+		Tags:    []string{"x", "y", "z"},
+		Hosts:   []string{"h1", "h2", "h3"},
+		Env:     map[string]string{"A": "1,2", "B": "3"},
+	}
+	if diff := cmp.Diff(want, v); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	if got, want := cmd.flags.Lookup("q").Usage, `";"-separated list of`; !strings.Contains(got, want) {
+		t.Errorf("usage: got %q, want it to contain %q", got, want)
+	}
+}
+
+// level implements flag.Value.
+type level int
+
+func (l *level) String() string { return strconv.Itoa(int(*l)) }
+
+func (l *level) Set(s string) error {
+	switch s {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("bad level")
+	}
+	return nil
+}
+
+func TestFlagValue(t *testing.T) {
+	type s struct {
+		L level `cli:"flag=level, the level"`
+		A level
+	}
+	v := &s{}
+	cmd := initFlags(&Command{Struct: v})
+	if err := cmd.processFields(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.flags.Parse([]string{"-level", "high", "low"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.bindFormals(cmd.formals, cmd.flags.Args()); err != nil {
+		t.Fatal(err)
+	}
+	if v.L != 2 || v.A != 1 {
+		t.Errorf("got %+v, want {L:2 A:1}", *v)
+	}
+}
+
+func TestFlagAliases(t *testing.T) {
+	type s struct {
+		Verbose bool   `cli:"flag=v|-verbose, verbose output"`
+		Out     string `cli:"flag=o|out, output file"`
+	}
+	v := &s{}
+	cmd := initFlags(&Command{Name: "cmd", Struct: v})
+	if err := cmd.processFields(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.flags.Parse([]string{"-verbose", "-out", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if !v.Verbose || v.Out != "x" {
+		t.Errorf("got %+v", *v)
+	}
+	var b strings.Builder
+	cmd.printFlags(&b, helpStyle{})
+	got := b.String()
+	want := `  -o, -out value
+    	output file
+  -v, -verbose
+    	verbose output
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	type db struct {
+		Host string `cli:"flag=, database host"`
+		Port int    `cli:"flag=, database port"`
+	}
+	type s struct {
+		DB  db `cli:"prefix=db."`
+		Arg string
+	}
+	v := &s{}
+	cmd := initFlags(&Command{Struct: v})
+	if err := cmd.processFields(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.flags.Parse([]string{"-db.host", "h", "-db.port", "5"}); err != nil {
+		t.Fatal(err)
+	}
+	if v.DB.Host != "h" || v.DB.Port != 5 {
+		t.Errorf("got %+v", v.DB)
+	}
+
+	type bad struct {
+		DB struct{ A string } `cli:"prefix=db."`
+	}
+	err := (&Command{Struct: &bad{}}).processFields()
+	if want := "positional argument"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got %v, want error containing %q", err, want)
+	}
+}
+
+func TestCountFlag(t *testing.T) {
+	type s struct {
+		V int `cli:"flag=v, count=, verbosity"`
+	}
+	for _, test := range []struct {
+		args []string
+		want int
+	}{
+		{nil, 0},
+		{[]string{"-v"}, 1},
+		{[]string{"-v", "-v", "-v"}, 3},
+		{[]string{"-v=5", "-v"}, 6},
+	} {
+		v := &s{}
+		cmd := initFlags(&Command{Struct: v})
+		if err := cmd.processFields(); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.flags.Parse(test.args); err != nil {
+			t.Fatal(err)
+		}
+		if v.V != test.want {
+			t.Errorf("%v: got %d, want %d", test.args, v.V, test.want)
+		}
+	}
+
+	// Each run counts from the default.
+	top := initFlags(&Command{Name: "prog"})
+	v := &s{V: 1}
+	top.Register(&Command{Name: "log", Struct: v, runner: RunFunc(func(context.Context) error { return nil })})
+	for i := 0; i < 2; i++ {
+		if err := top.Run(context.Background(), []string{"log", "-v", "-v"}); err != nil {
+			t.Fatal(err)
+		}
+		if v.V != 3 {
+			t.Errorf("run %d: got %d, want 3", i+1, v.V)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	type s struct {
+		Name  string   `cli:"match=^[a-z]+$, matchmsg=must be lowercase"`
+		Hosts []string `cli:"flag=hosts, match=^h[0-9]$, hosts"`
+	}
+	v := &s{}
+	cmd := initFlags(&Command{Struct: v})
+	if err := cmd.processFields(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmd.formals[0].parser("Pat"); err == nil || err.Error() != "must be lowercase" {
+		t.Errorf("got %v, want 'must be lowercase'", err)
+	}
+	if _, err := cmd.formals[0].parser("pat"); err != nil {
+		t.Error(err)
+	}
+	err := cmd.flags.Parse([]string{"-hosts", "h1,x2"})
+	if want := "must match ^h[0-9]$"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got %v, want error containing %q", err, want)
+	}
+}
+
+func TestPathType(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.json")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	type s struct {
+		Config string   `cli:"flag=config, type=existingfile, config file"`
+		Out    string   `cli:"flag=out, type=existingdir, output directory"`
+		Inputs []string `cli:"type=glob:*.json, inputs"`
+	}
+	for _, test := range []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{"-config", file, "-out", dir, "x.json", file}, ""},
+		{[]string{"-config", dir}, "is a directory"},
+		{[]string{"-config", filepath.Join(dir, "nope")}, "no such file"},
+		{[]string{"-out", file}, "is not a directory"},
+		{[]string{"x.json", "y.txt"}, "y.txt does not match *.json"},
+	} {
+		top := initFlags(&Command{Name: "top"})
+		cmd := top.Register(&Command{Name: "cmd", Struct: &s{}})
+		cmd.flags.SetOutput(io.Discard)
+		err := cmd.flags.Parse(test.args)
+		if err == nil {
+			err = cmd.bindFormals(cmd.formals, cmd.flags.Args())
+		}
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%v: %v", test.args, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%v: got %v, want error containing %q", test.args, err, test.wantErr)
+		}
+	}
+}
+
+func TestDefineChoices(t *testing.T) {
+	type s struct {
+		Env   string `cli:"flag=, oneof=@env, environment"`
+		Stage string `cli:"oneof=@env, stage"`
+	}
+	top := initFlags(&Command{Name: "top"})
+	top.DefineChoices("env", "dev", "prod")
+	v := &s{}
+	sub := top.Register(&Command{Name: "sub", Struct: v})
+	sub.flags.SetOutput(io.Discard)
+	if err := sub.flags.Parse([]string{"-env", "prod"}); err != nil {
+		t.Fatal(err)
+	}
+	if v.Env != "prod" {
+		t.Errorf("got %q, want %q", v.Env, "prod")
+	}
+	if err := sub.flags.Parse([]string{"-env", "staging"}); err == nil {
+		t.Error("got nil, want error for value not in choices")
+	}
+	if got, want := sub.formals[0].Choices, []string{"dev", "prod"}; !cmp.Equal(got, want) {
+		t.Errorf("arg choices: got %v, want %v", got, want)
+	}
+
+	// Choices set on the top command can be used by its own fields.
+	tv := &s{}
+	top2 := initFlags(&Command{Name: "top", Struct: tv, Choices: map[string][]string{"env": {"dev", "prod"}}})
+	if err := top2.processFields(); err != nil {
+		t.Fatal(err)
+	}
+	top2.flags.SetOutput(io.Discard)
+	if err := top2.flags.Parse([]string{"-env", "dev"}); err != nil || tv.Env != "dev" {
+		t.Errorf("top: got %q, %v; want %q, nil", tv.Env, err, "dev")
+	}
+
+	type bad struct {
+		Env string `cli:"flag=, oneof=@nope"`
+	}
+	err := top.register(initFlags(&Command{Name: "bad", Struct: &bad{}}))
+	if err == nil || !strings.Contains(err.Error(), `no choices named "nope"`) {
+		t.Errorf("got %v, want unknown choices error", err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	type s struct {
+		Env  string            `cli:"flag=, normalize=trim|lower, oneof=dev|prod"`
+		Dirs []string          `cli:"flag=, normalize=path"`
+		Tags map[string]string `cli:"flag=, normalize=upper"`
+		Name string            `cli:"normalize=lower"`
+	}
+	v := &s{}
+	cmd := initFlags(&Command{Struct: v})
+	if err := cmd.processFields(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.flags.Parse([]string{"-env", " PROD ", "-dirs", "a/b/../c,./d/", "-tags", "k=v"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.bindFormals(cmd.formals, []string{"Bob"}); err != nil {
+		t.Fatal(err)
+	}
+	want := &s{
+		Env:  "prod",
+		Dirs: []string{"a/c", "d"},
+		Tags: map[string]string{"k": "V"},
+		Name: "bob",
+	}
+	if diff := cmp.Diff(want, v); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	for _, tag := range []string{"flag=, normalize=squash", "flag=, normalize=lower"} {
+		type bad struct {
+			N int
+		}
+		sf := reflect.TypeOf(bad{}).Field(0)
+		c := initFlags(&Command{})
+		if err := c.parseTag(tag, sf, reflect.ValueOf(&bad{}).Elem().Field(0), ""); err == nil {
+			t.Errorf("%q: got nil, want error", tag)
+		}
+	}
+}
+
+func TestBundles(t *testing.T) {
+	type auth struct {
+		Token string `cli:"flag=, auth token"`
+	}
+	type output struct {
+		Format string `cli:"flag=, oneof=text|json, output format"`
+	}
+	a, o := &auth{}, &output{}
+	top := initFlags(&Command{Name: "top"})
+	sub := top.Command("sub", &c1{}, "", a, o)
+	if err := sub.flags.Parse([]string{"-token", "t", "-format", "json", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.bindFormals(sub.formals, sub.flags.Args()); err != nil {
+		t.Fatal(err)
+	}
+	if a.Token != "t" || o.Format != "json" || sub.Struct.(*c1).A != 3 {
+		t.Errorf("got %+v, %+v, %+v", a, o, sub.Struct)
+	}
+
+	err := top.register(initFlags(&Command{Name: "bad", Struct: &c1{}}), auth{})
+	if err == nil || !strings.Contains(err.Error(), "not a pointer to a struct") {
+		t.Errorf("got %v, want error for non-pointer bundle", err)
+	}
+}
+
+type secretCmd struct {
+	Token string `cli:"flag=token, secret, API token"`
+	Port  int    `cli:"flag=port|p, secret=, env=CLI_TEST_SECRET_PORT, port"`
+	Debug bool   `cli:"flag=debug, secret=, debug"`
+	Name  string `cli:"flag=name, user name"`
+}
+
+func (*secretCmd) Run(context.Context) error { return nil }
+
+func TestSecretFlag(t *testing.T) {
+	top := initFlags(&Command{Name: "prog"})
+	login := top.Command("login", &secretCmd{Token: "hunter2", Port: 8080, Debug: true, Name: "pat"}, "log in")
+	var buf bytes.Buffer
+	login.usage(&buf)
+	help := buf.String()
+	for _, s := range []string{"hunter2", "8080", "true"} {
+		if strings.Contains(help, s) {
+			t.Errorf("help contains %q:\n%s", s, help)
+		}
+	}
+	if got, want := strings.Count(help, "(default (hidden))"), 3; got != want {
+		t.Errorf("got %d hidden defaults, want %d:\n%s", got, want, help)
+	}
+	if !strings.Contains(help, `(default "pat")`) {
+		t.Errorf("help is missing the default of -name:\n%s", help)
+	}
+	for _, f := range login.Spec().Flags {
+		if f.Name != "name" && f.Default != hiddenValue {
+			t.Errorf("spec of -%s: got default %q, want %q", f.Name, f.Default, hiddenValue)
+		}
+	}
+
+	// Invalid values are not in error messages.
+	_, stderr, code := top.ExecuteCapture(context.Background(), "login", "-p", "x8x")
+	if code != 2 || strings.Contains(stderr, "x8x") || !strings.Contains(stderr, "invalid value for flag -port") {
+		t.Errorf("got code %d, stderr\n%s", code, stderr)
+	}
+	t.Setenv("CLI_TEST_SECRET_PORT", "y9y")
+	_, stderr, code = top.ExecuteCapture(context.Background(), "login")
+	if code == 0 || strings.Contains(stderr, "y9y") || !strings.Contains(stderr, "$CLI_TEST_SECRET_PORT") {
+		t.Errorf("env: got code %d, stderr\n%s", code, stderr)
+	}
+	t.Setenv("CLI_TEST_SECRET_PORT", "")
+	if _, stderr, code := top.ExecuteCapture(context.Background(), "login", "-port", "9090", "-debug"); code != 0 {
+		t.Errorf("valid values: got code %d, stderr\n%s", code, stderr)
+	}
+	// Only the quoted value is hidden, not other text that contains it.
+	_, stderr, _ = top.ExecuteCapture(context.Background(), "login", "-p", "a")
+	if want := `parsing (hidden): invalid syntax`; !strings.Contains(stderr, want) {
+		t.Errorf("got stderr\n%s\nwant it to contain %q", stderr, want)
+	}
+
+	// The error is reported by the run that caused it, on an instance.
+	re := initFlags(&Command{Name: "prog", Reentrant: true})
+	re.Command("login", &secretCmd{}, "log in")
+	for _, test := range []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{"login", "-p", "x8x"}, true},
+		{[]string{"login", "-p", "80"}, false},
+	} {
+		var out strings.Builder
+		ctx := withInvocation(context.Background(), &invocation{stdout: &out, stderr: &out})
+		err := re.Run(ctx, test.args)
+		if (err != nil) != test.wantErr || strings.Contains(out.String(), "x8x") {
+			t.Errorf("Reentrant %v: got %v, output\n%s", test.args, err, out.String())
+		}
+	}
+
+	type pos struct {
+		A string `cli:"secret=, a"`
+	}
+	err := (&Command{Struct: &pos{}}).processFields()
+	if want := "'secret' is only for flags"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got %v, want error containing %q", err, want)
+	}
+	type count struct {
+		V int `cli:"flag=v, count, secret="`
+	}
+	err = initFlags(&Command{Struct: &count{}}).processFields()
+	if want := "not supported for counts"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got %v, want error containing %q", err, want)
+	}
+}
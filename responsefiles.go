@@ -0,0 +1,40 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Reading arguments from files.
+
+// expandResponseFiles replaces each argument of args of the form @FILE with
+// the arguments in FILE. See Command.ResponseFiles.
+func expandResponseFiles(args []string) ([]string, error) {
+	var out []string
+	for i, a := range args {
+		switch {
+		case a == "--":
+			return append(out, args[i:]...), nil
+		case strings.HasPrefix(a, "@@"):
+			out = append(out, a[1:])
+		case len(a) > 1 && a[0] == '@':
+			data, err := os.ReadFile(a[1:])
+			if err != nil {
+				return nil, err
+			}
+			for _, line := range strings.Split(string(data), "\n") {
+				words, err := splitCommandLine(strings.TrimSuffix(line, "\r"))
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", a[1:], err)
+				}
+				out = append(out, words...)
+			}
+		default:
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
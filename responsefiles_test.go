@@ -0,0 +1,39 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResponseFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "args.txt")
+	if err := os.WriteFile(file, []byte("-loud\r\n'b c'\n\n  d @e\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	top := initFlags(&Command{Name: "prog", ResponseFiles: true})
+	top.Command("say", &shouter{}, "say words")
+	for _, test := range []struct {
+		args    []string
+		wantOut string
+		wantErr string
+	}{
+		{[]string{"say", "a", "@" + file}, "A B C D @E", ""},
+		{[]string{"say", "@@x", "@"}, "@x @", ""},
+		{[]string{"say", "--", "@" + file}, "@" + file, ""},
+		{[]string{"say", "@" + filepath.Join(dir, "nosuch")}, "", "no such file"},
+	} {
+		stdout, stderr, _ := top.ExecuteCapture(context.Background(), test.args...)
+		if got := strings.TrimSpace(stdout); got != test.wantOut {
+			t.Errorf("%v: got %q, want %q", test.args, got, test.wantOut)
+		}
+		if !strings.Contains(stderr, test.wantErr) {
+			t.Errorf("%v: stderr = %q, want it to contain %q", test.args, stderr, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Keeping the values of secret flags out of help and error messages.
+
+// hiddenValue is displayed in place of the value of a secret flag.
+const hiddenValue = "(hidden)"
+
+// secretValue wraps the flag.Value of a flag with the "secret" tag key.
+//
+// The flag package quotes a value it can't set in its error message, so when
+// parsing the command line, Set doesn't return an error. It records one
+// without the value instead, for parseFlagsHelp to report; see takeSecretErr.
+type secretValue struct {
+	flag.Value
+	name string
+	err  error // from Set
+}
+
+// String implements flag.Value.
+func (v *secretValue) String() string {
+	// The flag package calls String on a zero secretValue.
+	if v.Value == nil {
+		return ""
+	}
+	return v.Value.String()
+}
+
+// Set implements flag.Value.
+func (v *secretValue) Set(s string) error {
+	if err := v.set(s); err != nil && v.err == nil {
+		v.err = fmt.Errorf("invalid value for flag -%s: %w", v.name, err)
+	}
+	return nil
+}
+
+// set sets the underlying value to s, and returns any error with s, as
+// quoted by strconv.Quote, replaced by hiddenValue.
+func (v *secretValue) set(s string) error {
+	err := v.Value.Set(s)
+	if err == nil || s == "" {
+		return err
+	}
+	if q := strconv.Quote(s); strings.Contains(err.Error(), q) {
+		return errors.New(strings.ReplaceAll(err.Error(), q, hiddenValue))
+	}
+	return err
+}
+
+// takeSecretErr returns the first error, in the order of the flags' names,
+// recorded by the secret flags of c, and clears them all.
+func (c *Command) takeSecretErr() error {
+	var err error
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if v, ok := f.Value.(*secretValue); ok {
+			if err == nil {
+				err = v.err
+			}
+			v.err = nil
+		}
+	})
+	return err
+}
+
+// IsBoolFlag lets a secret boolean flag be set without a value.
+func (v *secretValue) IsBoolFlag() bool {
+	b, ok := v.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// setFlagValue sets f to s, as from an environment variable or a
+// configuration file. Its errors don't include the values of secret flags.
+func setFlagValue(f *flag.Flag, s string) error {
+	if v, ok := f.Value.(*secretValue); ok {
+		return v.set(s)
+	}
+	return f.Value.Set(s)
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Reporting flags that deserve a security review.
+
+// An InputRisk is a reason that a flag is part of a program's attack surface.
+type InputRisk string
+
+const (
+	RiskSecret InputRisk = "secret" // the value is a credential, like a password or token
+	RiskFile   InputRisk = "file"   // the value names a file that the program may read
+	RiskURL    InputRisk = "url"    // the value names a network resource
+)
+
+// A FlagReview is an entry in a security report: a flag and its risks.
+type FlagReview struct {
+	Command string      `json:"command"` // path of the command, like "prog sub"
+	Flag    string      `json:"flag"`
+	Type    string      `json:"type,omitempty"`
+	Risks   []InputRisk `json:"risks"`
+}
+
+// SecurityReport returns a review entry for each flag in the command tree
+// described by s whose name or type suggests that it carries a secret, reads a
+// file or accepts a URL, in the order of the tree. Programs can write the
+// report as JSON for a security team.
+//
+// The classification is mostly by name, so it errs toward including flags. A
+// flag is a secret if its tag has the "secret" key, or if a word of its name
+// or an alias is one like "password" or "token"; it reads a file if a word is one like "file", "path" or "config";
+// and it accepts a URL if a word is one like "url", "endpoint" or "host", or
+// if its type is url.URL. Words are separated by punctuation or by a change
+// from lower to upper case, so "api-token" and "apiToken" both contain
+// "token".
+func SecurityReport(s *Spec) []FlagReview {
+	var rs []FlagReview
+	securityReport(s, s.Name, &rs)
+	return rs
+}
+
+func securityReport(s *Spec, path string, rs *[]FlagReview) {
+	for _, f := range s.Flags {
+		if risks := flagRisks(f); len(risks) > 0 {
+			*rs = append(*rs, FlagReview{Command: path, Flag: f.Name, Type: f.Type, Risks: risks})
+		}
+	}
+	for _, sub := range s.Commands {
+		securityReport(sub, path+" "+sub.Name, rs)
+	}
+}
+
+var riskWords = map[InputRisk][]string{
+	RiskSecret: {"password", "passwd", "passphrase", "secret", "token", "apikey", "credential", "credentials", "auth"},
+	RiskFile:   {"file", "files", "filename", "path", "dir", "config", "cert", "cacert", "keyfile", "input", "in"},
+	RiskURL:    {"url", "uri", "endpoint", "addr", "address", "host", "server", "proxy"},
+}
+
+func flagRisks(f *FlagSpec) []InputRisk {
+	words := map[string]bool{}
+	for _, n := range append([]string{f.Name}, f.Aliases...) {
+		for _, w := range nameWords(n) {
+			words[w] = true
+		}
+	}
+	var risks []InputRisk
+	for _, r := range []InputRisk{RiskSecret, RiskFile, RiskURL} {
+		match := (r == RiskURL && strings.HasSuffix(f.Type, "url.URL")) || (r == RiskSecret && f.Secret)
+		for _, w := range riskWords[r] {
+			match = match || words[w]
+		}
+		if match {
+			risks = append(risks, r)
+		}
+	}
+	return risks
+}
+
+// nameWords splits a flag name into lower-case words.
+func nameWords(name string) []string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, strings.ToLower(string(cur)))
+			cur = cur[:0]
+		}
+	}
+	var prev rune
+	for _, r := range name {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			flush()
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+		prev = r
+	}
+	flush()
+	return words
+}
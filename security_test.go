@@ -0,0 +1,69 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type loginCmd struct {
+	Password string `cli:"flag=password|p, the password"`
+	APIToken string `cli:"flag=apiToken, the token"`
+	Config   string `cli:"flag=config-file, configuration"`
+	Server   string `cli:"flag=s|server, the server"`
+	Verbose  bool   `cli:"flag=verbose, more output"`
+	Infinite bool   `cli:"flag=infinite, keep going"`
+}
+
+func (*loginCmd) Run(context.Context) error { return nil }
+
+func TestSecurityReport(t *testing.T) {
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	g := top.Command("auth", nil, "")
+	g.Command("login", &loginCmd{}, "")
+	got := SecurityReport(top.Spec())
+	want := []FlagReview{
+		{Command: "prog auth login", Flag: "password", Type: "string", Risks: []InputRisk{RiskSecret}},
+		{Command: "prog auth login", Flag: "apiToken", Type: "string", Risks: []InputRisk{RiskSecret}},
+		{Command: "prog auth login", Flag: "config-file", Type: "string", Risks: []InputRisk{RiskFile}},
+		{Command: "prog auth login", Flag: "s", Type: "string", Risks: []InputRisk{RiskURL}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	// A flag whose type is a URL.
+	got = SecurityReport(&Spec{Name: "x", Flags: []*FlagSpec{{Name: "o", Type: "*url.URL"}}})
+	want = []FlagReview{{Command: "x", Flag: "o", Type: "*url.URL", Risks: []InputRisk{RiskURL}}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	// A flag tagged "secret".
+	got = SecurityReport(&Spec{Name: "x", Flags: []*FlagSpec{{Name: "key", Type: "string", Secret: true}}})
+	want = []FlagReview{{Command: "x", Flag: "key", Type: "string", Risks: []InputRisk{RiskSecret}}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestNameWords(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []string
+	}{
+		{"api-token", []string{"api", "token"}},
+		{"apiToken", []string{"api", "token"}},
+		{"db.host", []string{"db", "host"}},
+		{"URL", []string{"url"}},
+		{"tlsCAFile", []string{"tls", "cafile"}},
+	} {
+		if got := nameWords(test.in); !cmp.Equal(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
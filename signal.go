@@ -0,0 +1,70 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Handling of termination signals.
+
+// A signalWatcher cancels a context when a signal arrives, and remembers
+// the signal.
+type signalWatcher struct {
+	mu  sync.Mutex
+	sig os.Signal
+}
+
+// watch returns a context derived from ctx that is canceled when a
+// signal is received on ch. The returned function stops watching; it must be
+// called.
+func (w *signalWatcher) watch(ctx context.Context, ch <-chan os.Signal) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case s := <-ch:
+			w.mu.Lock()
+			w.sig = s
+			w.mu.Unlock()
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// signal returns the signal that was received, or nil if none.
+func (w *signalWatcher) signal() os.Signal {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sig
+}
+
+// exitCode returns the exit code for a process that failed with the given
+// code. If a signal was received, it is 128 plus the signal number, following
+// shell convention: 130 for SIGINT and 143 for SIGTERM.
+func (w *signalWatcher) exitCode(code int) int {
+	if code == 0 {
+		return 0
+	}
+	if s, ok := w.signal().(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return code
+}
+
+// mainWithSignals is like mainWithArgs, but cancels the context on SIGINT or
+// SIGTERM and reports those signals in the exit code.
+func (c *Command) mainWithSignals(ctx context.Context, args []string) int {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(ch)
+	var w signalWatcher
+	ctx, stop := w.watch(ctx, ch)
+	defer stop()
+	return w.exitCode(c.mainWithArgs(ctx, args))
+}
@@ -0,0 +1,28 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestSignalWatcher(t *testing.T) {
+	var w signalWatcher
+	ch := make(chan os.Signal, 1)
+	ctx, stop := w.watch(context.Background(), ch)
+	defer stop()
+	if got := w.exitCode(1); got != 1 {
+		t.Errorf("before signal: got %d, want 1", got)
+	}
+	ch <- syscall.SIGTERM
+	<-ctx.Done()
+	if got := w.exitCode(1); got != 143 {
+		t.Errorf("after SIGTERM: got %d, want 143", got)
+	}
+	if got := w.exitCode(0); got != 0 {
+		t.Errorf("success after SIGTERM: got %d, want 0", got)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// A Spec describes a command: its flags, positional arguments and
+// sub-commands. It is the model from which usage messages, documentation,
+// completions and other descriptions of a command tree can be generated.
+type Spec struct {
+	Name       string      `json:"name"`
+	Usage      string      `json:"usage,omitempty"`
+	Long       string      `json:"long,omitempty"`
+	Deprecated string      `json:"deprecated,omitempty"`
+	Category   string      `json:"category,omitempty"`
+	Runnable   bool        `json:"runnable"`          // if false, the command is a group
+	Default    string      `json:"default,omitempty"` // default sub-command of a group
+	Flags      []*FlagSpec `json:"flags,omitempty"`
+	Args       []*ArgSpec  `json:"args,omitempty"`
+	Commands   []*Spec     `json:"commands,omitempty"`
+}
+
+// A FlagSpec describes a flag.
+type FlagSpec struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	// Usage is the flag's usage as displayed in help, including any notes
+	// about choices, ranges, requirements and defaults.
+	Usage      string   `json:"usage,omitempty"`
+	Type       string   `json:"type,omitempty"` // Go type of the field, if any
+	Default    string   `json:"default,omitempty"`
+	Choices    []string `json:"choices,omitempty"`
+	Requires   []string `json:"requires,omitempty"`
+	Exclusive  string   `json:"exclusive,omitempty"` // the flag's "xor" group
+	Deprecated string   `json:"deprecated,omitempty"`
+	Count      bool     `json:"count,omitempty"`
+	PathType   string   `json:"pathType,omitempty"`  // from the "type" tag key, like "existingfile"
+	Env        string   `json:"env,omitempty"`       // environment variable that sets the flag
+	ConfigKey  string   `json:"configKey,omitempty"` // key in the configuration file that sets the flag
+	Secret     bool     `json:"secret,omitempty"`    // from the "secret" tag key
+
+	field string        // name of the struct field, if any
+	value reflect.Value // the struct field, if any
+	sep   string        // from the "sep" tag key
+}
+
+// An ArgSpec describes a positional argument.
+type ArgSpec struct {
+	Name     string   `json:"name"`
+	Usage    string   `json:"usage,omitempty"`
+	Type     string   `json:"type,omitempty"`     // Go type of the field
+	Optional bool     `json:"optional,omitempty"` // this and all following args may be omitted
+	Variadic bool     `json:"variadic,omitempty"` // takes all remaining args
+	Min      int      `json:"min,omitempty"`      // for a variadic arg, the minimum number of args
+	Choices  []string `json:"choices,omitempty"`
+	PathType string   `json:"pathType,omitempty"` // from the "type" tag key, like "existingfile"
+}
+
+// Spec returns a description of c and its sub-commands.
+// It includes flags that were added to c's flag set directly, like the
+// global flags of the top-level command, though they carry less information.
+func (c *Command) Spec() *Spec {
+	_, runnable := c.runnable()
+	s := &Spec{
+		Name:       c.Name,
+		Usage:      c.Usage,
+		Long:       c.Long,
+		Deprecated: c.Deprecated,
+		Category:   c.Category,
+		Runnable:   runnable,
+		Default:    c.defaultSub,
+	}
+	for _, f := range c.flagSpecs {
+		fs := *f
+		s.Flags = append(s.Flags, &fs)
+	}
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if c.flagSpec(f.Name) == nil {
+			s.Flags = append(s.Flags, &FlagSpec{Name: f.Name, Usage: f.Usage, Default: f.DefValue})
+		}
+	})
+	for _, f := range c.formals {
+		as := f.ArgSpec
+		s.Args = append(s.Args, &as)
+	}
+	for _, sub := range c.subs {
+		s.Commands = append(s.Commands, sub.Spec())
+	}
+	return s
+}
+
+// flagSpec returns the FlagSpec for the flag or alias name, or nil if
+// there is none.
+func (c *Command) flagSpec(name string) *FlagSpec {
+	for _, f := range c.flagSpecs {
+		if f.Name == name {
+			return f
+		}
+		for _, a := range f.Aliases {
+			if a == name {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// writeSpecFiles writes a file for the command described by s, whose path
+// from the top-level command is parent followed by s.Name, and for each
+// command below it, into dir. The file for a command with path p is named
+// name(p), and its contents are written by write. It is used by GenManPages
+// and GenMarkdownTree.
+func writeSpecFiles(s *Spec, parent []string, dir string, name func(path []string) string, write func(w io.Writer, s *Spec, path []string)) error {
+	path := append(append([]string(nil), parent...), s.Name)
+	f, err := os.Create(filepath.Join(dir, name(path)))
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	write(w, s, path)
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	for _, sub := range s.Commands {
+		if err := writeSpecFiles(sub, path, dir, name, write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// synopsis returns the words that follow the command's name in a synopsis
+// of the command described by s: "[flags]", then command if s is a group of
+// commands, then its positional arguments, each formatted by arg. Optional
+// words are in square brackets.
+func (s *Spec) synopsis(command string, arg func(name string) string) []string {
+	var syn []string
+	if len(s.Flags) > 0 {
+		syn = append(syn, "[flags]")
+	}
+	if !s.Runnable && len(s.Commands) > 0 {
+		if s.Default != "" {
+			syn = append(syn, "["+command+"]")
+		} else {
+			syn = append(syn, command)
+		}
+	}
+	for _, a := range s.Args {
+		w := arg(a.Name)
+		if a.Variadic {
+			w += "..."
+		}
+		if a.Optional {
+			w = "[" + w + "]"
+		}
+		syn = append(syn, w)
+	}
+	return syn
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+type specCmd struct {
+	Verbose int      `cli:"flag=v|verbose, count=, verbosity"`
+	Env     string   `cli:"flag=, oneof=dev|prod, environment"`
+	Cert    string   `cli:"flag=, xor=auth, certificate"`
+	Key     string   `cli:"flag=, xor=auth, requires=cert, key"`
+	Old     bool     `cli:"flag=, deprecated=use -env, old"`
+	Name    string   `cli:"name=NAME, the name"`
+	Files   []string `cli:"opt=, min=1, files"`
+}
+
+func (*specCmd) Run(context.Context) error { return nil }
+
+func TestSpec(t *testing.T) {
+	top := Top(&Command{Name: "top"})
+	top.Command("group", nil, "a group").Command("s", &specCmd{Env: "dev"}, "spec test")
+
+	got := top.findSub("group").Spec()
+	want := &Spec{
+		Name:  "group",
+		Usage: "a group",
+		Commands: []*Spec{{
+			Name:     "s",
+			Usage:    "spec test",
+			Runnable: true,
+			Flags: []*FlagSpec{
+				{Name: "v", Aliases: []string{"verbose"}, Usage: "verbosity", Type: "int", Count: true},
+				{Name: "env", Usage: "environment; one of dev, prod (default dev)", Type: "string",
+					Default: "dev", Choices: []string{"dev", "prod"}},
+				{Name: "cert", Usage: "certificate", Type: "string", Exclusive: "auth"},
+				{Name: "key", Usage: "key (requires -cert)", Type: "string", Requires: []string{"cert"}, Exclusive: "auth"},
+				{Name: "old", Usage: "old (deprecated: use -env)", Type: "bool", Deprecated: "use -env"},
+			},
+			Args: []*ArgSpec{
+				{Name: "NAME", Usage: "the name", Type: "string"},
+				{Name: "FILES", Usage: "files", Type: "[]string", Optional: true, Variadic: true, Min: 1},
+			},
+		}},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreUnexported(FlagSpec{})); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Detecting breaking changes between versions of a command tree.
+
+// A BreakingChange is a difference between two versions of a command tree that
+// can make a command line that worked with the old version fail with the new.
+type BreakingChange struct {
+	Command string `json:"command"` // path of the command, like "prog sub"
+	Change  string `json:"change"`
+}
+
+func (b BreakingChange) String() string {
+	return b.Command + ": " + b.Change
+}
+
+// BreakingChanges compares the command trees described by old and new, and
+// reports the changes in new that can break existing uses of old. Typically old
+// is read from a JSON file saved with the previous release, and new is the
+// current Spec; a release pipeline fails if there are any changes.
+//
+// The changes detected are removed commands, flags, aliases and choices;
+// a command that is no longer runnable; changes to the type of a flag or
+// argument; new required arguments; arguments that become required or stop
+// being variadic, or need more values; new flag requirements; and the removal
+// of a default sub-command. A renamed flag appears as a removed one.
+// Additions that don't affect existing command lines, like new commands or
+// flags, are not reported.
+func BreakingChanges(old, new *Spec) []BreakingChange {
+	var bs []BreakingChange
+	diffSpecs(old, new, old.Name, &bs)
+	return bs
+}
+
+func diffSpecs(old, new *Spec, path string, bs *[]BreakingChange) {
+	add := func(format string, args ...interface{}) {
+		*bs = append(*bs, BreakingChange{Command: path, Change: fmt.Sprintf(format, args...)})
+	}
+	if old.Runnable && !new.Runnable {
+		add("no longer runnable")
+	}
+	if old.Default != "" && new.Default != old.Default {
+		add("default sub-command %q removed", old.Default)
+	}
+
+	// Flags.
+	newFlags := map[string]*FlagSpec{}
+	for _, f := range new.Flags {
+		newFlags[f.Name] = f
+		for _, a := range f.Aliases {
+			newFlags[a] = f
+		}
+	}
+	for _, of := range old.Flags {
+		nf := newFlags[of.Name]
+		if nf == nil {
+			add("flag -%s removed", of.Name)
+			continue
+		}
+		for _, a := range of.Aliases {
+			if newFlags[a] == nil {
+				add("flag -%s: alias -%s removed", of.Name, a)
+			}
+		}
+		if of.Type != "" && nf.Type != "" && of.Type != nf.Type {
+			add("flag -%s: type changed from %s to %s", of.Name, of.Type, nf.Type)
+		}
+		if r := removedChoices(of.Choices, nf.Choices); r != "" {
+			add("flag -%s: %s", of.Name, r)
+		}
+		for _, r := range nf.Requires {
+			if !slices.Contains(of.Requires, r) {
+				add("flag -%s: now requires -%s", of.Name, r)
+			}
+		}
+	}
+
+	// Positional arguments. Compare them by position, since names are only
+	// documentation.
+	for i, oa := range old.Args {
+		if i >= len(new.Args) {
+			add("argument %s removed", oa.Name)
+			continue
+		}
+		na := new.Args[i]
+		if oa.Type != "" && na.Type != "" && oa.Type != na.Type {
+			add("argument %s: type changed from %s to %s", oa.Name, oa.Type, na.Type)
+		}
+		if oa.Optional && !na.Optional {
+			add("argument %s: now required", oa.Name)
+		}
+		if oa.Variadic && !na.Variadic {
+			add("argument %s: no longer takes multiple values", oa.Name)
+		}
+		if na.Variadic && na.Min > oa.Min {
+			add("argument %s: minimum number of values raised to %d", oa.Name, na.Min)
+		}
+		if r := removedChoices(oa.Choices, na.Choices); r != "" {
+			add("argument %s: %s", oa.Name, r)
+		}
+	}
+	for _, na := range new.Args[min(len(old.Args), len(new.Args)):] {
+		if !na.Optional && !(na.Variadic && na.Min == 0) {
+			add("new required argument %s", na.Name)
+		}
+	}
+
+	// Sub-commands.
+	for _, oc := range old.Commands {
+		var nc *Spec
+		for _, c := range new.Commands {
+			if c.Name == oc.Name {
+				nc = c
+				break
+			}
+		}
+		if nc == nil {
+			add("command %q removed", oc.Name)
+			continue
+		}
+		diffSpecs(oc, nc, path+" "+oc.Name, bs)
+	}
+}
+
+// removedChoices describes the values in old that are not in new, or returns
+// the empty string if there are none. A nil list of choices allows any value.
+func removedChoices(old, new []string) string {
+	if new == nil {
+		return ""
+	}
+	if old == nil {
+		return "values restricted to " + strings.Join(new, ", ")
+	}
+	var removed []string
+	for _, c := range old {
+		if !slices.Contains(new, c) {
+			removed = append(removed, c)
+		}
+	}
+	if len(removed) == 0 {
+		return ""
+	}
+	return "choices removed: " + strings.Join(removed, ", ")
+}
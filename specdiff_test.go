@@ -0,0 +1,85 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBreakingChanges(t *testing.T) {
+	old := &Spec{
+		Name: "prog",
+		Commands: []*Spec{
+			{
+				Name:     "get",
+				Runnable: true,
+				Flags: []*FlagSpec{
+					{Name: "format", Aliases: []string{"f"}, Type: "string", Choices: []string{"json", "text", "yaml"}},
+					{Name: "limit", Type: "int"},
+					{Name: "verbose", Type: "bool"},
+					{Name: "key", Type: "string"},
+				},
+				Args: []*ArgSpec{
+					{Name: "ID", Type: "string"},
+					{Name: "FIELDS", Type: "[]string", Optional: true, Variadic: true},
+				},
+			},
+			{Name: "put", Runnable: true},
+			{Name: "old", Runnable: true},
+		},
+	}
+	new := &Spec{
+		Name: "prog",
+		Commands: []*Spec{
+			{
+				Name:     "get",
+				Runnable: true,
+				Flags: []*FlagSpec{
+					{Name: "format", Type: "string", Choices: []string{"json", "text"}},
+					{Name: "limit", Type: "uint"},
+					{Name: "v", Type: "bool"},
+					{Name: "key", Type: "string", Requires: []string{"cert"}},
+					{Name: "cert", Type: "string"},
+				},
+				Args: []*ArgSpec{
+					{Name: "ID", Type: "string"},
+					{Name: "FIELDS", Type: "[]string", Variadic: true, Min: 1},
+				},
+			},
+			{Name: "put", Runnable: true, Args: []*ArgSpec{{Name: "FILE", Type: "string"}}},
+			{Name: "new", Runnable: true},
+		},
+	}
+	got := BreakingChanges(old, new)
+	want := []BreakingChange{
+		{"prog get", "flag -format: alias -f removed"},
+		{"prog get", "flag -format: choices removed: yaml"},
+		{"prog get", "flag -limit: type changed from int to uint"},
+		{"prog get", "flag -verbose removed"},
+		{"prog get", "flag -key: now requires -cert"},
+		{"prog get", "argument FIELDS: now required"},
+		{"prog get", "argument FIELDS: minimum number of values raised to 1"},
+		{"prog put", "new required argument FILE"},
+		{"prog", `command "old" removed`},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	// A spec is compatible with itself, including after a round trip
+	// through JSON.
+	data, err := json.Marshal(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Spec
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if got := BreakingChanges(&saved, old); len(got) != 0 {
+		t.Errorf("got %v, want none", got)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Suggestions for misspelled command and flag names.
+
+// didYouMean returns a phrase suggesting the candidates closest to name,
+// like ` (did you mean "status"?)`, or the empty string if none is close.
+func didYouMean(name string, candidates []string) string {
+	best := suggestions(name, candidates)
+	if len(best) == 0 {
+		return ""
+	}
+	for i, b := range best {
+		best[i] = fmt.Sprintf("%q", b)
+	}
+	return fmt.Sprintf(" (did you mean %s?)", strings.Join(best, " or "))
+}
+
+// suggestions returns the candidates with the smallest edit distance to name,
+// provided that distance is small relative to the length of name.
+func suggestions(name string, candidates []string) []string {
+	limit := len(name) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	var best []string
+	bestDist := limit + 1
+	for _, c := range candidates {
+		d := editDistance(name, c)
+		switch {
+		case d < bestDist:
+			bestDist = d
+			best = []string{c}
+		case d == bestDist:
+			best = append(best, c)
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b, counting a
+// transposition of adjacent characters as a single edit.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// d[i][j] is the distance between ra[:i] and rb[:j].
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// commandSuggestion returns a suggestion for an unknown sub-command name.
+func (c *Command) commandSuggestion(name string) string {
+	var names []string
+	for _, s := range c.subs {
+		names = append(names, s.Name)
+	}
+	return didYouMean(name, names)
+}
+
+// flagSuggestion returns a suggestion for the unknown flag in err, an error
+// from parsing c's flags. It returns the empty string if err is not about an
+// unknown flag.
+func (c *Command) flagSuggestion(err error) string {
+	_, name, found := stringsCut(err.Error(), "flag provided but not defined: -")
+	if !found {
+		return ""
+	}
+	var names []string
+	c.flags.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+	return didYouMean("-"+name, names)
+}
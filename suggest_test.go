@@ -0,0 +1,74 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"status", "status", 0},
+		{"stauts", "status", 1},
+		{"stat", "status", 2},
+		{"kitten", "sitting", 3},
+	} {
+		if got := editDistance(test.a, test.b); got != test.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestDidYouMean(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		candidates []string
+		want       string
+	}{
+		{"lst", []string{"list", "last", "delete"}, ` (did you mean "list" or "last"?)`},
+		{"x", []string{"list"}, ""},
+		{"x", nil, ""},
+	} {
+		if got := didYouMean(test.name, test.candidates); got != test.want {
+			t.Errorf("%q, %q: got %q, want %q", test.name, test.candidates, got, test.want)
+		}
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	top := Top(nil)
+	top.flags.SetOutput(io.Discard)
+	top.Command("status", &c1{}, "")
+	top.Command("start", &c1{}, "")
+	sub := top.Command("sub", &c4{}, "")
+	sub.flags.SetOutput(io.Discard)
+
+	ctx := context.Background()
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"stauts"}, `unknown command "stauts" (did you mean "status"?)`},
+		{[]string{"stat"}, `unknown command "stat" (did you mean "start"?)`},
+		{[]string{"frobnicate"}, `unknown command "frobnicate"`},
+		{[]string{"sub", "-regoin", "x"}, `flag provided but not defined: -regoin (did you mean "-region"?)`},
+	} {
+		err := top.Run(ctx, test.args)
+		if err == nil {
+			t.Errorf("%v: got nil, want error", test.args)
+			continue
+		}
+		got, _, _ := stringsCut(err.Error(), "\n")
+		if !strings.HasSuffix(got, test.want) {
+			t.Errorf("%v: got %q, want suffix %q", test.args, got, test.want)
+		}
+	}
+}
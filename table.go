@@ -0,0 +1,150 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Writing tables.
+
+// An Alignment says how the cells of a table column are aligned.
+type Alignment int
+
+const (
+	AlignLeft Alignment = iota
+	AlignRight
+)
+
+// A Table holds rows of text to be written in aligned columns, as by a
+// command that lists results:
+//
+//	t := &cli.Table{Headers: []string{"NAME", "SIZE"}, Align: []cli.Alignment{cli.AlignLeft, cli.AlignRight}}
+//	for _, f := range files {
+//	  t.Append(f.Name, f.Size)
+//	}
+//	return cli.WriteTable(ctx, t)
+//
+// If the table is wider than MaxWidth, the widest columns are narrowed, and
+// cells that don't fit are truncated with an ellipsis.
+type Table struct {
+	Headers  []string    // the first row, if any
+	Align    []Alignment // the alignment of each column; missing ones are AlignLeft
+	MaxWidth int         // the most columns a line can take; 0 for no limit
+
+	rows [][]string
+}
+
+// The space between columns of a table.
+const tableGap = "  "
+
+// The narrowest a column is made to fit a table in its MaxWidth.
+const minColumnWidth = 5
+
+// cellReplacer removes line breaks and tabs, which would spoil the
+// alignment, from table cells.
+var cellReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\t", " ")
+
+// Append adds a row to t. Each cell is formatted with fmt.Sprint.
+func (t *Table) Append(cells ...interface{}) {
+	row := make([]string, len(cells))
+	for i, c := range cells {
+		row[i] = cellReplacer.Replace(fmt.Sprint(c))
+	}
+	t.rows = append(t.rows, row)
+}
+
+// Write writes t to w.
+func (t *Table) Write(w io.Writer) error {
+	var rows [][]string
+	if len(t.Headers) > 0 {
+		rows = append(rows, t.Headers)
+	}
+	rows = append(rows, t.rows...)
+	widths := t.columnWidths(rows)
+	var b strings.Builder
+	for _, row := range rows {
+		var line strings.Builder
+		for i, width := range widths {
+			var cell string
+			if i < len(row) {
+				cell = truncateWidth(row[i], width)
+			}
+			if i > 0 {
+				line.WriteString(tableGap)
+			}
+			pad := strings.Repeat(" ", width-displayWidth(cell))
+			if i < len(t.Align) && t.Align[i] == AlignRight {
+				line.WriteString(pad + cell)
+			} else {
+				line.WriteString(cell + pad)
+			}
+		}
+		b.WriteString(strings.TrimRight(line.String(), " "))
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// columnWidths returns the width of each column of rows, narrowed to fit
+// t.MaxWidth if possible.
+func (t *Table) columnWidths(rows [][]string) []int {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := displayWidth(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	if t.MaxWidth <= 0 {
+		return widths
+	}
+	total := len(tableGap) * (len(widths) - 1)
+	for _, n := range widths {
+		total += n
+	}
+	for total > t.MaxWidth {
+		// Narrow the widest column.
+		widest := 0
+		for i, n := range widths {
+			if n > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumnWidth {
+			break
+		}
+		widths[widest]--
+		total--
+	}
+	return widths
+}
+
+// TableOptions is a bundle with a "-no-trunc" flag for commands that write
+// tables with WriteTable. Pass a *TableOptions to Command or Register.
+type TableOptions struct {
+	NoTrunc bool `cli:"flag=no-trunc, don't truncate table cells to fit the terminal"`
+}
+
+// WriteTable writes t to the command's standard output. If t.MaxWidth is zero
+// and standard output is a terminal, the table is fit to the terminal's width.
+// If the command has a TableOptions bundle whose flag is set, nothing is
+// truncated.
+func WriteTable(ctx context.Context, t *Table) error {
+	inv := invocationOrDefault(ctx)
+	u := *t
+	if o := bundleFor[TableOptions](inv.cmd); o != nil && o.NoTrunc {
+		u.MaxWidth = 0
+	} else if u.MaxWidth == 0 {
+		u.MaxWidth = terminalWidth(inv.stdout)
+	}
+	return u.Write(inv.stdout)
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	newTable := func(max int) *Table {
+		tab := &Table{
+			Headers:  []string{"NAME", "SIZE", "DESCRIPTION"},
+			Align:    []Alignment{AlignLeft, AlignRight},
+			MaxWidth: max,
+		}
+		tab.Append("a.txt", 12, "a short file")
+		tab.Append("世界.go", 3456, "wide\nname")
+		tab.Append("c")
+		return tab
+	}
+	for _, test := range []struct {
+		max  int
+		want string
+	}{
+		{0, `
+NAME     SIZE  DESCRIPTION
+a.txt      12  a short file
+世界.go  3456  wide name
+c
+`},
+		{22, `
+NAME     SIZE  DESCRI…
+a.txt      12  a shor…
+世界.go  3456  wide n…
+c
+`},
+		// Columns are not narrowed past a minimum.
+		{10, `
+NAME   SIZE  DESC…
+a.txt    12  a sh…
+世界…  3456  wide…
+c
+`},
+	} {
+		var buf bytes.Buffer
+		if err := newTable(test.max).Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := buf.String(), test.want[1:]; got != want {
+			t.Errorf("max %d: got\n%s\nwant\n%s", test.max, got, want)
+		}
+	}
+}
+
+type tabler struct{}
+
+func (l *tabler) Run(ctx context.Context) error {
+	t := &Table{MaxWidth: 8}
+	t.Append("abcdefghij")
+	return WriteTable(ctx, t)
+}
+
+func TestWriteTable(t *testing.T) {
+	top := initFlags(&Command{Name: "prog"})
+	top.Command("list", &tabler{}, "list", &TableOptions{})
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"list"}, "abcdefg…\n"},
+		{[]string{"list", "-no-trunc"}, "abcdefghij\n"},
+	} {
+		stdout, stderr, _ := top.ExecuteCapture(context.Background(), test.args...)
+		if stdout != test.want {
+			t.Errorf("%v: got %q, want %q (stderr %q)", test.args, stdout, test.want, stderr)
+		}
+	}
+}
+
+func TestTruncateWidth(t *testing.T) {
+	for _, test := range []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"hello", 5, "hello"},
+		{"hello", 4, "hel…"},
+		{"hello", 1, "…"},
+		{"hello", 0, ""},
+		{"世界abc", 4, "世…"},
+	} {
+		if got := truncateWidth(test.in, test.width); got != test.want {
+			t.Errorf("truncateWidth(%q, %d) = %q, want %q", test.in, test.width, got, test.want)
+		}
+	}
+}
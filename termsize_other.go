@@ -0,0 +1,13 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package cli
+
+import "os"
+
+// terminalSize returns zeros, since the size of a terminal can't be found
+// on this system.
+func terminalSize(f *os.File) (cols, rows int) {
+	return 0, 0
+}
@@ -0,0 +1,31 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var procGetConsoleScreenBufferInfo = syscall.NewLazyDLL("kernel32.dll").NewProc("GetConsoleScreenBufferInfo")
+
+// consoleScreenBufferInfo is CONSOLE_SCREEN_BUFFER_INFO.
+type consoleScreenBufferInfo struct {
+	Size              struct{ X, Y int16 }
+	CursorPosition    struct{ X, Y int16 }
+	Attributes        uint16
+	Window            struct{ Left, Top, Right, Bottom int16 }
+	MaximumWindowSize struct{ X, Y int16 }
+}
+
+// terminalSize returns the width and height of the console window that f
+// refers to, or zeros if f is not a console.
+func terminalSize(f *os.File) (cols, rows int) {
+	var info consoleScreenBufferInfo
+	r, _, _ := procGetConsoleScreenBufferInfo.Call(f.Fd(), uintptr(unsafe.Pointer(&info)))
+	if r == 0 {
+		return 0, 0
+	}
+	return int(info.Window.Right-info.Window.Left) + 1, int(info.Window.Bottom-info.Window.Top) + 1
+}
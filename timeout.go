@@ -0,0 +1,119 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Limiting how long commands run, and reporting where the time went.
+
+// TimeoutOptions is a bundle with a "-timeout" flag that limits how long a
+// command runs, as in
+//
+//	prog sync -timeout 30s
+//
+// The limit covers the Before methods and BeforeRun hooks of the commands
+// being run, the Run method, and the After method. When it passes, the
+// context they were passed is canceled, and if the command then fails, its
+// error is a *TimeoutError that says which of those phases was interrupted and
+// how long each took.
+//
+// Pass a *TimeoutOptions as a bundle to Command or Register, or call
+// AddTimeoutFlag. If the top-level command has the flag, it applies to all
+// sub-commands.
+type TimeoutOptions struct {
+	Timeout time.Duration `cli:"flag=timeout, 'fail if the command takes longer than this; zero means no limit'"`
+}
+
+// AddTimeoutFlag adds the flag of TimeoutOptions to c.
+func (c *Command) AddTimeoutFlag() {
+	c.addBundle(&TimeoutOptions{})
+}
+
+// A Phase is a part of running a command: "Before" for the Before methods and
+// BeforeRun hooks, "Run" for the Run method and its middleware, and "After"
+// for the After method.
+type Phase struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+}
+
+// A TimeoutError is returned by a command that failed after its -timeout
+// passed. See TimeoutOptions.
+type TimeoutError struct {
+	Timeout     time.Duration
+	Interrupted string  // the name of the phase in progress when the time ran out
+	Phases      []Phase // the phases that ran, in order
+	Err         error   // the command's error
+}
+
+func (e *TimeoutError) Error() string {
+	var ps []string
+	for _, p := range e.Phases {
+		ps = append(ps, fmt.Sprintf("%s %s", p.Name, p.Duration.Round(time.Millisecond)))
+	}
+	return fmt.Sprintf("timed out after %s during %s (%s): %v",
+		e.Timeout, e.Interrupted, strings.Join(ps, ", "), e.Err)
+}
+
+// Unwrap supports errors.Is and errors.As.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// timeout returns the duration of the -timeout flag of c's own
+// TimeoutOptions, or 0 if it has none.
+func (c *Command) timeout() time.Duration {
+	for _, b := range c.bundles {
+		if t, ok := b.(*TimeoutOptions); ok {
+			return t.Timeout
+		}
+	}
+	return 0
+}
+
+// timePhase calls f and records how long it took as part of the named phase.
+// Time spent in a phase in several calls, like the Before methods of
+// a command and its sub-command, is added together.
+func (inv *invocation) timePhase(name string, f func() error) error {
+	start := timeNow()
+	err := f()
+	d := timeNow().Sub(start)
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	for i := range inv.phases {
+		if inv.phases[i].Name == name {
+			inv.phases[i].Duration += d
+			return err
+		}
+	}
+	inv.phases = append(inv.phases, Phase{Name: name, Start: start, Duration: d})
+	return err
+}
+
+// timeoutError returns err as a *TimeoutError if ctx, which had the given
+// timeout, is past its deadline. Otherwise it returns err.
+func (inv *invocation) timeoutError(ctx context.Context, timeout time.Duration, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	te := &TimeoutError{Timeout: timeout, Phases: append([]Phase(nil), inv.phases...), Err: err}
+	for _, p := range te.Phases {
+		if !p.Start.After(deadline) {
+			te.Interrupted = p.Name
+		}
+	}
+	if te.Interrupted == "" {
+		te.Interrupted = "setup"
+	}
+	return te
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type sleeper struct {
+	afterErr error // the error passed to After
+}
+
+func (s *sleeper) Before(ctx context.Context) error { return nil }
+
+func (s *sleeper) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return CheckCancel(ctx)
+}
+
+func (s *sleeper) After(ctx context.Context, err error) error {
+	s.afterErr = err
+	return err
+}
+
+func TestTimeout(t *testing.T) {
+	top := initFlags(&Command{Name: "prog"})
+	top.AddTimeoutFlag()
+	s := &sleeper{}
+	top.Command("sleep", s, "sleep until interrupted")
+
+	err := top.Run(context.Background(), []string{"-timeout", "20ms", "sleep"})
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Fatalf("got %v, want a *TimeoutError", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want it to wrap context.DeadlineExceeded", err)
+	}
+	if te.Interrupted != "Run" {
+		t.Errorf("interrupted %q, want Run", te.Interrupted)
+	}
+	var names []string
+	for _, p := range te.Phases {
+		names = append(names, p.Name)
+	}
+	if got, want := strings.Join(names, " "), "Before Run After"; got != want {
+		t.Errorf("phases %q, want %q", got, want)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "timed out after 20ms during Run (Before ") {
+		t.Errorf("message %q", msg)
+	}
+	if !errors.Is(s.afterErr, context.DeadlineExceeded) {
+		t.Errorf("After got %v, want context.DeadlineExceeded", s.afterErr)
+	}
+
+	// Without a timeout, the phases are recorded but nothing times out.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err = top.Run(ctx, []string{"sleep"})
+	if !errors.Is(err, ErrInterrupted) || errors.As(err, &te) {
+		t.Errorf("no timeout: got %v, want ErrInterrupted", err)
+	}
+}
+
+type finisher struct {
+	Invalid bool `cli:"flag=invalid, fail in Validate"`
+	Fail    bool `cli:"flag=fail, fail in Run"`
+
+	calls []string
+}
+
+func (f *finisher) Validate() error {
+	if f.Invalid {
+		return errors.New("invalid")
+	}
+	return nil
+}
+
+func (f *finisher) Run(ctx context.Context) error {
+	f.calls = append(f.calls, "Run")
+	if f.Fail {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func (f *finisher) After(ctx context.Context, err error) error {
+	f.calls = append(f.calls, fmt.Sprintf("After %v", err))
+	return err
+}
+
+func TestAfter(t *testing.T) {
+	// After is called exactly when Run is.
+	for _, test := range []struct {
+		args []string
+		deny bool // fail in a BeforeRun hook
+		want []string
+	}{
+		{nil, false, []string{"Run", "After <nil>"}},
+		{[]string{"-fail"}, false, []string{"Run", "After failed"}},
+		{[]string{"-invalid"}, false, nil},
+		{nil, true, nil},
+	} {
+		top := initFlags(&Command{Name: "prog"})
+		f := &finisher{}
+		top.Command("f", f, "")
+		top.BeforeRun(func(ctx context.Context, c *Command) (context.Context, error) {
+			if test.deny {
+				return ctx, errors.New("denied")
+			}
+			return ctx, nil
+		})
+		var out strings.Builder
+		ctx := withInvocation(context.Background(), &invocation{stdout: &out, stderr: &out})
+		top.Run(ctx, append([]string{"f"}, test.args...))
+		if !cmp.Equal(f.calls, test.want) {
+			t.Errorf("%v, deny=%t: got %q, want %q", test.args, test.deny, f.calls, test.want)
+		}
+	}
+}
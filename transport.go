@@ -0,0 +1,94 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Configuring HTTP clients.
+
+// TransportOptions is a bundle of flags that configure how commands connect
+// to HTTP servers. Add them to a command with AddTransportFlags, or pass a
+// *TransportOptions as a bundle to Command or Register. Commands get a client
+// that honors them with HTTPClient.
+type TransportOptions struct {
+	Proxy              string `cli:"flag=proxy, URL of the HTTP proxy to use, instead of the one from the environment"`
+	CACert             string `cli:"flag=cacert, type=existingfile, PEM file of certificate authorities to trust, in addition to the system's"`
+	InsecureSkipVerify bool   `cli:"flag=insecure-skip-verify, don't verify the certificates of servers (unsafe)"`
+
+	mu     sync.Mutex
+	key    string // the exported fields used to build client
+	client *http.Client
+}
+
+// AddTransportFlags adds the flags of TransportOptions to c. It is typically
+// called on the top-level command, so that all commands connect the same way.
+func (c *Command) AddTransportFlags() {
+	c.addBundle(&TransportOptions{})
+}
+
+// HTTPClient returns an HTTP client configured by the TransportOptions of the
+// running command or the nearest command above it, or http.DefaultClient if
+// none has them. It returns an error if the options are invalid, as when the
+// proxy URL can't be parsed.
+func HTTPClient(ctx context.Context) (*http.Client, error) {
+	if t := bundleFor[TransportOptions](invocationOrDefault(ctx).cmd); t != nil {
+		if t.InsecureSkipVerify {
+			warnOnce(ctx, "insecure-skip-verify", "not verifying server certificates (-insecure-skip-verify)")
+		}
+		return t.Client()
+	}
+	return http.DefaultClient, nil
+}
+
+// Client returns an HTTP client configured by o. It returns the same client
+// until o's fields change.
+func (o *TransportOptions) Client() (*http.Client, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	key := fmt.Sprintf("%q %q %t", o.Proxy, o.CACert, o.InsecureSkipVerify)
+	if o.client != nil && o.key == key {
+		return o.client, nil
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if o.Proxy != "" {
+		p := o.Proxy
+		if !strings.Contains(p, "://") {
+			p = "http://" + p
+		}
+		u, err := url.Parse(p)
+		if err != nil {
+			return nil, fmt.Errorf("-proxy: %w", err)
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+	if o.CACert != "" || o.InsecureSkipVerify {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+	}
+	if o.CACert != "" {
+		pem, err := os.ReadFile(o.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("-cacert: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("-cacert: no certificates in %s", o.CACert)
+		}
+		tr.TLSClientConfig.RootCAs = pool
+	}
+	o.key = key
+	o.client = &http.Client{Transport: tr}
+	return o.client, nil
+}
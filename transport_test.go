@@ -0,0 +1,98 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type fetcher struct {
+	URL string
+}
+
+func (f *fetcher) Run(ctx context.Context) error {
+	client, err := HTTPClient(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := client.Get(f.URL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	fmt.Fprintln(Stdout(ctx), res.Status)
+	return nil
+}
+
+func TestTransportFlags(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	proxied := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+	}))
+	defer proxy.Close()
+
+	cacert := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}
+	if err := os.WriteFile(cacert, pem.EncodeToMemory(block), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		args    []string
+		wantErr string
+		warning bool
+	}{
+		{nil, "certificate", false},
+		{[]string{"-cacert", cacert}, "", false},
+		{[]string{"-insecure-skip-verify"}, "", true},
+		{[]string{"-cacert", "transport_test.go"}, "no certificates", false},
+	} {
+		top := &Command{Name: "prog"}
+		initFlags(top)
+		top.AddTransportFlags()
+		top.Command("fetch", &fetcher{}, "")
+		var out bytes.Buffer
+		ctx := withInvocation(context.Background(), &invocation{stdout: &out, stderr: &out})
+		err := top.Run(ctx, append(test.args, "fetch", srv.URL))
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%v: %v", test.args, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%v: got error %v, want one containing %q", test.args, err, test.wantErr)
+		}
+		if got := strings.Contains(out.String(), "warning:"); got != test.warning {
+			t.Errorf("%v: warning = %t, want %t; output:\n%s", test.args, got, test.warning, out.String())
+		}
+	}
+
+	// A bundle on a sub-command, with a proxy.
+	top := &Command{Name: "prog"}
+	initFlags(top)
+	top.Command("fetch", &fetcher{}, "", &TransportOptions{})
+	ctx := withInvocation(context.Background(), &invocation{stdout: &bytes.Buffer{}})
+	if err := top.Run(ctx, []string{"fetch", "-proxy", proxy.URL, "http://example.com/x"}); err != nil {
+		t.Fatal(err)
+	}
+	if proxied != 1 {
+		t.Errorf("proxy got %d requests, want 1", proxied)
+	}
+}
+
+func TestHTTPClientDefault(t *testing.T) {
+	c, err := HTTPClient(context.Background())
+	if err != nil || c != http.DefaultClient {
+		t.Errorf("got %v, %v; want http.DefaultClient", c, err)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+// Customizing usage messages.
+
+// A UsageWriter writes the usage message of a command. Set the UsageWriter
+// field of the top-level command to control the layout, order and wording of
+// the usage messages of all commands, while still using the parts that are
+// generated from their definitions.
+type UsageWriter interface {
+	WriteUsage(w io.Writer, u *UsageInfo) error
+}
+
+// UsageInfo holds the parts of a command's usage message, formatted as
+// the built-in layout formats them.
+type UsageInfo struct {
+	Command     *Command
+	Path        string // the command's name, after the names of the commands above it, like "prog list"
+	Line        string // a summary of the command line, like "prog list [flags] DIR"
+	Usage       string // the command's one-line description
+	Long        string // the command's longer description, indented and followed by a blank line
+	Deprecated  string // why the command is deprecated, if it is
+	Args        string // a line for each described positional argument
+	Flags       string // descriptions of the flags, as the flag package writes them
+	Examples    string // the command's examples, with their command lines
+	Commands    string // a line for each sub-command, with its description, under headings for their categories
+	CommandHint string // a sentence saying how to get help on a sub-command, if there are any
+}
+
+// usageInfo returns the parts of c's usage message, in the style st.
+func (c *Command) usageInfo(st helpStyle) *UsageInfo {
+	u := &UsageInfo{
+		Command:    c,
+		Path:       c.Path(),
+		Line:       c.usageHeader(),
+		Usage:      c.Usage,
+		Deprecated: c.Deprecated,
+	}
+	if c.Long != "" {
+		u.Long = indent(c.Long, "  ") + "\n"
+	}
+	var b strings.Builder
+	c.printArgs(&b, st.width)
+	u.Args = b.String()
+	b.Reset()
+	c.printFlags(&b, st)
+	u.Flags = b.String()
+	b.Reset()
+	c.printExamples(&b)
+	u.Examples = b.String()
+	if len(c.subs) > 0 {
+		b.Reset()
+		c.printCommandEntries(&b, st)
+		u.Commands = b.String()
+		u.CommandHint = c.commandHint(st)
+	}
+	return u
+}
+
+// usageWriter returns the UsageWriter of c or the nearest command above it
+// that has one, or nil if none does.
+func (c *Command) usageWriter() UsageWriter {
+	for ; c != nil; c = c.super {
+		if c.UsageWriter != nil {
+			return c.UsageWriter
+		}
+	}
+	return nil
+}
+
+// DefaultUsageTemplate is a template for UsageTemplate that produces much
+// the same usage messages as the built-in layout. It is a starting point for
+// customization.
+const DefaultUsageTemplate = `Usage:
+{{.Line}}{{if .Usage}}    {{.Usage}}{{end}}
+{{if .Deprecated}}  Deprecated: {{.Deprecated}}
+{{end}}{{if .Long}}
+{{.Long}}{{end}}{{.Args}}{{.Flags}}{{if .Examples}}
+Examples:
+{{.Examples}}{{end}}{{if .Commands}}
+{{.Commands}}
+{{.CommandHint}}
+{{end}}`
+
+// UsageTemplate returns a UsageWriter that executes the text/template text
+// with a *UsageInfo. For example, this template puts the description first
+// and leaves out the flags:
+//
+//	{{.Usage}}
+//
+//	usage: {{.Line}}
+//	{{.Args}}
+//
+// See DefaultUsageTemplate for a more complete example.
+func UsageTemplate(text string) (UsageWriter, error) {
+	t, err := template.New("usage").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return templateUsageWriter{t}, nil
+}
+
+type templateUsageWriter struct {
+	t *template.Template
+}
+
+func (t templateUsageWriter) WriteUsage(w io.Writer, u *UsageInfo) error {
+	return t.t.Execute(w, u)
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUsageTemplate(t *testing.T) {
+	newTop := func() *Command {
+		top := initFlags(&Command{Name: "prog", Usage: "a program"})
+		top.flags.Bool("quiet", false, "say less")
+		say := top.Command("say", &shouter{}, "say words")
+		say.Long = "Say writes its arguments.\n\nWith -loud, it shouts them."
+		say.Examples = []Example{
+			{Doc: "Shout.", Args: []string{"-loud", "hello there"}},
+			{Args: []string{"hi"}},
+		}
+		top.Command("other", nil, "another group").Command("x", &shouter{}, "x")
+		return top
+	}
+	usage := func(c *Command) string {
+		var buf bytes.Buffer
+		c.usage(&buf)
+		return buf.String()
+	}
+
+	// The default template matches the built-in layout.
+	builtin := newTop()
+	templ := newTop()
+	var err error
+	templ.UsageWriter, err = UsageTemplate(DefaultUsageTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range [][]string{nil, {"say"}, {"other"}} {
+		b, c := builtin, templ
+		for _, name := range path {
+			b, c = b.findSub(name), c.findSub(name)
+		}
+		if got, want := usage(c), usage(b); got != want {
+			t.Errorf("%v: got\n%s\nwant\n%s", path, got, want)
+		}
+	}
+
+	// A custom template applies to sub-commands.
+	top := newTop()
+	top.UsageWriter, err = UsageTemplate("{{.Usage}}\n\nusage: {{.Line}}\n{{.Args}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := usage(top.findSub("say")), "say words\n\nusage: prog [flags] say [flags] WORD...\n  WORD       words\n"; got != want {
+		t.Errorf("custom: got\n%q\nwant\n%q", got, want)
+	}
+
+	if _, err := UsageTemplate("{{.Nope"); err == nil {
+		t.Error("bad template: got nil, want error")
+	}
+}
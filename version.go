@@ -0,0 +1,79 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Reporting the program's version.
+
+// VersionInfo describes the version of a program. See Command.SetVersion.
+type VersionInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"date,omitempty"`
+}
+
+// String formats v on one line, like "1.2.0 (commit 4f3c2a1, built 2021-06-01)".
+func (v VersionInfo) String() string {
+	var extra []string
+	if v.Commit != "" {
+		extra = append(extra, "commit "+v.Commit)
+	}
+	if v.Date != "" {
+		extra = append(extra, "built "+v.Date)
+	}
+	if len(extra) == 0 {
+		return v.Version
+	}
+	return fmt.Sprintf("%s (%s)", v.Version, strings.Join(extra, ", "))
+}
+
+// FormatVersionJSON writes v to w as JSON. It can be used as the
+// VersionFormat of a Command.
+func FormatVersionJSON(w io.Writer, v VersionInfo) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// SetVersion records version information for the program whose top-level
+// command is c. It adds a "-version" flag to c which prints the information.
+// If c is a group of commands rather than a runnable command, where a
+// "version" argument would be taken as a sub-command name anyway, it also
+// adds a "version" sub-command that does the same. The output is the
+// program's name followed by VersionInfo.String, unless c.VersionFormat is
+// set.
+// If c already has a sub-command named "version", SetVersion leaves it alone,
+// and a "version" sub-command registered later replaces the one SetVersion
+// added.
+func (c *Command) SetVersion(version, commit, date string) {
+	c.version = &VersionInfo{Version: version, Commit: commit, Date: date}
+	c.flags.BoolVar(&c.showVersion, "version", false, "print version information and exit")
+	if _, ok := c.runnable(); ok {
+		return
+	}
+	if c.findSub("version") == nil {
+		c.Register(&Command{
+			Name:     "version",
+			Usage:    "print version information",
+			runner:   RunFunc(c.printVersion),
+			implicit: true,
+		})
+	}
+}
+
+// printVersion writes c's version information to standard output.
+func (c *Command) printVersion(ctx context.Context) error {
+	w := invocationOrDefault(ctx).stdout
+	if c.VersionFormat != nil {
+		return c.VersionFormat(w, *c.version)
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", c.Name, c.version)
+	return err
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+func TestSetVersion(t *testing.T) {
+	for _, test := range []struct {
+		args   []string
+		format func(io.Writer, VersionInfo) error
+		want   string
+	}{
+		{[]string{"-version"}, nil, "prog 1.2.0 (commit 4f3c2a1, built 2021-06-01)\n"},
+		{[]string{"version"}, nil, "prog 1.2.0 (commit 4f3c2a1, built 2021-06-01)\n"},
+		{
+			[]string{"version"},
+			FormatVersionJSON,
+			"{\n  \"version\": \"1.2.0\",\n  \"commit\": \"4f3c2a1\",\n  \"date\": \"2021-06-01\"\n}\n",
+		},
+	} {
+		top := initFlags(&Command{Name: "prog", VersionFormat: test.format})
+		top.Command("other", &c1{}, "")
+		top.SetVersion("1.2.0", "4f3c2a1", "2021-06-01")
+		var out bytes.Buffer
+		ctx := withInvocation(context.Background(), &invocation{stdout: &out, stderr: io.Discard})
+		if err := top.Run(ctx, test.args); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != test.want {
+			t.Errorf("%v: got %q, want %q", test.args, got, test.want)
+		}
+	}
+
+	// A runnable command gets the flag but no sub-command, so "version" is
+	// still an argument.
+	r := initFlags(&Command{Name: "prog", Struct: &c1{}})
+	if err := r.processFields(); err != nil {
+		t.Fatal(err)
+	}
+	r.SetVersion("1.2.0", "", "")
+	if r.findSub("version") != nil {
+		t.Error("runnable command got a version sub-command")
+	}
+
+	// A later "version" sub-command replaces the one SetVersion added.
+	g := initFlags(&Command{Name: "prog"})
+	g.Command("other", &c1{}, "")
+	g.SetVersion("1.2.0", "", "")
+	mine := g.Command("version", &c3{}, "my version")
+	if g.findSub("version") != mine || len(g.subs) != 2 {
+		t.Errorf("version sub-command was not replaced: %v", g.subs)
+	}
+
+	if got, want := (VersionInfo{Version: "dev"}).String(), "dev"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Re-running commands until their output satisfies a condition.
+
+// WaitOptions is a bundle of flags that re-run a command until the JSON it
+// writes, as with WriteJSON, satisfies a condition. They are for scripts that
+// must wait for something to become ready, as in
+//
+//	prog status -wait-until state=ready -wait-timeout 5m
+//
+// Pass a *WaitOptions as a bundle to Command or Register, or call
+// AddWaitFlags. Like WatchOptions, they make sense only for commands that
+// don't change anything.
+//
+// The condition is a Filter. It is satisfied when any of the JSON values a run
+// writes matches it. Then the command succeeds, and that run's output is
+// written. If the timeout elapses first, the last run's output is written, and
+// the command fails with ErrWaitTimeout, for which Command.Main returns exit
+// code 4. Errors from runs don't end waiting, except for usage errors; the
+// last one is reported on timeout. A zero timeout waits until the command is
+// interrupted.
+type WaitOptions struct {
+	Until    Filter        `cli:"flag=wait-until, 're-run the command until its JSON output matches all these comma-separated conditions, like state=ready'"`
+	Timeout  time.Duration `cli:"flag=wait-timeout, 'with -wait-until, how long to wait before failing; zero means forever'"`
+	Interval time.Duration `cli:"flag=wait-interval, 'with -wait-until, the time between runs (default 2s)'"`
+}
+
+// ErrWaitTimeout is the error returned when the condition of a -wait-until
+// flag isn't satisfied in time. See WaitOptions.
+var ErrWaitTimeout = errors.New("timed out waiting for condition")
+
+// AddWaitFlags adds the flags of WaitOptions to c.
+func (c *Command) AddWaitFlags() {
+	c.addBundle(&WaitOptions{})
+}
+
+// waitUntil runs run every w.Interval until its output satisfies w.Until,
+// w.Timeout elapses, run returns a usage error, or ctx is done.
+func (c *Command) waitUntil(ctx context.Context, run RunFunc, w *WaitOptions) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	var deadline <-chan time.Time
+	if w.Timeout > 0 {
+		t := time.NewTimer(w.Timeout)
+		defer t.Stop()
+		deadline = t.C
+	}
+	inv := invocationFrom(ctx)
+	stdout := inv.stdout
+	defer func() { inv.stdout = stdout }()
+	var (
+		buf     bytes.Buffer
+		lastErr error
+	)
+	for {
+		buf.Reset()
+		inv.stdout = &buf
+		err := run(ctx)
+		inv.stdout = stdout
+		var uerr *UsageError
+		if errors.As(err, &uerr) {
+			return err
+		}
+		if err == nil {
+			ok, merr := outputMatches(buf.Bytes(), w.Until)
+			if merr != nil {
+				return &UsageError{c, fmt.Errorf("-wait-until: %w", merr)}
+			}
+			if ok {
+				_, err := stdout.Write(buf.Bytes())
+				return err
+			}
+		}
+		lastErr = err
+		select {
+		case <-ctx.Done():
+			return CheckCancel(ctx)
+		case <-deadline:
+			if _, err := stdout.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			if lastErr != nil {
+				return fmt.Errorf("%w; last error: %v", ErrWaitTimeout, lastErr)
+			}
+			return ErrWaitTimeout
+		case <-time.After(interval):
+		}
+	}
+}
+
+// outputMatches reports whether any of the JSON values in out matches f.
+func outputMatches(out []byte, f Filter) (bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(out))
+	// Compare numbers as they were written.
+	dec.UseNumber()
+	for {
+		var v interface{}
+		if err := dec.Decode(&v); err == io.EOF {
+			return false, nil
+		} else if err != nil {
+			return false, fmt.Errorf("output is not JSON: %w", err)
+		}
+		ok, err := f.Match(v)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type prober struct {
+	ReadyAt int  `cli:"flag=ready-at, run on which the state is ready"`
+	Text    bool `cli:"flag=text, write text instead of JSON"`
+	runs    int
+}
+
+func (p *prober) Run(ctx context.Context) error {
+	p.runs++
+	if p.Text {
+		fmt.Fprintln(Stdout(ctx), "ready")
+		return nil
+	}
+	if p.runs == 2 {
+		return errors.New("unavailable")
+	}
+	state := "pending"
+	if p.ReadyAt > 0 && p.runs >= p.ReadyAt {
+		state = "ready"
+	}
+	return WriteJSON(ctx, map[string]interface{}{"state": state, "runs": p.runs})
+}
+
+func TestWaitUntil(t *testing.T) {
+	for _, test := range []struct {
+		args     []string
+		wantOut  string
+		wantErr  string
+		wantCode int
+	}{
+		{[]string{"-ready-at", "3"}, `"state": "pending"`, "", 0},
+		{[]string{"-ready-at", "3", "-wait-until", "state=ready"}, `"runs": 3`, "", 0},
+		{[]string{"-ready-at", "3", "-wait-until", "state=ready,runs=4"}, `"runs": 4`, "", 0},
+		{[]string{"-wait-until", "state=ready", "-wait-timeout", "20ms"}, `"state": "pending"`, "timed out", 4},
+		{[]string{"-text", "-wait-until", "state=ready"}, "", "not JSON", 2},
+		{[]string{"-wait-until", "state=ready", "-watch"}, "", "cannot be used together", 2},
+	} {
+		top := initFlags(&Command{Name: "prog"})
+		top.Command("probe", &prober{}, "probe", &WaitOptions{}, &WatchOptions{})
+		args := append([]string{"probe", "-wait-interval", "1ms"}, test.args...)
+		stdout, stderr, code := top.ExecuteCapture(context.Background(), args...)
+		if !strings.Contains(stdout, test.wantOut) {
+			t.Errorf("%v: stdout = %q, want it to contain %q", test.args, stdout, test.wantOut)
+		}
+		if !strings.Contains(stderr, test.wantErr) {
+			t.Errorf("%v: stderr = %q, want it to contain %q", test.args, stderr, test.wantErr)
+		}
+		if code != test.wantCode {
+			t.Errorf("%v: code = %d, want %d", test.args, code, test.wantCode)
+		}
+	}
+}
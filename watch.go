@@ -0,0 +1,196 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Re-running commands periodically.
+
+// DefaultWatchInterval is the interval between runs for a bare -watch flag.
+const DefaultWatchInterval = 2 * time.Second
+
+// WatchOptions is a bundle of flags that re-run a command periodically, like
+// the watch program, until it is interrupted. Pass a *WatchOptions as a bundle
+// to Command or Register, or call AddWatchFlags. They make sense only for
+// commands that don't change anything.
+//
+// With -watch, the command runs every DefaultWatchInterval; with
+// -watch=5s, every five seconds. If standard output is a terminal, the screen
+// is cleared before each run's output. With -watch-diff, the first run's
+// output is written in full, and each later run writes only the lines that
+// changed, prefixed with "-" or "+".
+//
+// An error from a run is written to standard error, and the command runs again
+// at the next interval. A usage error ends watching. An interrupt (ctrl-C) or
+// termination signal also ends it, and the command succeeds.
+type WatchOptions struct {
+	Watch WatchInterval `cli:"flag=watch, 're-run the command periodically; -watch=5s sets the interval'"`
+	Diff  bool          `cli:"flag=watch-diff, 'with -watch, show only the lines of output that changed'"`
+}
+
+// A WatchInterval is the value of the -watch flag. It can be set to true, for
+// DefaultWatchInterval, false, or a positive duration.
+type WatchInterval time.Duration
+
+// IsBoolFlag lets the flag appear without a value, as in "-watch".
+func (w *WatchInterval) IsBoolFlag() bool { return true }
+
+// String implements flag.Value.
+func (w *WatchInterval) String() string {
+	if w == nil || *w == 0 {
+		return ""
+	}
+	return time.Duration(*w).String()
+}
+
+// Set implements flag.Value.
+func (w *WatchInterval) Set(s string) error {
+	switch s {
+	case "true":
+		*w = WatchInterval(DefaultWatchInterval)
+		return nil
+	case "false":
+		*w = 0
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.New("want true, false or a duration")
+	}
+	if d <= 0 {
+		return errors.New("interval must be positive")
+	}
+	*w = WatchInterval(d)
+	return nil
+}
+
+// AddWatchFlags adds the flags of WatchOptions to c.
+func (c *Command) AddWatchFlags() {
+	c.addBundle(&WatchOptions{})
+}
+
+// runWatched runs run, repeatedly if c has a WatchOptions bundle whose -watch
+// flag is set or a WaitOptions bundle whose -wait-until flag is set.
+func (c *Command) runWatched(ctx context.Context, run RunFunc) error {
+	w := bundleFor[WatchOptions](c)
+	watching := w != nil && w.Watch != 0
+	if wo := bundleFor[WaitOptions](c); wo != nil && len(wo.Until) > 0 {
+		if watching {
+			return &UsageError{c, errors.New("-watch and -wait-until cannot be used together")}
+		}
+		return c.waitUntil(ctx, run, wo)
+	}
+	if !watching {
+		return run(ctx)
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(ch)
+	return c.watch(ctx, run, w, ch)
+}
+
+// watch runs run every w.Watch until it returns a usage error, ctx is done,
+// or a signal arrives on sigc.
+func (c *Command) watch(ctx context.Context, run RunFunc, w *WatchOptions, sigc <-chan os.Signal) error {
+	var sw signalWatcher
+	ctx, stop := sw.watch(ctx, sigc)
+	defer stop()
+
+	inv := invocationFrom(ctx)
+	stdout := inv.stdout
+	defer func() { inv.stdout = stdout }()
+	clear := !w.Diff && isTerminal(stdout)
+	var prev []string
+	for i := 0; ; i++ {
+		var buf bytes.Buffer
+		inv.stdout = &buf
+		err := run(ctx)
+		inv.stdout = stdout
+		if sw.signal() != nil {
+			// Interrupted: stop quietly, without the partial output.
+			return nil
+		}
+		var uerr *UsageError
+		if errors.As(err, &uerr) {
+			return err
+		}
+		lines := strings.SplitAfter(buf.String(), "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		switch {
+		case clear:
+			fmt.Fprintf(stdout, "\x1b[H\x1b[2JEvery %s: %s\n\n", time.Duration(w.Watch), c.Path())
+			io.WriteString(stdout, buf.String())
+		case w.Diff && i > 0:
+			writeLineDiff(stdout, prev, lines)
+		default:
+			io.WriteString(stdout, buf.String())
+		}
+		prev = lines
+		if err != nil {
+			fmt.Fprintf(inv.stderr, "%s: %v\n", c.Path(), err)
+		}
+		select {
+		case <-ctx.Done():
+			if sw.signal() != nil {
+				return nil
+			}
+			return CheckCancel(ctx)
+		case <-time.After(time.Duration(w.Watch)):
+		}
+	}
+}
+
+// writeLineDiff writes the lines that differ between old and new to w,
+// prefixing removed lines with "-" and added lines with "+".
+func writeLineDiff(w io.Writer, old, new []string) {
+	// lcs[i][j] is the length of the longest common subsequence
+	// of old[i:] and new[j:].
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	line := func(prefix, s string) {
+		if !strings.HasSuffix(s, "\n") {
+			s += "\n"
+		}
+		io.WriteString(w, prefix+s)
+	}
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && j < len(new) && old[i] == new[j]:
+			i++
+			j++
+		case j == len(new) || (i < len(old) && lcs[i+1][j] >= lcs[i][j+1]):
+			line("-", old[i])
+			i++
+		default:
+			line("+", new[j])
+			j++
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type ticker struct {
+	runs  int
+	sigc  chan os.Signal
+	limit int
+}
+
+func (k *ticker) Run(ctx context.Context) error {
+	k.runs++
+	if k.runs == k.limit {
+		if k.sigc != nil {
+			k.sigc <- syscall.SIGINT
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return NewUsageError(errors.New("enough"))
+	}
+	if k.runs == 2 {
+		return errors.New("failed")
+	}
+	fmt.Fprintf(Stdout(ctx), "header\nrun %d\n", k.runs)
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"tick"}, "header\nrun 1\n"},
+		{[]string{"tick", "-watch=1ms"}, "header\nrun 1\nheader\nrun 3\n"},
+		{[]string{"tick", "-watch=1ms", "-watch-diff"}, "header\nrun 1\n-header\n-run 1\n+header\n+run 3\n"},
+	} {
+		k := &ticker{limit: 4}
+		top := initFlags(&Command{Name: "prog"})
+		top.Command("tick", k, "tick", &WatchOptions{})
+		stdout, stderr, _ := top.ExecuteCapture(context.Background(), test.args...)
+		if stdout != test.want {
+			t.Errorf("%v: got\n%s\nwant\n%s", test.args, stdout, test.want)
+		}
+		if len(test.args) > 1 {
+			if want := "prog tick: failed"; !strings.Contains(stderr, want) {
+				t.Errorf("%v: stderr %q does not contain %q", test.args, stderr, want)
+			}
+		}
+	}
+}
+
+func TestWatchSignal(t *testing.T) {
+	sigc := make(chan os.Signal, 1)
+	k := &ticker{limit: 3, sigc: sigc}
+	top := initFlags(&Command{Name: "prog"})
+	cmd := top.Command("tick", k, "tick")
+	var buf bytes.Buffer
+	inv := &invocation{stdout: &buf, stderr: &buf}
+	ctx := withInvocation(context.Background(), inv)
+	w := &WatchOptions{Watch: WatchInterval(time.Millisecond)}
+	if err := cmd.watch(ctx, k.Run, w, sigc); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "header\nrun 1\nprog tick: failed\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWatchInterval(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"true", DefaultWatchInterval, false},
+		{"false", 0, false},
+		{"500ms", 500 * time.Millisecond, false},
+		{"0s", 0, true},
+		{"soon", 0, true},
+	} {
+		var w WatchInterval
+		err := w.Set(test.in)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: got error %v, want error: %t", test.in, err, test.wantErr)
+			continue
+		}
+		if got := time.Duration(w); got != test.want {
+			t.Errorf("%q: got %s, want %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestWriteLineDiff(t *testing.T) {
+	var buf bytes.Buffer
+	writeLineDiff(&buf, []string{"a\n", "b\n", "c\n"}, []string{"a\n", "c\n", "d"})
+	if got, want := buf.String(), "-b\n+d\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"list", 4},
+		{"café", 4},
+		{"café", 4}, // combining acute accent
+		{"一覧", 4},
+		{"学生を表示", 10},
+		{"목록", 4},
+		{"ｌｉｓｔ", 8}, // fullwidth Latin
+		{"ok✅", 4},
+		{"\x1b[1mlist\x1b[0m", 4},
+		{"\x1b[1;31m一覧\x1b[0m", 4},
+	} {
+		if got := displayWidth(test.in); got != test.want {
+			t.Errorf("displayWidth(%q) = %d, want %d", test.in, got, test.want)
+		}
+	}
+}
+
+func TestPadRight(t *testing.T) {
+	for _, test := range []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"ab", 4, "ab  "},
+		{"一覧", 6, "一覧  "},
+		{"一覧", 3, "一覧"},
+	} {
+		if got := padRight(test.in, test.width); got != test.want {
+			t.Errorf("padRight(%q, %d) = %q, want %q", test.in, test.width, got, test.want)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	for _, test := range []struct {
+		in    string
+		width int
+		want  []string
+	}{
+		{"a b c", 0, []string{"a b c"}},
+		{"short", 40, []string{"short"}},
+		{"the quick brown fox jumps over the lazy dog", 20, []string{"the quick brown fox", "jumps over the lazy", "dog"}},
+		{"a supercalifragilisticexpialidocious word", 20, []string{"a", "supercalifragilisticexpialidocious", "word"}},
+		// Widths below the minimum are raised to it.
+		{"the quick brown fox jumps", 5, []string{"the quick brown fox", "jumps"}},
+		{"一覧 一覧 一覧 一覧 一覧 一覧", 20, []string{"一覧 一覧 一覧 一覧", "一覧 一覧"}},
+	} {
+		got := wrapText(test.in, test.width)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("wrapText(%q, %d) = %q, want %q", test.in, test.width, got, test.want)
+		}
+	}
+}
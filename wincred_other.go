@@ -0,0 +1,9 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+//go:build !windows
+
+package cli
+
+func wincredStore() (CredentialStore, error) {
+	return nil, ErrNoKeychain
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestWincred(t *testing.T) {
+	cs, err := wincredStore()
+	if err != nil {
+		t.Skip(err)
+	}
+	service := fmt.Sprintf("jba-cli-test-%d", os.Getpid())
+	if _, err := cs.Get(service, "pat"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("before Set: got %v, want ErrCredentialNotFound", err)
+	}
+	if err := cs.Set(service, "pat", "hunter2"); err != nil {
+		t.Skipf("can't write credentials: %v", err)
+	}
+	defer cs.Delete(service, "pat")
+	if err := cs.Set(service, "pat", "swordfish"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cs.Get(service, "pat"); err != nil || got != "swordfish" {
+		t.Errorf("got %q, %v; want swordfish", got, err)
+	}
+	if err := cs.Delete(service, "pat"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs.Get(service, "pat"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Errorf("after Delete: got %v, want ErrCredentialNotFound", err)
+	}
+	// Deleting a missing credential is not an error.
+	if err := cs.Delete(service, "pat"); err != nil {
+		t.Errorf("second Delete: got %v", err)
+	}
+}
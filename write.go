@@ -0,0 +1,85 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Writing output files.
+
+// AddDryRunFlag adds a boolean flag named "dry-run" to c, which asks commands
+// to show what they would change instead of changing it. It is typically
+// called on the top-level command, so that the flag applies to all commands.
+// Commands check it with DryRun, and WriteFileAtomic honors it.
+func (c *Command) AddDryRunFlag() {
+	c.dryRun = new(bool)
+	c.flags.BoolVar(c.dryRun, "dry-run", false, "show what would be changed, but don't change anything")
+}
+
+// DryRun reports whether the flag added by AddDryRunFlag is set for the
+// running command or one above it. It is also true when the command is run
+// by the command of AddLearnCommand.
+func DryRun(ctx context.Context) bool {
+	inv := invocationOrDefault(ctx)
+	if inv.dryRun {
+		return true
+	}
+	for c := inv.cmd; c != nil; c = c.super {
+		if c.dryRun != nil {
+			return *c.dryRun
+		}
+	}
+	return false
+}
+
+// hasDryRunFlag reports whether AddDryRunFlag was called on c or a command
+// above it.
+func (c *Command) hasDryRunFlag() bool {
+	for ; c != nil; c = c.super {
+		if c.dryRun != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteFileAtomic writes data to the file named by path, like os.WriteFile,
+// but so that other programs see either the old contents of the file or the
+// new ones, never a partial write. It writes a temporary file in the same
+// directory and renames it to path. The file's permissions are perm.
+//
+// If DryRun is true, WriteFileAtomic doesn't write anything; it tells the
+// user on standard error what it would have written.
+func WriteFileAtomic(ctx context.Context, path string, data []byte, perm os.FileMode) (err error) {
+	if DryRun(ctx) {
+		fmt.Fprintf(Stderr(ctx), "dry run: would write %d bytes to %s\n", len(data), path)
+		return nil
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
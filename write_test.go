@@ -0,0 +1,75 @@
+// Copyright 2021 Jonathan Amsterdam.
+
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type saver struct {
+	Path string `cli:"name=PATH, file to write"`
+	Data string `cli:"name=DATA, contents"`
+}
+
+func (s *saver) Run(ctx context.Context) error {
+	return WriteFileAtomic(ctx, s.Path, []byte(s.Data), 0600)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	top := initFlags(&Command{Name: "prog"})
+	top.AddDryRunFlag()
+	top.Command("save", &saver{}, "save data")
+
+	check := func(want string) {
+		t.Helper()
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	_, stderr, code := top.ExecuteCapture(context.Background(), "-dry-run", "save", path, "new")
+	if want := "would write 3 bytes to " + path; code != 0 || !strings.Contains(stderr, want) {
+		t.Errorf("dry run: got (%q, %d), want %q and 0", stderr, code, want)
+	}
+	check("old")
+
+	if _, stderr, code := top.ExecuteCapture(context.Background(), "save", path, "new"); code != 0 {
+		t.Fatalf("got code %d: %s", code, stderr)
+	}
+	check("new")
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fi.Mode().Perm(); got != 0600 {
+			t.Errorf("got mode %v, want 0600", got)
+		}
+	}
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files, want 1", len(entries))
+	}
+
+	if err := WriteFileAtomic(context.Background(), filepath.Join(dir, "nosuch", "f"), nil, 0644); err == nil {
+		t.Error("missing directory: got nil, want error")
+	}
+}